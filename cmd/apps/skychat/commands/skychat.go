@@ -2,17 +2,26 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
 	"embed"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,25 +35,171 @@ import (
 	"github.com/skycoin/skywire/pkg/app/appnet"
 	"github.com/skycoin/skywire/pkg/app/appserver"
 	"github.com/skycoin/skywire/pkg/routing"
+	"github.com/skycoin/skywire/pkg/skyenv"
 	"github.com/skycoin/skywire/pkg/visor/visorconfig"
 )
 
 const (
 	netType = appnet.TypeSkynet
-	port    = routing.Port(1)
+	// port accepts conns carrying plain text messages, the only protocol of older releases.
+	port = routing.Port(1)
+	// framedPort accepts conns carrying length-prefixed frames, which may be control frames.
+	// Visors are dialed on it first, so control frames are only sent to peers supporting them.
+	framedPort = routing.Port(skyenv.SkychatFramedPort)
 )
 
 // var addr = flag.String("addr", ":8001", "address to bind, put an * before the port if you want to be able to access outside localhost")
 var r = netutil.NewRetrier(nil, 50*time.Millisecond, netutil.DefaultMaxBackoff, 5, 2)
 
 var (
-	addr     string
-	appCl    *app.Client
-	clientCh chan string
-	conns    map[cipher.PubKey]net.Conn // Chat connections
-	connsMu  sync.Mutex
+	addr      string
+	appCl     *app.Client
+	subs      = make(map[*subscriber]struct{}) // Subscribers to UI events
+	subsMu    sync.Mutex
+	conns     map[cipher.PubKey]net.Conn // Chat connections
+	connsMu   sync.Mutex
+	dials     = make(map[cipher.PubKey]*pendingDial) // Dials in progress
+	dialsMu   sync.Mutex
+	maxConns  int
+	connSlots chan struct{} // Limits the number of concurrently handled conns
+	statusMu  sync.Mutex
+	listening bool         // Whether skychat conns are being accepted
+	lisPort   routing.Port // Port of the listener granted by the visor
+	lastErr   error        // Last error the app ran into
+	profileMu sync.Mutex
+	local     profile                           // Profile of the local user sent to peers
+	peers     = make(map[cipher.PubKey]profile) // Profiles received from peers
+	expiryMu  sync.Mutex
+	expiries  = make(map[expiryKey]time.Time) // Expiry times of ephemeral messages
 )
 
+// maxMessageSize is the max size of a sent message, so that it fits the read buffer of the receiver.
+const maxMessageSize = 32 * 1024
+
+// maxMessageRequestSize is the max size of a message request body. It fits a message
+// of maxMessageSize bytes with every byte escaped as \uXXXX, and the recipient.
+const maxMessageRequestSize = 6*maxMessageSize + 1024
+
+// frameHeaderSize is the size of the length prefix of frames sent over framed conns.
+const frameHeaderSize = 4
+
+// goodbyeFrame is sent before gracefully closing a conn, so that the peer drops it at once
+// instead of waiting for a read error. Messages cannot contain it, as they may not start with NUL.
+var goodbyeFrame = []byte("\x00goodbye")
+
+// profileFrame prefixes a JSON encoded profile sent to peers, so that they display the name of the user.
+var profileFrame = []byte("\x00profile")
+
+// ephemeralFrame prefixes an ephemeral message, which is deleted by the UIs of both sides
+// once it expires. It is followed by the message id, the TTL in milliseconds and the text.
+var ephemeralFrame = []byte("\x00ephemeral")
+
+const (
+	ephemeralIDSize     = 16
+	ephemeralHeaderSize = ephemeralIDSize + 8
+	// maxEphemeralMessageSize is the max size of an ephemeral message, so that the frame
+	// fits the read buffer of the receiver.
+	maxEphemeralMessageSize = maxMessageSize - ephemeralHeaderSize - len("\x00ephemeral")
+)
+
+// sweepInterval is how often expired messages are swept.
+const sweepInterval = time.Second
+
+// Limits of profile fields, so that a profile frame fits the read buffer of the receiver.
+const (
+	maxProfileNameLen   = 64
+	maxProfileAvatarLen = 1024
+)
+
+var (
+	errTooManyConns   = errors.New("too many skychat connections")
+	errMaxConns       = errors.New("max number of connections must be at least 1")
+	errNoRecipient    = errors.New("message recipient is missing")
+	errEmptyMessage   = errors.New("message is empty")
+	errMessageTooLong = fmt.Errorf("message is longer than %d bytes", maxMessageSize)
+	errControlMessage = errors.New("message may not start with NUL")
+	errNotConnected   = errors.New("no skychat connection to the visor")
+	errNoConversation = errors.New("no conversation with the visor")
+	errProfileName    = fmt.Errorf("profile name is empty or longer than %d bytes", maxProfileNameLen)
+	errProfileAvatar  = fmt.Errorf("profile avatar reference is longer than %d bytes", maxProfileAvatarLen)
+	errEventType      = errors.New("unknown event type")
+	errInvalidTTL     = errors.New("message ttl is not a positive duration")
+	errEphemeralLong  = fmt.Errorf("ephemeral message is longer than %d bytes", maxEphemeralMessageSize)
+	errEphemeralFrame = errors.New("malformed ephemeral message")
+	errFrameTooLong   = fmt.Errorf("frame is longer than %d bytes", maxMessageSize)
+	errPlainConn      = errors.New("peer does not support ephemeral messages")
+)
+
+// eventType is a kind of event delivered to the UI.
+type eventType string
+
+const (
+	eventMessage eventType = "message" // Text message from a peer
+	eventProfile eventType = "profile" // Profile received from a peer
+	eventGoodbye eventType = "goodbye" // Peer closed the conn gracefully
+	eventExpire  eventType = "expire"  // Ephemeral message expired and has to be deleted
+)
+
+// subscriberBuffer is the number of events buffered for a subscriber. Events are dropped
+// for the subscriber while its buffer is full.
+const subscriberBuffer = 16
+
+// uiEvent is an event delivered to the UI, data is JSON encoded.
+type uiEvent struct {
+	typ  eventType
+	data string
+}
+
+// subscriber receives UI events of the types it subscribed to.
+type subscriber struct {
+	ch    chan uiEvent
+	types map[eventType]bool
+}
+
+// profile is a human-readable identity of a skychat user.
+type profile struct {
+	Name      string `json:"name"`
+	AvatarRef string `json:"avatar_ref,omitempty"`
+}
+
+// validate checks that p fits the limits of profile fields.
+func (p profile) validate() error {
+	if p.Name == "" || len(p.Name) > maxProfileNameLen {
+		return errProfileName
+	}
+	if len(p.AvatarRef) > maxProfileAvatarLen {
+		return errProfileAvatar
+	}
+	return nil
+}
+
+// expiryKey identifies an ephemeral message of the conversation with a peer.
+type expiryKey struct {
+	peer cipher.PubKey
+	id   string
+}
+
+// appStatus is a health snapshot of the skychat app.
+type appStatus struct {
+	Listening bool         `json:"listening"`
+	Network   appnet.Type  `json:"network"`
+	Port      routing.Port `json:"port"`
+	Conns     int          `json:"connections"`
+	LastError string       `json:"last_error,omitempty"`
+}
+
+// dial establishes skychat connection to the given address.
+var dial = func(addr appnet.Addr) (net.Conn, error) {
+	return appCl.Dial(addr)
+}
+
+// pendingDial is a dial in progress, shared by all senders to the same visor.
+type pendingDial struct {
+	done chan struct{}
+	conn net.Conn
+	err  error
+}
+
 // the go embed static points to skywire/cmd/apps/skychat/static
 
 //go:embed static
@@ -52,6 +207,7 @@ var embededFiles embed.FS
 
 func init() {
 	RootCmd.Flags().StringVar(&addr, "addr", ":8001", "address to bind, put an * before the port if you want to be able to access outside localhost")
+	RootCmd.Flags().IntVar(&maxConns, "max-conns", 256, "max number of concurrently handled chat connections")
 }
 
 // RootCmd is the root command for skywire-cli
@@ -67,6 +223,9 @@ var RootCmd = &cobra.Command{
 	DisableSuggestions:    true,
 	DisableFlagsInUseLine: true,
 	Version:               buildinfo.Version(),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateMaxConns(maxConns)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 
 		appCl = app.NewClient(nil)
@@ -78,10 +237,10 @@ var RootCmd = &cobra.Command{
 
 		fmt.Println("Successfully started skychat.")
 
-		clientCh = make(chan string)
-		defer close(clientCh)
+		defer closeSubscribers()
 
 		conns = make(map[cipher.PubKey]net.Conn)
+		connSlots = make(chan struct{}, maxConns)
 		go listenLoop()
 
 		if runtime.GOOS == "windows" {
@@ -99,6 +258,11 @@ var RootCmd = &cobra.Command{
 		http.Handle("/", http.FileServer(getFileSystem()))
 		http.HandleFunc("/message", messageHandler(ctx))
 		http.HandleFunc("/sse", sseHandler)
+		http.HandleFunc("/health", healthHandler)
+		http.HandleFunc("/profile", profileHandler)
+		http.HandleFunc("/conversation", conversationHandler)
+
+		go sweepLoop(ctx, sweepInterval)
 
 		url := ""
 		//		address := *addr
@@ -121,6 +285,9 @@ var RootCmd = &cobra.Command{
 
 			go func() {
 				<-termCh
+				if err := disconnectAll(); err != nil {
+					print(fmt.Sprintf("Failed to disconnect: %v\n", err))
+				}
 				setAppStatus(appCl, appserver.AppDetailedStatusStopped)
 				os.Exit(1)
 			}()
@@ -148,7 +315,17 @@ func Execute() {
 	}
 }
 
+// validateMaxConns checks the max number of concurrently handled conns, so that
+// the app does not start rejecting every conn.
+func validateMaxConns(n int) error {
+	if n < 1 {
+		return fmt.Errorf("%w, got --max-conns %d", errMaxConns, n)
+	}
+	return nil
+}
+
 func listenLoop() {
+	var l net.Listener
 	l, err := appCl.Listen(netType, port)
 	if err != nil {
 		print(fmt.Sprintf("Error listening network %v on port %d: %v\n", netType, port, err))
@@ -156,109 +333,717 @@ func listenLoop() {
 		return
 	}
 
-	setAppPort(appCl, port)
+	setAppPort(appCl, l.Addr().(appnet.Addr).Port)
+	// peers still reach us on port if framed one is not available, without control frames
+	if fl, err := appCl.Listen(netType, framedPort); err != nil {
+		print(fmt.Sprintf("Error listening network %v on port %d: %v\n", netType, framedPort, err))
+	} else {
+		l = app.NewMultiListener(l, fl)
+	}
+	if err := serveConns(l); err != nil {
+		print(fmt.Sprintf("Stopped accepting skychat conns: %v\n", err))
+	}
+}
+
+// serveConns accepts skychat conns from l until accepting fails. Then l and all the
+// conns are closed, and the returned error joins the accept failure with close failures.
+func serveConns(l net.Listener) error {
+	if laddr, ok := l.Addr().(appnet.Addr); ok {
+		setListenPort(laddr.Port)
+	}
+	setListening(true)
 
 	for {
 		fmt.Println("Accepting skychat conn...")
 		conn, err := l.Accept()
 		if err != nil {
-			print(fmt.Sprintf("Failed to accept conn: %v\n", err))
-			return
+			setListening(false)
+			setLastError(err)
+
+			errs := []error{fmt.Errorf("accept conn: %w", err)}
+			if err := l.Close(); err != nil && !isConnClosed(err) {
+				errs = append(errs, fmt.Errorf("close listener: %w", err))
+			}
+			return errors.Join(append(errs, closeConns())...)
 		}
 		fmt.Println("Accepted skychat conn")
 
+		conn = wrapConn(conn)
 		raddr := conn.RemoteAddr().(appnet.Addr)
-		connsMu.Lock()
-		conns[raddr.PubKey] = conn
-		connsMu.Unlock()
+		if addConn(raddr.PubKey, conn) != conn {
+			fmt.Printf("Dropped duplicate skychat conn from %s\n", raddr.PubKey)
+			continue
+		}
 		fmt.Printf("Accepted skychat conn on %s from %s\n", conn.LocalAddr(), raddr.PubKey)
 
-		go handleConn(conn)
+		if err := startHandling(raddr.PubKey, conn); err != nil {
+			print(fmt.Sprintf("Rejected skychat conn from %s: %v\n", raddr.PubKey, err))
+			continue
+		}
+		go func() {
+			if err := sendProfile(conn); err != nil {
+				print(fmt.Sprintf("Failed to send profile to %s: %v\n", raddr.PubKey, err))
+			}
+		}()
+	}
+}
+
+// closeConns closes all skychat conns, which also stops their handlers.
+// Returned error joins failures of closing the conns.
+func closeConns() error {
+	connsMu.Lock()
+	old := conns
+	conns = make(map[cipher.PubKey]net.Conn)
+	connsMu.Unlock()
+
+	var errs []error
+	for pk, conn := range old {
+		if err := conn.Close(); err != nil && !isConnClosed(err) {
+			errs = append(errs, fmt.Errorf("close conn to %s: %w", pk, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// disconnect says goodbye to the visor with the given pk and closes the conn to it.
+func disconnect(pk cipher.PubKey) error {
+	connsMu.Lock()
+	conn, ok := conns[pk]
+	delete(conns, pk)
+	connsMu.Unlock()
+
+	if !ok {
+		return errNotConnected
+	}
+	return sayGoodbye(conn)
+}
+
+// deleteP2P deletes the conversation with the visor with the given pk: the conn to it
+// is closed with a goodbye and its profile is forgotten. Messages are stored by the UI.
+func deleteP2P(pk cipher.PubKey) error {
+	profileMu.Lock()
+	_, known := peers[pk]
+	delete(peers, pk)
+	profileMu.Unlock()
+
+	err := disconnect(pk)
+	if errors.Is(err, errNotConnected) {
+		if !known {
+			return fmt.Errorf("%w %s", errNoConversation, pk)
+		}
+		err = nil
+	}
+	return err
+}
+
+// disconnectAll says goodbye to all connected visors and closes the conns to them.
+func disconnectAll() error {
+	connsMu.Lock()
+	old := conns
+	conns = make(map[cipher.PubKey]net.Conn)
+	connsMu.Unlock()
+
+	var errs []error
+	for pk, conn := range old {
+		if err := sayGoodbye(conn); err != nil {
+			errs = append(errs, fmt.Errorf("disconnect %s: %w", pk, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sayGoodbye sends goodbyeFrame over conn, if it is framed, and closes it.
+func sayGoodbye(conn net.Conn) error {
+	var errs []error
+	if isFramed(conn) {
+		if err := writeFull(conn, goodbyeFrame); err != nil && !isConnClosed(err) {
+			errs = append(errs, fmt.Errorf("send goodbye: %w", err))
+		}
+	}
+	if err := conn.Close(); err != nil && !isConnClosed(err) {
+		errs = append(errs, fmt.Errorf("close conn: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// dropConn unregisters conn to the visor with the given pk, unless it was replaced already.
+func dropConn(pk cipher.PubKey, conn net.Conn) {
+	connsMu.Lock()
+	if conns[pk] == conn {
+		delete(conns, pk)
+	}
+	connsMu.Unlock()
+}
+
+// closeConn unregisters conn to the visor with the given pk and closes it, which also
+// stops its handler.
+func closeConn(pk cipher.PubKey, conn net.Conn) {
+	dropConn(pk, conn)
+	if err := conn.Close(); err != nil && !isConnClosed(err) {
+		print(fmt.Sprintf("Failed to close conn: %v\n", err))
+	}
+}
+
+// addConn registers conn to the visor with the given pk and returns the conn kept for it.
+// If both visors dialed each other at once, there are two conns between them. Then both
+// sides keep the conn initiated by the visor with the lower pk and close the other one.
+func addConn(pk cipher.PubKey, conn net.Conn) net.Conn {
+	connsMu.Lock()
+	old, ok := conns[pk]
+	if ok && old != conn && lowerPK(initiator(old), initiator(conn)) {
+		connsMu.Unlock()
+		if err := conn.Close(); err != nil {
+			print(fmt.Sprintf("Failed to close duplicate conn: %v\n", err))
+		}
+		return old
+	}
+	conns[pk] = conn
+	connsMu.Unlock()
+
+	if ok && old != conn {
+		if err := old.Close(); err != nil {
+			print(fmt.Sprintf("Failed to close duplicate conn: %v\n", err))
+		}
+	}
+	return conn
+}
+
+// initiator returns pk of the visor which dialed conn. Dialed conns have skychat port
+// on the remote side, while accepted conns have it on the local one.
+func initiator(conn net.Conn) cipher.PubKey {
+	if raddr, ok := conn.RemoteAddr().(appnet.Addr); ok && raddr.Port != port && raddr.Port != framedPort {
+		return raddr.PubKey
+	}
+	laddr, _ := conn.LocalAddr().(appnet.Addr) //nolint:errcheck
+	return laddr.PubKey
+}
+
+// framedConn is a conn to a visor supporting control frames. Each Write sends b as a single
+// frame prefixed with its length, frames are read with readFrame.
+type framedConn struct {
+	net.Conn
+	r   *bufio.Reader
+	wMu sync.Mutex
+}
+
+// wrapConn returns conn as a framedConn if it was made over framedPort.
+func wrapConn(conn net.Conn) net.Conn {
+	raddr, _ := conn.RemoteAddr().(appnet.Addr) //nolint:errcheck
+	laddr, _ := conn.LocalAddr().(appnet.Addr)  //nolint:errcheck
+	if raddr.Port != framedPort && laddr.Port != framedPort {
+		return conn
+	}
+	return &framedConn{Conn: conn, r: bufio.NewReader(conn)}
+}
+
+// isFramed reports whether control frames may be sent over conn.
+func isFramed(conn net.Conn) bool {
+	_, ok := conn.(*framedConn)
+	return ok
+}
+
+// Write implements net.Conn, sending b as a single frame.
+func (c *framedConn) Write(b []byte) (int, error) {
+	if len(b) > maxMessageSize {
+		return 0, errFrameTooLong
+	}
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(b))
+	binary.BigEndian.PutUint32(frame, uint32(len(b)))
+	frame = append(frame, b...)
+
+	c.wMu.Lock()
+	defer c.wMu.Unlock()
+	if err := writeFull(c.Conn, frame); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// readFrame reads the next frame, failing with errFrameTooLong if the peer sent
+// a frame longer than maxMessageSize.
+func (c *framedConn) readFrame() ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if n > maxMessageSize {
+		return nil, errFrameTooLong
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(c.r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// lowerPK reports whether pk a is lower than pk b.
+func lowerPK(a, b cipher.PubKey) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+// startHandling starts handling conn to the visor with the given pk, unless the limit
+// of concurrently handled conns is reached. In that case conn is dropped and closed.
+func startHandling(pk cipher.PubKey, conn net.Conn) error {
+	slots := connSlots
+	select {
+	case slots <- struct{}{}:
+	default:
+		closeConn(pk, conn)
+		return errTooManyConns
+	}
+
+	go func() {
+		defer func() { <-slots }()
+		handleConn(conn)
+	}()
+	return nil
+}
+
+// readPacket reads the next frame of a framed conn, or the next chunk of text of a plain one.
+func readPacket(conn net.Conn) ([]byte, error) {
+	if fc, ok := conn.(*framedConn); ok {
+		return fc.readFrame()
 	}
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	return buf[:n], err
 }
 
 func handleConn(conn net.Conn) {
 	raddr := conn.RemoteAddr().(appnet.Addr)
+	framed := isFramed(conn)
 	for {
-		buf := make([]byte, 32*1024)
-		n, err := conn.Read(buf)
+		buf, err := readPacket(conn)
 		if err != nil {
-			fmt.Println("Failed to read packet:", err)
-			raddr := conn.RemoteAddr().(appnet.Addr)
-			connsMu.Lock()
-			delete(conns, raddr.PubKey)
-			connsMu.Unlock()
+			if !isConnClosed(err) {
+				fmt.Println("Failed to read packet:", err)
+			}
+			closeConn(raddr.PubKey, conn)
+			return
+		}
+
+		if framed && bytes.HasPrefix(buf, profileFrame) {
+			var p profile
+			if err := json.Unmarshal(buf[len(profileFrame):], &p); err != nil {
+				fmt.Printf("Dropped invalid profile of %s\n", raddr.PubKey)
+				continue
+			}
+			// names exceeding the limits are never sent by well-behaved peers
+			if err := p.validate(); err != nil {
+				fmt.Printf("Dropped conn of %s: %v\n", raddr.PubKey, err)
+				closeConn(raddr.PubKey, conn)
+				return
+			}
+			profileMu.Lock()
+			peers[raddr.PubKey] = p
+			profileMu.Unlock()
+			publishJSON(eventProfile, map[string]string{"sender": raddr.PubKey.Hex(), "name": p.Name, "avatar_ref": p.AvatarRef})
+			continue
+		}
+
+		if framed && bytes.Equal(buf, goodbyeFrame) {
+			fmt.Printf("Skychat conn closed by %s\n", raddr.PubKey)
+			publishJSON(eventGoodbye, map[string]string{"sender": raddr.PubKey.Hex()})
+			closeConn(raddr.PubKey, conn)
 			return
 		}
 
-		clientMsg, err := json.Marshal(map[string]string{"sender": raddr.PubKey.Hex(), "message": string(buf[:n])})
+		text := buf
+		var id string
+		var expiresAt time.Time
+		if framed && bytes.HasPrefix(text, ephemeralFrame) {
+			var ttl time.Duration
+			if id, ttl, text, err = parseEphemeral(text); err != nil {
+				fmt.Printf("Dropped invalid ephemeral message of %s\n", raddr.PubKey)
+				continue
+			}
+			expiresAt = time.Now().Add(ttl)
+			trackExpiry(raddr.PubKey, id, expiresAt)
+		} else if framed && len(text) > 0 && text[0] == 0 {
+			// control frames of newer releases are skipped
+			fmt.Printf("Dropped unknown control frame of %s\n", raddr.PubKey)
+			continue
+		}
+
+		uiMsg := map[string]string{"sender": raddr.PubKey.Hex(), "message": string(text)}
+		if id != "" {
+			uiMsg["id"] = id
+			uiMsg["expires_at"] = expiresAt.Format(time.RFC3339Nano)
+		}
+		profileMu.Lock()
+		if p, ok := peers[raddr.PubKey]; ok {
+			uiMsg["sender_name"] = p.Name
+			if p.AvatarRef != "" {
+				uiMsg["sender_avatar"] = p.AvatarRef
+			}
+		}
+		profileMu.Unlock()
+		clientMsg, err := json.Marshal(uiMsg)
 		if err != nil {
 			print(fmt.Sprintf("Failed to marshal json: %v\n", err))
 		}
-		select {
-		case clientCh <- string(clientMsg):
+		if publish(eventMessage, string(clientMsg)) > 0 {
 			fmt.Printf("Received and sent to ui: %s\n", clientMsg)
-		default:
+		} else {
 			fmt.Printf("Received and trashed: %s\n", clientMsg)
 		}
 	}
 }
 
+// subscribe returns a channel receiving UI events of the given types and a function
+// unsubscribing from them, which closes the channel.
+func subscribe(types ...eventType) (<-chan uiEvent, func()) {
+	sub := &subscriber{ch: make(chan uiEvent, subscriberBuffer), types: make(map[eventType]bool, len(types))}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+	subsMu.Lock()
+	subs[sub] = struct{}{}
+	subsMu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			subsMu.Lock()
+			defer subsMu.Unlock()
+			if _, ok := subs[sub]; ok {
+				delete(subs, sub)
+				close(sub.ch)
+			}
+		})
+	}
+}
+
+// publish delivers the event to subscribers of its type and returns the number
+// of subscribers it was delivered to. Subscribers with full buffers miss the event.
+func publish(typ eventType, data string) int {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	delivered := 0
+	for sub := range subs {
+		if !sub.types[typ] {
+			continue
+		}
+		select {
+		case sub.ch <- uiEvent{typ: typ, data: data}:
+			delivered++
+		default:
+		}
+	}
+	return delivered
+}
+
+// publishJSON publishes the event with JSON encoded v as data.
+func publishJSON(typ eventType, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		print(fmt.Sprintf("Failed to marshal json: %v\n", err))
+		return
+	}
+	publish(typ, string(b))
+}
+
+// closeSubscribers unsubscribes all the subscribers, closing their channels.
+func closeSubscribers() {
+	subsMu.Lock()
+	defer subsMu.Unlock()
+	for sub := range subs {
+		delete(subs, sub)
+		close(sub.ch)
+	}
+}
+
+// parseEventTypes parses comma-separated event types.
+func parseEventTypes(s string) ([]eventType, error) {
+	var types []eventType
+	for _, name := range strings.Split(s, ",") {
+		switch t := eventType(strings.TrimSpace(name)); t {
+		case eventMessage, eventProfile, eventGoodbye, eventExpire:
+			types = append(types, t)
+		default:
+			return nil, fmt.Errorf("%w: %q", errEventType, name)
+		}
+	}
+	return types, nil
+}
+
+// isConnClosed reports whether err means the connection was closed rather
+// than failed: either the peer hung up or the conn was closed locally.
+func isConnClosed(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// writeFull writes the whole b to conn, retrying on short writes.
+func writeFull(conn net.Conn, b []byte) error {
+	for len(b) > 0 {
+		n, err := conn.Write(b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrShortWrite
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
 func messageHandler(ctx context.Context) func(w http.ResponseWriter, rreq *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
 
-		data := map[string]string{}
-		if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		pk, msg, ttl, err := decodeMessage(req.Body)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		pk := cipher.PubKey{}
-		if err := pk.UnmarshalText([]byte(data["recipient"])); err != nil {
+		if ttl > 0 {
+			id, expiresAt, err := sendEphemeral(ctx, pk, string(msg), ttl)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			resp := map[string]string{"id": id, "expires_at": expiresAt.Format(time.RFC3339Nano)}
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				print(fmt.Sprintf("Failed to write message id: %v\n", err))
+			}
+			return
+		}
+
+		conn, err := ensureConn(ctx, pk)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		addr := appnet.Addr{
-			Net:    netType,
-			PubKey: pk,
-			Port:   1,
+		if err := writeFull(conn, msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			closeConn(pk, conn)
+			return
 		}
-		connsMu.Lock()
-		conn, ok := conns[pk]
-		connsMu.Unlock()
+	}
+}
 
-		if !ok {
-			var err error
-			err = r.Do(ctx, func() error {
-				conn, err = appCl.Dial(addr)
-				return err
-			})
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
+// decodeMessage decodes a message to send from the JSON body of a message request.
+// It fails unless the body has a recipient pk and a non-empty message of at most maxMessageSize bytes,
+// which does not start with NUL reserved for control frames. At most maxMessageRequestSize bytes
+// of the body are read, so that oversized messages are rejected without being buffered whole.
+// Optional ttl makes the message ephemeral, it is a duration such as "30s" or "1h".
+func decodeMessage(r io.Reader) (cipher.PubKey, []byte, time.Duration, error) {
+	var data struct {
+		Recipient cipher.PubKey `json:"recipient"`
+		Message   string        `json:"message"`
+		TTL       string        `json:"ttl"`
+	}
+	body := &io.LimitedReader{R: r, N: maxMessageRequestSize}
+	if err := json.NewDecoder(body).Decode(&data); err != nil {
+		if body.N == 0 {
+			return cipher.PubKey{}, nil, 0, errMessageTooLong
+		}
+		return cipher.PubKey{}, nil, 0, err
+	}
+	switch {
+	case data.Recipient.Null():
+		return cipher.PubKey{}, nil, 0, errNoRecipient
+	case data.Message == "":
+		return cipher.PubKey{}, nil, 0, errEmptyMessage
+	case len(data.Message) > maxMessageSize:
+		return cipher.PubKey{}, nil, 0, errMessageTooLong
+	case data.Message[0] == 0:
+		return cipher.PubKey{}, nil, 0, errControlMessage
+	}
+	var ttl time.Duration
+	if data.TTL != "" {
+		var err error
+		if ttl, err = parseTTL(data.TTL); err != nil {
+			return cipher.PubKey{}, nil, 0, err
+		}
+		if len(data.Message) > maxEphemeralMessageSize {
+			return cipher.PubKey{}, nil, 0, errEphemeralLong
+		}
+	}
+	return data.Recipient, []byte(data.Message), ttl, nil
+}
 
-			connsMu.Lock()
-			conns[pk] = conn
-			connsMu.Unlock()
+// parseTTL parses the TTL of an ephemeral message, which is sent in whole milliseconds.
+func parseTTL(s string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl < time.Millisecond {
+		return 0, errInvalidTTL
+	}
+	return ttl, nil
+}
 
-			go handleConn(conn)
-		}
+// sendEphemeral sends text to the visor with the given pk as a message expiring after ttl.
+// It returns the id of the message and the time it expires at, when the UIs of both sides
+// are told to delete it.
+func sendEphemeral(ctx context.Context, pk cipher.PubKey, text string, ttl time.Duration) (string, time.Time, error) {
+	switch {
+	case ttl < time.Millisecond:
+		return "", time.Time{}, errInvalidTTL
+	case text == "":
+		return "", time.Time{}, errEmptyMessage
+	case len(text) > maxEphemeralMessageSize:
+		return "", time.Time{}, errEphemeralLong
+	}
 
-		_, err := conn.Write([]byte(data["message"]))
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	idBytes := make([]byte, ephemeralIDSize)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", time.Time{}, fmt.Errorf("generate message id: %w", err)
+	}
+	frame := make([]byte, 0, len(ephemeralFrame)+ephemeralHeaderSize+len(text))
+	frame = append(frame, ephemeralFrame...)
+	frame = append(frame, idBytes...)
+	frame = binary.BigEndian.AppendUint64(frame, uint64(ttl.Milliseconds()))
+	frame = append(frame, text...)
+
+	conn, err := ensureConn(ctx, pk)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !isFramed(conn) {
+		return "", time.Time{}, errPlainConn
+	}
+	if err := writeFull(conn, frame); err != nil {
+		closeConn(pk, conn)
+		return "", time.Time{}, err
+	}
+
+	id := hex.EncodeToString(idBytes)
+	expiresAt := time.Now().Add(ttl)
+	trackExpiry(pk, id, expiresAt)
+	return id, expiresAt, nil
+}
 
-			connsMu.Lock()
-			delete(conns, pk)
-			connsMu.Unlock()
+// parseEphemeral parses the ephemeral message frame, returning the id, TTL and text of the message.
+func parseEphemeral(frame []byte) (string, time.Duration, []byte, error) {
+	b := frame[len(ephemeralFrame):]
+	if len(b) <= ephemeralHeaderSize {
+		return "", 0, nil, errEphemeralFrame
+	}
+	ms := binary.BigEndian.Uint64(b[ephemeralIDSize:ephemeralHeaderSize])
+	if ms == 0 || ms > uint64(math.MaxInt64/int64(time.Millisecond)) {
+		return "", 0, nil, errEphemeralFrame
+	}
+	return hex.EncodeToString(b[:ephemeralIDSize]), time.Duration(ms) * time.Millisecond, b[ephemeralHeaderSize:], nil
+}
+
+// trackExpiry records the time the message of the conversation with peer expires at.
+func trackExpiry(peer cipher.PubKey, id string, expiresAt time.Time) {
+	expiryMu.Lock()
+	expiries[expiryKey{peer: peer, id: id}] = expiresAt
+	expiryMu.Unlock()
+}
+
+// sweepExpired forgets the messages expired at now and tells the UI to delete them.
+// It returns the number of swept messages.
+func sweepExpired(now time.Time) int {
+	var expired []expiryKey
+	expiryMu.Lock()
+	for key, expiresAt := range expiries {
+		if !now.Before(expiresAt) {
+			expired = append(expired, key)
+			delete(expiries, key)
+		}
+	}
+	expiryMu.Unlock()
+
+	for _, key := range expired {
+		publishJSON(eventExpire, map[string]string{"peer": key.peer.Hex(), "id": key.id})
+	}
+	return len(expired)
+}
 
+// sweepLoop sweeps expired messages every interval until ctx is done.
+func sweepLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			sweepExpired(now)
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// ensureConn returns the conn to the visor with the given pk, dialing it if there is none.
+func ensureConn(ctx context.Context, pk cipher.PubKey) (net.Conn, error) {
+	connsMu.Lock()
+	conn, ok := conns[pk]
+	connsMu.Unlock()
+
+	if ok {
+		return conn, nil
+	}
+	return dialOnce(ctx, pk)
+}
+
+// dialOnce dials the visor with the given pk and starts handling the resulting conn.
+// Concurrent calls for the same pk share a single dial and get the same conn,
+// callers waiting for the dial of another call stop waiting once their ctx is done.
+func dialOnce(ctx context.Context, pk cipher.PubKey) (net.Conn, error) {
+	dialsMu.Lock()
+	if d, ok := dials[pk]; ok {
+		dialsMu.Unlock()
+		select {
+		case <-d.done:
+			return d.conn, d.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	d := &pendingDial{done: make(chan struct{})}
+	dials[pk] = d
+	dialsMu.Unlock()
+
+	d.err = r.Do(ctx, func() error {
+		var err error
+		d.conn, err = dialChat(pk)
+		return err
+	})
+	if d.err == nil {
+		if kept := addConn(pk, d.conn); kept != d.conn {
+			// remote visor dialed us at the same time and its conn won
+			d.conn = kept
+		} else if d.err = startHandling(pk, d.conn); d.err != nil {
+			d.conn = nil
+		} else if err := sendProfile(d.conn); err != nil {
+			print(fmt.Sprintf("Failed to send profile to %s: %v\n", pk, err))
+		}
+	}
+
+	dialsMu.Lock()
+	delete(dials, pk)
+	dialsMu.Unlock()
+	close(d.done)
+
+	return d.conn, d.err
+}
+
+// dialChat dials the visor with the given pk on framedPort, falling back to port
+// for visors of older releases, which do not listen on it.
+func dialChat(pk cipher.PubKey) (net.Conn, error) {
+	conn, err := dial(appnet.Addr{Net: netType, PubKey: pk, Port: framedPort})
+	if err == nil {
+		return wrapConn(conn), nil
+	}
+	conn, plainErr := dial(appnet.Addr{Net: netType, PubKey: pk, Port: port})
+	if plainErr != nil {
+		return nil, errors.Join(err, plainErr)
+	}
+	return conn, nil
+}
+
+// sseHandler streams UI events of the types given by the comma-separated types query
+// parameter, text messages by default. Messages are sent as unnamed events, other
+// events are named after their type.
 func sseHandler(w http.ResponseWriter, req *http.Request) {
 	f, ok := w.(http.Flusher)
 	if !ok {
@@ -266,6 +1051,17 @@ func sseHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	types := []eventType{eventMessage}
+	if q := req.URL.Query().Get("types"); q != "" {
+		var err error
+		if types, err = parseEventTypes(q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	events, unsubscribe := subscribe(types...)
+	defer unsubscribe()
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -273,11 +1069,14 @@ func sseHandler(w http.ResponseWriter, req *http.Request) {
 
 	for {
 		select {
-		case msg, ok := <-clientCh:
+		case ev, ok := <-events:
 			if !ok {
 				return
 			}
-			_, _ = fmt.Fprintf(w, "data: %s\n\n", msg)
+			if ev.typ != eventMessage {
+				_, _ = fmt.Fprintf(w, "event: %s\n", ev.typ)
+			}
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", ev.data)
 			f.Flush()
 
 		case <-req.Context().Done():
@@ -295,6 +1094,148 @@ func getFileSystem() http.FileSystem {
 	return http.FS(fsys)
 }
 
+// getStatus returns current health status of the app.
+func getStatus() appStatus {
+	connsMu.Lock()
+	n := len(conns)
+	connsMu.Unlock()
+
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	s := appStatus{
+		Listening: listening,
+		Network:   netType,
+		Port:      lisPort,
+		Conns:     n,
+	}
+	if lastErr != nil {
+		s.LastError = lastErr.Error()
+	}
+	return s
+}
+
+func setListening(v bool) {
+	statusMu.Lock()
+	listening = v
+	statusMu.Unlock()
+}
+
+func setListenPort(p routing.Port) {
+	statusMu.Lock()
+	lisPort = p
+	statusMu.Unlock()
+}
+
+func setLastError(err error) {
+	statusMu.Lock()
+	lastErr = err
+	statusMu.Unlock()
+}
+
+// getProfile returns the profile of the local user.
+func getProfile() profile {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	return local
+}
+
+// setProfile sets the profile of the local user and sends it to all connected visors.
+func setProfile(p profile) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	profileMu.Lock()
+	local = p
+	profileMu.Unlock()
+
+	connsMu.Lock()
+	cs := make(map[cipher.PubKey]net.Conn, len(conns))
+	for pk, conn := range conns {
+		cs[pk] = conn
+	}
+	connsMu.Unlock()
+
+	for pk, conn := range cs {
+		if err := sendProfile(conn); err != nil {
+			print(fmt.Sprintf("Failed to send profile to %s: %v\n", pk, err))
+		}
+	}
+	return nil
+}
+
+// sendProfile sends the profile of the local user over conn, unless it is not set
+// or conn is not framed.
+func sendProfile(conn net.Conn) error {
+	p := getProfile()
+	if p.Name == "" || !isFramed(conn) {
+		return nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return writeFull(conn, append(append([]byte{}, profileFrame...), b...))
+}
+
+// profileHandler serves the profile of the local user on GET and sets it on POST.
+func profileHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(getProfile()); err != nil {
+			print(fmt.Sprintf("Failed to write profile: %v\n", err))
+		}
+	case http.MethodPost, http.MethodPut:
+		var p profile
+		if err := json.NewDecoder(io.LimitReader(req.Body, maxMessageSize)).Decode(&p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := setProfile(p); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// conversationHandler deletes the conversation with the visor given by the pk query parameter.
+func conversationHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodDelete {
+		w.Header().Set("Allow", "DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var pk cipher.PubKey
+	if err := pk.Set(req.URL.Query().Get("pk")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := deleteP2P(pk); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errNoConversation) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// healthHandler serves app status, responding with 503 if skychat conns are not accepted.
+func healthHandler(w http.ResponseWriter, _ *http.Request) {
+	s := getStatus()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !s.Listening {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		print(fmt.Sprintf("Failed to write health status: %v\n", err))
+	}
+}
+
 func handleIPCSignal(client *ipc.Client) {
 	time.Sleep(5 * time.Second)
 	if client == nil {
@@ -325,6 +1266,7 @@ func setAppStatus(appCl *app.Client, status appserver.AppDetailedStatus) {
 }
 
 func setAppError(appCl *app.Client, appErr error) {
+	setLastError(appErr)
 	if err := appCl.SetError(appErr.Error()); err != nil {
 		print(fmt.Sprintf("Failed to set error %v: %v\n", appErr, err))
 	}
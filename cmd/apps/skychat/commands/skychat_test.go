@@ -0,0 +1,1135 @@
+// Package commands cmd/apps/skychat/commands/skychat_test.go
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire/pkg/app/appnet"
+	"github.com/skycoin/skywire/pkg/routing"
+)
+
+func TestIsConnClosed(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "EOF", err: io.EOF, want: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "closed pipe", err: io.ErrClosedPipe, want: true},
+		{name: "closed network conn", err: net.ErrClosed, want: true},
+		{name: "wrapped EOF", err: fmt.Errorf("read packet: %w", io.EOF), want: true},
+		{name: "op error on closed conn", err: &net.OpError{Op: "read", Net: "tcp", Err: net.ErrClosed}, want: true},
+		{name: "generic error", err: errors.New("connection reset by peer"), want: false},
+		{name: "timeout", err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("i/o timeout")}, want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, isConnClosed(tc.err))
+		})
+	}
+}
+
+// pipeConn is a net.Pipe end reporting skychat addresses of the visors.
+type pipeConn struct {
+	net.Conn
+	laddr appnet.Addr
+	raddr appnet.Addr
+}
+
+func (c *pipeConn) LocalAddr() net.Addr {
+	return c.laddr
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+// framedPipe returns a framed conn to the visor with the given pk and the remote
+// end of it, which reads and writes frames.
+func framedPipe(pk cipher.PubKey) (net.Conn, *framedConn) {
+	local, remote := net.Pipe()
+	conn := wrapConn(&pipeConn{Conn: local, raddr: appnet.Addr{PubKey: pk, Port: framedPort}})
+	return conn, &framedConn{Conn: remote, r: bufio.NewReader(remote)}
+}
+
+func TestMessageHandler_ConcurrentSendsShareDial(t *testing.T) {
+	const sends = 50
+	const text = "hello"
+
+	resetConns(t, 1)
+	pk, _ := cipher.GenerateKeyPair()
+
+	local, remote := net.Pipe()
+	defer remote.Close() //nolint:errcheck
+
+	origDial := dial
+	defer func() { dial = origDial }()
+
+	var dialCount int32
+	dialStarted := make(chan struct{})
+	dial = func(addr appnet.Addr) (net.Conn, error) {
+		atomic.AddInt32(&dialCount, 1)
+		close(dialStarted)
+		// give other senders time to pile up on the in-flight dial
+		time.Sleep(100 * time.Millisecond)
+		return &pipeConn{Conn: local, raddr: addr}, nil
+	}
+
+	received := make(chan int)
+	go func() {
+		total := 0
+		fr := &framedConn{Conn: remote, r: bufio.NewReader(remote)}
+		for total < sends*len(text) {
+			frame, err := fr.readFrame()
+			if err != nil {
+				break
+			}
+			total += len(frame)
+		}
+		received <- total
+	}()
+
+	body, err := json.Marshal(map[string]string{"recipient": pk.Hex(), "message": text})
+	require.NoError(t, err)
+
+	handler := messageHandler(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < sends; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodPost, "/message", bytes.NewReader(body)))
+			assert.Equal(t, http.StatusOK, w.Code)
+		}()
+		if i == 0 {
+			<-dialStarted
+		}
+	}
+	wg.Wait()
+
+	require.EqualValues(t, 1, atomic.LoadInt32(&dialCount))
+	require.Equal(t, sends*len(text), <-received)
+}
+
+func TestValidateMaxConns(t *testing.T) {
+	require.NoError(t, validateMaxConns(1))
+	require.ErrorIs(t, validateMaxConns(0), errMaxConns)
+	require.ErrorIs(t, validateMaxConns(-1), errMaxConns)
+}
+
+func TestStartHandling_LimitsConns(t *testing.T) {
+	const limit = 2
+	resetConns(t, limit)
+
+	var remotes []net.Conn
+	for i := 0; i < limit; i++ {
+		pk, _ := cipher.GenerateKeyPair()
+		local, remote := net.Pipe()
+		remotes = append(remotes, remote)
+		require.NoError(t, startHandling(pk, &pipeConn{Conn: local, raddr: appnet.Addr{PubKey: pk}}))
+	}
+
+	pk, _ := cipher.GenerateKeyPair()
+	local, remote := net.Pipe()
+	defer remote.Close() //nolint:errcheck
+	require.ErrorIs(t, startHandling(pk, &pipeConn{Conn: local, raddr: appnet.Addr{PubKey: pk}}), errTooManyConns)
+
+	// rejected conn is closed
+	_, err := remote.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.EOF)
+
+	// once a handled conn goes away, its slot is freed
+	require.NoError(t, remotes[0].Close())
+	require.Eventually(t, func() bool { return len(connSlots) < limit }, time.Second, 10*time.Millisecond)
+
+	local, remote = net.Pipe()
+	remotes[0] = remote
+	require.NoError(t, startHandling(pk, &pipeConn{Conn: local, raddr: appnet.Addr{PubKey: pk}}))
+
+	for _, remote := range remotes {
+		require.NoError(t, remote.Close())
+	}
+}
+
+// resetConns resets the package level connection state for a test.
+func resetConns(t *testing.T, limit int) {
+	connsMu.Lock()
+	conns = make(map[cipher.PubKey]net.Conn)
+	connsMu.Unlock()
+	connSlots = make(chan struct{}, limit)
+	t.Cleanup(func() {
+		require.Eventually(t, func() bool { return len(connSlots) == 0 }, time.Second, 10*time.Millisecond)
+	})
+}
+
+// shortWriteConn accepts at most max bytes per Write.
+type shortWriteConn struct {
+	net.Conn
+	max    int
+	buf    bytes.Buffer
+	writes int
+}
+
+func (c *shortWriteConn) Write(b []byte) (int, error) {
+	c.writes++
+	if len(b) > c.max {
+		b = b[:c.max]
+	}
+	return c.buf.Write(b)
+}
+
+func TestWriteFull(t *testing.T) {
+	msg := bytes.Repeat([]byte("skychat "), 100)
+
+	t.Run("short writes", func(t *testing.T) {
+		conn := &shortWriteConn{max: 7}
+		require.NoError(t, writeFull(conn, msg))
+		require.Equal(t, msg, conn.buf.Bytes())
+		require.Equal(t, (len(msg)+6)/7, conn.writes)
+	})
+
+	t.Run("no progress", func(t *testing.T) {
+		conn := &shortWriteConn{max: 0}
+		require.ErrorIs(t, writeFull(conn, msg), io.ErrShortWrite)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		require.NoError(t, c2.Close())
+		require.Error(t, writeFull(c1, msg))
+		require.NoError(t, c1.Close())
+	})
+}
+
+func TestAddConn_SimultaneousConnect(t *testing.T) {
+	pkA, _ := cipher.GenerateKeyPair()
+	pkB, _ := cipher.GenerateKeyPair()
+	if !lowerPK(pkA, pkB) {
+		pkA, pkB = pkB, pkA
+	}
+
+	// connPair returns both ends of a skychat conn dialed from one visor to another
+	connPair := func(from, to cipher.PubKey) (dialed, accepted *pipeConn) {
+		c1, c2 := net.Pipe()
+		fromAddr := appnet.Addr{Net: netType, PubKey: from, Port: 49153}
+		toAddr := appnet.Addr{Net: netType, PubKey: to, Port: port}
+		return &pipeConn{Conn: c1, laddr: fromAddr, raddr: toAddr},
+			&pipeConn{Conn: c2, laddr: toAddr, raddr: fromAddr}
+	}
+
+	// isClosed reports whether conn was closed on either end,
+	// open conns just time out as nobody reads from the other end
+	isClosed := func(conn net.Conn) bool {
+		if err := conn.SetWriteDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+			return errors.Is(err, io.ErrClosedPipe)
+		}
+		_, err := conn.Write([]byte{0})
+		return errors.Is(err, io.ErrClosedPipe)
+	}
+
+	for _, dialedFirst := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dialed first %v", dialedFirst), func(t *testing.T) {
+			// A and B dial each other at once, each side sees one dialed and one accepted conn
+			aDialed, bAccepted := connPair(pkA, pkB)
+			bDialed, aAccepted := connPair(pkB, pkA)
+
+			sides := []struct {
+				local    cipher.PubKey
+				remote   cipher.PubKey
+				dialed   net.Conn
+				accepted net.Conn
+			}{
+				{local: pkA, remote: pkB, dialed: aDialed, accepted: aAccepted},
+				{local: pkB, remote: pkA, dialed: bDialed, accepted: bAccepted},
+			}
+
+			var kept []net.Conn
+			for _, side := range sides {
+				resetConns(t, 1)
+				first, second := side.accepted, side.dialed
+				if dialedFirst {
+					first, second = second, first
+				}
+				require.Equal(t, first, addConn(side.remote, first))
+				k := addConn(side.remote, second)
+
+				connsMu.Lock()
+				require.Len(t, conns, 1)
+				require.Equal(t, k, conns[side.remote])
+				connsMu.Unlock()
+				kept = append(kept, k)
+			}
+
+			// both sides keep the conn dialed by A, which has the lower pk
+			require.Equal(t, aDialed, kept[0])
+			require.Equal(t, bAccepted, kept[1])
+			require.True(t, isClosed(bDialed))
+			require.True(t, isClosed(aAccepted))
+			require.False(t, isClosed(aDialed))
+
+			require.NoError(t, aDialed.Close())
+		})
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	resetConns(t, 1)
+	setListening(false)
+	setListenPort(0)
+	setLastError(nil)
+	t.Cleanup(func() {
+		setListening(false)
+		setListenPort(0)
+		setLastError(nil)
+	})
+
+	getHealth := func() (int, appStatus) {
+		w := httptest.NewRecorder()
+		healthHandler(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var s appStatus
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&s))
+		return w.Code, s
+	}
+
+	code, s := getHealth()
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, appStatus{Network: netType}, s)
+
+	// the port granted by the visor is reported
+	setListenPort(port + 1)
+	setListening(true)
+	pk, _ := cipher.GenerateKeyPair()
+	local, remote := net.Pipe()
+	addConn(pk, &pipeConn{Conn: local, raddr: appnet.Addr{PubKey: pk}})
+
+	code, s = getHealth()
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, s.Listening)
+	require.Equal(t, port+1, s.Port)
+	require.Equal(t, 1, s.Conns)
+	require.Empty(t, s.LastError)
+
+	setListening(false)
+	setLastError(errors.New("listener closed"))
+
+	code, s = getHealth()
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.False(t, s.Listening)
+	require.Equal(t, "listener closed", s.LastError)
+
+	require.NoError(t, local.Close())
+	require.NoError(t, remote.Close())
+}
+
+// failingListener fails to accept conns.
+type failingListener struct {
+	acceptErr error
+	closeErr  error
+}
+
+func (l *failingListener) Accept() (net.Conn, error) { return nil, l.acceptErr }
+func (l *failingListener) Close() error              { return l.closeErr }
+func (l *failingListener) Addr() net.Addr            { return appnet.Addr{Net: netType, Port: port} }
+
+// failingCloseConn fails to close.
+type failingCloseConn struct {
+	net.Conn
+	err error
+}
+
+func (c *failingCloseConn) Close() error {
+	c.Conn.Close() //nolint:errcheck
+	return c.err
+}
+
+func TestServeConns_JoinsTeardownErrors(t *testing.T) {
+	resetConns(t, 3)
+	t.Cleanup(func() {
+		setListening(false)
+		setListenPort(0)
+		setLastError(nil)
+	})
+
+	acceptErr := errors.New("accept failed")
+	closeErrs := []error{errors.New("close failed 1"), errors.New("close failed 2")}
+
+	var remotes []net.Conn
+	addHandled := func(closeErr error) {
+		pk, _ := cipher.GenerateKeyPair()
+		local, remote := net.Pipe()
+		remotes = append(remotes, remote)
+		var conn net.Conn = &pipeConn{Conn: local, raddr: appnet.Addr{PubKey: pk}}
+		if closeErr != nil {
+			conn = &failingCloseConn{Conn: conn, err: closeErr}
+		}
+		addConn(pk, conn)
+		require.NoError(t, startHandling(pk, conn))
+	}
+	for _, err := range closeErrs {
+		addHandled(err)
+	}
+	addHandled(nil)
+
+	err := serveConns(&failingListener{acceptErr: acceptErr, closeErr: net.ErrClosed})
+	require.ErrorIs(t, err, acceptErr)
+	for _, closeErr := range closeErrs {
+		require.ErrorIs(t, err, closeErr)
+	}
+	require.NotErrorIs(t, err, net.ErrClosed)
+
+	require.False(t, getStatus().Listening)
+	require.Equal(t, port, getStatus().Port)
+	require.Zero(t, getStatus().Conns)
+
+	// all conns are closed and their handlers stopped
+	for _, remote := range remotes {
+		_, err := remote.Read(make([]byte, 1))
+		require.ErrorIs(t, err, io.EOF)
+	}
+}
+
+func TestEnsureConn(t *testing.T) {
+	origDial := dial
+	defer func() { dial = origDial }()
+
+	var dialCount int32
+	var dialedRemote net.Conn
+	dial = func(addr appnet.Addr) (net.Conn, error) {
+		atomic.AddInt32(&dialCount, 1)
+		local, remote := net.Pipe()
+		dialedRemote = remote
+		return &pipeConn{Conn: local, raddr: addr}, nil
+	}
+
+	t.Run("cached conn", func(t *testing.T) {
+		resetConns(t, 1)
+		atomic.StoreInt32(&dialCount, 0)
+
+		pk, _ := cipher.GenerateKeyPair()
+		local, remote := net.Pipe()
+		defer remote.Close() //nolint:errcheck
+		cached := &pipeConn{Conn: local, raddr: appnet.Addr{PubKey: pk}}
+		addConn(pk, cached)
+
+		conn, err := ensureConn(context.Background(), pk)
+		require.NoError(t, err)
+		require.Equal(t, net.Conn(cached), conn)
+		require.Zero(t, atomic.LoadInt32(&dialCount))
+		require.Zero(t, len(connSlots))
+	})
+
+	t.Run("dial on miss", func(t *testing.T) {
+		resetConns(t, 1)
+		atomic.StoreInt32(&dialCount, 0)
+
+		pk, _ := cipher.GenerateKeyPair()
+		conn, err := ensureConn(context.Background(), pk)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, atomic.LoadInt32(&dialCount))
+		require.Equal(t, pk, conn.RemoteAddr().(appnet.Addr).PubKey)
+
+		// dialed conn is stored and handled
+		connsMu.Lock()
+		require.Equal(t, conn, conns[pk])
+		connsMu.Unlock()
+		require.Equal(t, 1, len(connSlots))
+
+		again, err := ensureConn(context.Background(), pk)
+		require.NoError(t, err)
+		require.Equal(t, conn, again)
+		require.EqualValues(t, 1, atomic.LoadInt32(&dialCount))
+
+		require.NoError(t, dialedRemote.Close())
+	})
+}
+
+// failingWriteConn fails writes and records whether it was closed.
+type failingWriteConn struct {
+	pipeConn
+	closed atomic.Bool
+}
+
+func (c *failingWriteConn) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func (c *failingWriteConn) Close() error {
+	c.closed.Store(true)
+	return c.pipeConn.Close()
+}
+
+func TestMessageHandler_ClosesFailedConn(t *testing.T) {
+	resetConns(t, 1)
+	pk, _ := cipher.GenerateKeyPair()
+
+	local, remote := net.Pipe()
+	defer remote.Close() //nolint:errcheck
+	conn := &failingWriteConn{pipeConn: pipeConn{Conn: local, raddr: appnet.Addr{PubKey: pk}}}
+	addConn(pk, conn)
+
+	body, err := json.Marshal(map[string]string{"recipient": pk.Hex(), "message": "hello"})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	messageHandler(context.Background())(w, httptest.NewRequest(http.MethodPost, "/message", bytes.NewReader(body)))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	require.True(t, conn.closed.Load())
+	connsMu.Lock()
+	_, ok := conns[pk]
+	connsMu.Unlock()
+	require.False(t, ok)
+}
+
+func TestDialOnce_WaiterCanceled(t *testing.T) {
+	resetConns(t, 1)
+	pk, _ := cipher.GenerateKeyPair()
+
+	origDial := dial
+	defer func() { dial = origDial }()
+
+	dialStarted, release := make(chan struct{}), make(chan struct{})
+	local, remote := net.Pipe()
+	defer remote.Close() //nolint:errcheck
+	dial = func(addr appnet.Addr) (net.Conn, error) {
+		close(dialStarted)
+		<-release
+		return &pipeConn{Conn: local, raddr: addr}, nil
+	}
+
+	dialDone := make(chan error, 1)
+	go func() {
+		_, err := dialOnce(context.Background(), pk)
+		dialDone <- err
+	}()
+	<-dialStarted
+
+	// waiter of the shared dial returns as soon as its request is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := dialOnce(ctx, pk)
+	require.ErrorIs(t, err, context.Canceled)
+
+	close(release)
+	require.NoError(t, <-dialDone)
+}
+
+func TestGoodbye(t *testing.T) {
+	t.Run("peer reaps conn on goodbye", func(t *testing.T) {
+		resetConns(t, 1)
+		pk, _ := cipher.GenerateKeyPair()
+		conn, remote := framedPipe(pk)
+		require.Equal(t, conn, addConn(pk, conn))
+		require.NoError(t, startHandling(pk, conn))
+
+		require.NoError(t, writeFull(remote, goodbyeFrame))
+		require.Eventually(t, func() bool {
+			connsMu.Lock()
+			defer connsMu.Unlock()
+			_, ok := conns[pk]
+			return !ok
+		}, 100*time.Millisecond, time.Millisecond)
+
+		_, err := remote.Read(make([]byte, 1))
+		require.ErrorIs(t, err, io.EOF)
+	})
+
+	t.Run("disconnect sends goodbye", func(t *testing.T) {
+		resetConns(t, 1)
+		pk, _ := cipher.GenerateKeyPair()
+		conn, remote := framedPipe(pk)
+		require.Equal(t, conn, addConn(pk, conn))
+
+		received := make(chan []byte, 1)
+		go func() {
+			frame, _ := remote.readFrame() //nolint:errcheck
+			received <- frame
+		}()
+		require.NoError(t, disconnect(pk))
+		require.Equal(t, goodbyeFrame, <-received)
+		require.Zero(t, getStatus().Conns)
+
+		_, err := remote.Read(make([]byte, 1))
+		require.ErrorIs(t, err, io.EOF)
+		require.ErrorIs(t, disconnect(pk), errNotConnected)
+	})
+
+	t.Run("plain conn is closed without goodbye", func(t *testing.T) {
+		resetConns(t, 1)
+		pk, _ := cipher.GenerateKeyPair()
+		local, remote := net.Pipe()
+		conn := &pipeConn{Conn: local, raddr: appnet.Addr{PubKey: pk, Port: port}}
+		require.Equal(t, net.Conn(conn), addConn(pk, conn))
+
+		require.NoError(t, disconnect(pk))
+		_, err := remote.Read(make([]byte, 1))
+		require.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestDecodeMessage(t *testing.T) {
+	pk, _ := cipher.GenerateKeyPair()
+
+	tests := []struct {
+		name    string
+		body    string
+		wantMsg string
+		wantTTL time.Duration
+		wantErr error
+	}{
+		{name: "valid", body: fmt.Sprintf(`{"recipient":%q,"message":"hi"}`, pk.Hex()), wantMsg: "hi"},
+		{name: "missing recipient", body: `{"message":"hi"}`, wantErr: errNoRecipient},
+		{name: "null recipient", body: `{"recipient":"000","message":"hi"}`, wantErr: errNoRecipient},
+		{name: "empty message", body: fmt.Sprintf(`{"recipient":%q}`, pk.Hex()), wantErr: errEmptyMessage},
+		{name: "control message", body: fmt.Sprintf(`{"recipient":%q,"message":"\u0000goodbye"}`, pk.Hex()), wantErr: errControlMessage},
+		{
+			name:    "max length escaped message",
+			body:    fmt.Sprintf(`{"recipient":%q,"message":"%s"}`, pk.Hex(), strings.Repeat(`\u00e9`, maxMessageSize/2)),
+			wantMsg: strings.Repeat("é", maxMessageSize/2),
+		},
+		{
+			name:    "too long message",
+			body:    fmt.Sprintf(`{"recipient":%q,"message":%q}`, pk.Hex(), strings.Repeat("a", maxMessageSize+1)),
+			wantErr: errMessageTooLong,
+		},
+		{
+			name:    "too long body",
+			body:    fmt.Sprintf(`{"recipient":%q,"message":"%s"}`, pk.Hex(), strings.Repeat(`\u00e9`, maxMessageRequestSize)),
+			wantErr: errMessageTooLong,
+		},
+		{name: "ephemeral", body: fmt.Sprintf(`{"recipient":%q,"message":"hi","ttl":"30s"}`, pk.Hex()), wantMsg: "hi", wantTTL: 30 * time.Second},
+		{name: "negative ttl", body: fmt.Sprintf(`{"recipient":%q,"message":"hi","ttl":"-1s"}`, pk.Hex()), wantErr: errInvalidTTL},
+		{name: "malformed ttl", body: fmt.Sprintf(`{"recipient":%q,"message":"hi","ttl":"soon"}`, pk.Hex()), wantErr: errInvalidTTL},
+		{
+			name:    "too long ephemeral message",
+			body:    fmt.Sprintf(`{"recipient":%q,"message":%q,"ttl":"1m"}`, pk.Hex(), strings.Repeat("a", maxEphemeralMessageSize+1)),
+			wantErr: errEphemeralLong,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rPK, msg, ttl, err := decodeMessage(strings.NewReader(tc.body))
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, pk, rPK)
+			require.Equal(t, []byte(tc.wantMsg), msg)
+			require.Equal(t, tc.wantTTL, ttl)
+		})
+	}
+
+	for _, body := range []string{"", "null", "[]", `{"recipient":"zz","message":"hi"}`, `{"recipient":1}`} {
+		_, _, _, err := decodeMessage(strings.NewReader(body))
+		require.Error(t, err, body)
+	}
+}
+
+func FuzzDecodeMessage(f *testing.F) {
+	pk, _ := cipher.GenerateKeyPair()
+	f.Add(fmt.Sprintf(`{"recipient":%q,"message":"hi"}`, pk.Hex()))
+	f.Add(`{"recipient":"000","message":"hi"}`)
+	f.Add(`{"message":""}`)
+	f.Add(`null`)
+	f.Add(fmt.Sprintf(`{"recipient":%q,"message":"hi","ttl":"1h"}`, pk.Hex()))
+
+	f.Fuzz(func(t *testing.T, body string) {
+		rPK, msg, ttl, err := decodeMessage(strings.NewReader(body))
+		if err != nil {
+			return
+		}
+		require.False(t, rPK.Null())
+		require.NotEmpty(t, msg)
+		require.LessOrEqual(t, len(msg), maxMessageSize)
+		require.NotZero(t, msg[0])
+		if ttl != 0 {
+			require.GreaterOrEqual(t, ttl, time.Millisecond)
+			require.LessOrEqual(t, len(msg), maxEphemeralMessageSize)
+		}
+	})
+}
+
+// resetProfiles resets the local and peer profiles for a test.
+func resetProfiles(t *testing.T) {
+	set := func() {
+		profileMu.Lock()
+		local = profile{}
+		peers = make(map[cipher.PubKey]profile)
+		profileMu.Unlock()
+	}
+	set()
+	t.Cleanup(set)
+}
+
+func TestProfileHandler(t *testing.T) {
+	resetConns(t, 1)
+	resetProfiles(t)
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		profileHandler(w, httptest.NewRequest(method, "/profile", strings.NewReader(body)))
+		return w
+	}
+
+	w := do(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"name":""}`, w.Body.String())
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, `{"name":"alice","avatar_ref":"sha256:abc"}`).Code)
+	w = do(http.MethodGet, "")
+	require.JSONEq(t, `{"name":"alice","avatar_ref":"sha256:abc"}`, w.Body.String())
+
+	for _, body := range []string{
+		`{"name":""}`,
+		fmt.Sprintf(`{"name":%q}`, strings.Repeat("a", maxProfileNameLen+1)),
+		fmt.Sprintf(`{"name":"bob","avatar_ref":%q}`, strings.Repeat("a", maxProfileAvatarLen+1)),
+		`nope`,
+	} {
+		require.Equal(t, http.StatusBadRequest, do(http.MethodPost, body).Code, body)
+	}
+	require.Equal(t, profile{Name: "alice", AvatarRef: "sha256:abc"}, getProfile())
+	require.Equal(t, http.StatusMethodNotAllowed, do(http.MethodDelete, "").Code)
+}
+
+func TestProfile_AttachedToMessages(t *testing.T) {
+	resetConns(t, 2)
+	resetProfiles(t)
+	require.NoError(t, setProfile(profile{Name: "alice"}))
+
+	// sender: profile goes ahead of the first message to a visor
+	pk, _ := cipher.GenerateKeyPair()
+	local, remote := net.Pipe()
+	defer remote.Close() //nolint:errcheck
+
+	origDial := dial
+	defer func() { dial = origDial }()
+	dial = func(addr appnet.Addr) (net.Conn, error) {
+		return &pipeConn{Conn: local, raddr: addr}, nil
+	}
+
+	frames := make(chan string, 2)
+	go func() {
+		fr := &framedConn{Conn: remote, r: bufio.NewReader(remote)}
+		for {
+			frame, err := fr.readFrame()
+			if err != nil {
+				return
+			}
+			frames <- string(frame)
+		}
+	}()
+
+	body, err := json.Marshal(map[string]string{"recipient": pk.Hex(), "message": "hi"})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	messageHandler(context.Background())(w, httptest.NewRequest(http.MethodPost, "/message", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "\x00profile"+`{"name":"alice"}`, <-frames)
+	require.Equal(t, "hi", <-frames)
+
+	// receiver: messages are displayed with the name of the sender
+	events, unsubscribe := subscribe(eventMessage)
+	defer unsubscribe()
+
+	senderPK, _ := cipher.GenerateKeyPair()
+	rLocal, rRemote := framedPipe(senderPK)
+	defer rRemote.Close() //nolint:errcheck
+	require.NoError(t, startHandling(senderPK, rLocal))
+	require.NoError(t, writeFull(rRemote, []byte("\x00profile"+`{"name":"bob","avatar_ref":"sha256:abc"}`)))
+	require.NoError(t, writeFull(rRemote, []byte("hello")))
+	require.JSONEq(t,
+		fmt.Sprintf(`{"sender":%q,"sender_name":"bob","sender_avatar":"sha256:abc","message":"hello"}`, senderPK.Hex()),
+		(<-events).data)
+}
+
+func TestSubscribe_FiltersTypes(t *testing.T) {
+	resetConns(t, 1)
+	resetProfiles(t)
+
+	messages, unsubMessages := subscribe(eventMessage)
+	defer unsubMessages()
+	peerEvents, unsubPeerEvents := subscribe(eventProfile, eventGoodbye)
+	defer unsubPeerEvents()
+	all, unsubAll := subscribe(eventMessage, eventProfile, eventGoodbye)
+	defer unsubAll()
+
+	senderPK, _ := cipher.GenerateKeyPair()
+	local, remote := framedPipe(senderPK)
+	defer remote.Close() //nolint:errcheck
+	require.NoError(t, startHandling(senderPK, local))
+	require.NoError(t, writeFull(remote, []byte("\x00profile"+`{"name":"bob"}`)))
+	require.NoError(t, writeFull(remote, []byte("hello")))
+	require.NoError(t, writeFull(remote, goodbyeFrame))
+
+	next := func(events <-chan uiEvent) uiEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return uiEvent{}
+		}
+	}
+	sender := senderPK.Hex()
+	for _, ev := range []uiEvent{next(all), next(peerEvents)} {
+		require.Equal(t, eventProfile, ev.typ)
+		require.JSONEq(t, fmt.Sprintf(`{"sender":%q,"name":"bob","avatar_ref":""}`, sender), ev.data)
+	}
+	for _, ev := range []uiEvent{next(all), next(messages)} {
+		require.Equal(t, eventMessage, ev.typ)
+		require.JSONEq(t, fmt.Sprintf(`{"sender":%q,"sender_name":"bob","message":"hello"}`, sender), ev.data)
+	}
+	for _, ev := range []uiEvent{next(all), next(peerEvents)} {
+		require.Equal(t, eventGoodbye, ev.typ)
+		require.JSONEq(t, fmt.Sprintf(`{"sender":%q}`, sender), ev.data)
+	}
+	require.Empty(t, messages)
+	require.Empty(t, peerEvents)
+	require.Empty(t, all)
+
+	// unsubscribed channels are closed and no longer receive events
+	unsubMessages()
+	_, ok := <-messages
+	require.False(t, ok)
+	require.Equal(t, 1, publish(eventMessage, "{}"))
+}
+
+func TestSSEHandler_Types(t *testing.T) {
+	t.Run("unknown type", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		sseHandler(w, httptest.NewRequest(http.MethodGet, "/sse?types=message,typing", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("named events", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			sseHandler(w, httptest.NewRequest(http.MethodGet, "/sse?types=goodbye,message", nil).WithContext(ctx))
+		}()
+		require.Eventually(t, func() bool {
+			subsMu.Lock()
+			defer subsMu.Unlock()
+			return len(subs) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		publish(eventProfile, `{"name":"bob"}`)
+		publish(eventMessage, `{"message":"hi"}`)
+		publish(eventGoodbye, `{"sender":"pk"}`)
+		require.Eventually(t, func() bool {
+			subsMu.Lock()
+			defer subsMu.Unlock()
+			for sub := range subs {
+				return len(sub.ch) == 0
+			}
+			return false
+		}, 5*time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+		require.Equal(t, "data: {\"message\":\"hi\"}\n\nevent: goodbye\ndata: {\"sender\":\"pk\"}\n\n", w.Body.String())
+	})
+}
+
+func TestConversationHandler(t *testing.T) {
+	resetConns(t, 1)
+	resetProfiles(t)
+
+	del := func(pk string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		conversationHandler(w, httptest.NewRequest(http.MethodDelete, "/conversation?pk="+pk, nil))
+		return w
+	}
+
+	pk, _ := cipher.GenerateKeyPair()
+	conn, remote := framedPipe(pk)
+	require.Equal(t, conn, addConn(pk, conn))
+	profileMu.Lock()
+	peers[pk] = profile{Name: "bob"}
+	profileMu.Unlock()
+
+	received := make(chan []byte, 1)
+	go func() {
+		frame, _ := remote.readFrame() //nolint:errcheck
+		received <- frame
+	}()
+	require.Equal(t, http.StatusOK, del(pk.Hex()).Code)
+	require.Equal(t, goodbyeFrame, <-received)
+	require.Zero(t, getStatus().Conns)
+	profileMu.Lock()
+	require.NotContains(t, peers, pk)
+	profileMu.Unlock()
+
+	w := del(pk.Hex())
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Contains(t, w.Body.String(), errNoConversation.Error())
+
+	require.Equal(t, http.StatusBadRequest, del("zz").Code)
+
+	w = httptest.NewRecorder()
+	conversationHandler(w, httptest.NewRequest(http.MethodGet, "/conversation?pk="+pk.Hex(), nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+// resetExpiries forgets ephemeral messages for a test.
+func resetExpiries(t *testing.T) {
+	set := func() {
+		expiryMu.Lock()
+		expiries = make(map[expiryKey]time.Time)
+		expiryMu.Unlock()
+	}
+	set()
+	t.Cleanup(set)
+}
+
+func TestEphemeral_Swept(t *testing.T) {
+	resetConns(t, 2)
+	resetProfiles(t)
+	resetExpiries(t)
+
+	events, unsubscribe := subscribe(eventMessage, eventExpire)
+	defer unsubscribe()
+	next := func() uiEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return uiEvent{}
+		}
+	}
+
+	// the sender dials the recipient, whose side of the conn is handled here as well
+	senderPK, _ := cipher.GenerateKeyPair()
+	recipientPK, _ := cipher.GenerateKeyPair()
+	local, remote := net.Pipe()
+	defer remote.Close() //nolint:errcheck
+	origDial := dial
+	defer func() { dial = origDial }()
+	dial = func(addr appnet.Addr) (net.Conn, error) {
+		return &pipeConn{Conn: local, raddr: addr}, nil
+	}
+	accepted := wrapConn(&pipeConn{Conn: remote, laddr: appnet.Addr{Port: framedPort}, raddr: appnet.Addr{PubKey: senderPK}})
+	require.NoError(t, startHandling(senderPK, accepted))
+
+	send := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		messageHandler(context.Background())(w, httptest.NewRequest(http.MethodPost, "/message", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w
+	}
+	sent := time.Now()
+
+	w := send(fmt.Sprintf(`{"recipient":%q,"message":"secret","ttl":"1m"}`, recipientPK.Hex()))
+	var resp map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	id := resp["id"]
+	require.Len(t, id, 2*ephemeralIDSize)
+	var received map[string]string
+	require.NoError(t, json.Unmarshal([]byte(next().data), &received))
+	require.Equal(t, "secret", received["message"])
+	require.Equal(t, id, received["id"])
+	expiresAt, err := time.Parse(time.RFC3339Nano, received["expires_at"])
+	require.NoError(t, err)
+	require.WithinDuration(t, sent.Add(time.Minute), expiresAt, 5*time.Second)
+
+	// messages without ttl and with a longer one are retained
+	send(fmt.Sprintf(`{"recipient":%q,"message":"kept"}`, recipientPK.Hex()))
+	require.JSONEq(t, fmt.Sprintf(`{"sender":%q,"message":"kept"}`, senderPK.Hex()), next().data)
+	longID, _, err := sendEphemeral(context.Background(), recipientPK, "later", time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(next().data), &received))
+	require.Equal(t, longID, received["id"])
+
+	// the message expires on both sides
+	require.Zero(t, sweepExpired(sent.Add(30*time.Second)))
+	require.Equal(t, 2, sweepExpired(sent.Add(2*time.Minute)))
+	swept := []string{next().data, next().data}
+	require.ElementsMatch(t, []string{
+		fmt.Sprintf(`{"id":%q,"peer":%q}`, id, recipientPK.Hex()),
+		fmt.Sprintf(`{"id":%q,"peer":%q}`, id, senderPK.Hex()),
+	}, swept)
+	require.Empty(t, events)
+
+	expiryMu.Lock()
+	require.Len(t, expiries, 2)
+	for key := range expiries {
+		require.Equal(t, longID, key.id)
+	}
+	expiryMu.Unlock()
+}
+
+func TestParseEphemeral(t *testing.T) {
+	frame := append(append([]byte{}, ephemeralFrame...), make([]byte, ephemeralHeaderSize)...)
+	_, _, _, err := parseEphemeral(append(frame, "zero ttl"...))
+	require.ErrorIs(t, err, errEphemeralFrame)
+	_, _, _, err = parseEphemeral(frame)
+	require.ErrorIs(t, err, errEphemeralFrame)
+
+	_, _, err = sendEphemeral(context.Background(), cipher.PubKey{}, "hi", 0)
+	require.ErrorIs(t, err, errInvalidTTL)
+	_, _, err = sendEphemeral(context.Background(), cipher.PubKey{}, strings.Repeat("a", maxEphemeralMessageSize+1), time.Minute)
+	require.ErrorIs(t, err, errEphemeralLong)
+}
+
+func TestFramedConn(t *testing.T) {
+	t.Run("frames written at once are read separately", func(t *testing.T) {
+		resetConns(t, 1)
+		events, unsubscribe := subscribe(eventMessage)
+		defer unsubscribe()
+
+		pk, _ := cipher.GenerateKeyPair()
+		conn, remote := framedPipe(pk)
+		defer remote.Close() //nolint:errcheck
+		require.NoError(t, startHandling(pk, conn))
+
+		var b []byte
+		for _, text := range []string{"first", "second"} {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(text)))
+			b = append(b, text...)
+		}
+		require.NoError(t, writeFull(remote.Conn, b))
+		for _, text := range []string{"first", "second"} {
+			select {
+			case ev := <-events:
+				require.JSONEq(t, fmt.Sprintf(`{"sender":%q,"message":%q}`, pk.Hex(), text), ev.data)
+			case <-time.After(5 * time.Second):
+				t.Fatal("no message received")
+			}
+		}
+	})
+
+	t.Run("too long frame drops conn", func(t *testing.T) {
+		resetConns(t, 1)
+		pk, _ := cipher.GenerateKeyPair()
+		conn, remote := framedPipe(pk)
+		require.Equal(t, conn, addConn(pk, conn))
+		require.NoError(t, startHandling(pk, conn))
+
+		require.NoError(t, writeFull(remote.Conn, binary.BigEndian.AppendUint32(nil, maxMessageSize+1)))
+		_, err := remote.Read(make([]byte, 1))
+		require.ErrorIs(t, err, io.EOF)
+		require.ErrorIs(t, disconnect(pk), errNotConnected)
+
+		_, err = remote.Write(make([]byte, maxMessageSize+1))
+		require.ErrorIs(t, err, errFrameTooLong)
+	})
+}
+
+func TestPlainConn(t *testing.T) {
+	resetConns(t, 1)
+	resetProfiles(t)
+	require.NoError(t, setProfile(profile{Name: "alice"}))
+	events, unsubscribe := subscribe(eventMessage, eventGoodbye)
+	defer unsubscribe()
+
+	// visors of older releases do not listen on framedPort
+	pk, _ := cipher.GenerateKeyPair()
+	local, remote := net.Pipe()
+	defer remote.Close() //nolint:errcheck
+	origDial := dial
+	defer func() { dial = origDial }()
+	var ports []routing.Port
+	dial = func(addr appnet.Addr) (net.Conn, error) {
+		ports = append(ports, addr.Port)
+		if addr.Port == framedPort {
+			return nil, errors.New("no listener")
+		}
+		return &pipeConn{Conn: local, raddr: addr}, nil
+	}
+	conn, err := ensureConn(context.Background(), pk)
+	require.NoError(t, err)
+	require.Equal(t, []routing.Port{framedPort, port}, ports)
+	require.False(t, isFramed(conn))
+
+	// no control frames are sent to such visors, nor parsed from them
+	_, _, err = sendEphemeral(context.Background(), pk, "secret", time.Minute)
+	require.ErrorIs(t, err, errPlainConn)
+	require.NoError(t, writeFull(remote, goodbyeFrame))
+	select {
+	case ev := <-events:
+		require.Equal(t, eventMessage, ev.typ)
+		require.Contains(t, ev.data, `"message":"\u0000goodbye"`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	go func() {
+		require.NoError(t, writeFull(conn, []byte("hi")))
+	}()
+	buf := make([]byte, maxMessageSize)
+	n, err := remote.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "hi", string(buf[:n]))
+
+	require.NoError(t, disconnect(pk))
+}
+
+func TestHandleConn_PeerLimits(t *testing.T) {
+	t.Run("message of max size is delivered", func(t *testing.T) {
+		resetConns(t, 1)
+		events, unsubscribe := subscribe(eventMessage)
+		defer unsubscribe()
+
+		pk, _ := cipher.GenerateKeyPair()
+		conn, remote := framedPipe(pk)
+		defer remote.Close() //nolint:errcheck
+		require.NoError(t, startHandling(pk, conn))
+
+		text := strings.Repeat("a", maxMessageSize)
+		require.NoError(t, writeFull(remote, []byte(text)))
+		select {
+		case ev := <-events:
+			require.JSONEq(t, fmt.Sprintf(`{"sender":%q,"message":%q}`, pk.Hex(), text), ev.data)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no message received")
+		}
+	})
+
+	for _, tc := range []struct {
+		name    string
+		profile string
+	}{
+		{name: "empty name", profile: `{"name":""}`},
+		{name: "too long name", profile: fmt.Sprintf(`{"name":%q}`, strings.Repeat("a", maxProfileNameLen+1))},
+		{name: "too long avatar", profile: fmt.Sprintf(`{"name":"bob","avatar_ref":%q}`, strings.Repeat("a", maxProfileAvatarLen+1))},
+	} {
+		t.Run(tc.name+" drops conn", func(t *testing.T) {
+			resetConns(t, 1)
+			resetProfiles(t)
+			pk, _ := cipher.GenerateKeyPair()
+			conn, remote := framedPipe(pk)
+			require.Equal(t, conn, addConn(pk, conn))
+			require.NoError(t, startHandling(pk, conn))
+
+			require.NoError(t, writeFull(remote, append(append([]byte{}, profileFrame...), tc.profile...)))
+			_, err := remote.Read(make([]byte, 1))
+			require.ErrorIs(t, err, io.EOF)
+			require.ErrorIs(t, disconnect(pk), errNotConnected)
+			profileMu.Lock()
+			require.Empty(t, peers)
+			profileMu.Unlock()
+		})
+	}
+}
@@ -7,14 +7,19 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/skycoin/skywire-utilities/pkg/buildinfo"
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+	"github.com/skycoin/skywire-utilities/pkg/metricsutil"
 	"github.com/skycoin/skywire/internal/vpn"
+	"github.com/skycoin/skywire/internal/vpn/vpnmetrics"
 	"github.com/skycoin/skywire/pkg/app"
 	"github.com/skycoin/skywire/pkg/app/appnet"
 	"github.com/skycoin/skywire/pkg/app/appserver"
@@ -25,14 +30,23 @@ import (
 const (
 	netType = appnet.TypeSkynet
 	vpnPort = routing.Port(skyenv.VPNServerPort)
+	// stateFileName is the name of the state file in the app's dir of the visor's local dir
+	stateFileName = "system-state.json"
 )
 
 var (
-	localPKStr string
-	localSKStr string
-	passcode   string
-	networkIfc string
-	secure     bool
+	localPKStr     string
+	localSKStr     string
+	passcode       string
+	networkIfc     string
+	secure         bool
+	acceptInterval time.Duration
+	acceptJitter   time.Duration
+	maxAcceptDelay time.Duration
+	metricsAddr    string
+	ipPool         string
+	stateFile      string
+	trafficLog     time.Duration
 )
 
 func init() {
@@ -41,6 +55,13 @@ func init() {
 	RootCmd.Flags().StringVar(&passcode, "passcode", "", "passcode to authenticate connecting users")
 	RootCmd.Flags().StringVar(&networkIfc, "netifc", "", "Default network interface for multiple available interfaces")
 	RootCmd.Flags().BoolVar(&secure, "secure", true, "Forbid connections from clients to server local network")
+	RootCmd.Flags().DurationVar(&acceptInterval, "accept-interval", 0, "Minimal interval between client handshakes, 0 disables pacing")
+	RootCmd.Flags().DurationVar(&acceptJitter, "accept-jitter", 0, "Max random delay added to each client handshake, 0 disables it")
+	RootCmd.Flags().DurationVar(&maxAcceptDelay, "max-accept-delay", 30*time.Second, "Max time a client waits for handshake before being asked to reconnect later")
+	RootCmd.Flags().StringVar(&ipPool, "ip-pool", "", "private IPv4 network in CIDR notation to allocate client subnets from, e.g. 10.100.0.0/16")
+	RootCmd.Flags().StringVar(&metricsAddr, "metrics", "", "address to serve prometheus metrics on, metrics are disabled if empty")
+	RootCmd.Flags().DurationVar(&trafficLog, "traffic-log-interval", 0, "how often to print traffic of every client per direction, 0 disables it")
+	RootCmd.Flags().StringVar(&stateFile, "state-file", "", "file to keep original system network settings in, restored on start if left by a killed server (default "+stateFileName+" in the app's dir of the visor's local dir)")
 }
 
 // RootCmd is the root command for skywire-cli
@@ -105,10 +126,24 @@ var RootCmd = &cobra.Command{
 		setAppPort(appCl, vpnPort)
 		fmt.Printf("Got app listener, bound to %d\n", vpnPort)
 
+		if stateFile == "" {
+			stateFile = filepath.Join(appCl.Config().ProcWorkDir, stateFileName)
+		}
+
 		srvCfg := vpn.ServerConfig{
-			Passcode:         passcode,
-			Secure:           secure,
-			NetworkInterface: networkIfc,
+			Passcode:           passcode,
+			Secure:             secure,
+			NetworkInterface:   networkIfc,
+			AcceptInterval:     acceptInterval,
+			AcceptJitter:       acceptJitter,
+			MaxAcceptDelay:     maxAcceptDelay,
+			IPPool:             ipPool,
+			StateFile:          stateFile,
+			TrafficLogInterval: trafficLog,
+		}
+		if metricsAddr != "" {
+			srvCfg.Metrics = vpnmetrics.NewVictoriaMetrics()
+			metricsutil.ServeHTTPMetrics(logging.MustGetLogger("vpn_server"), metricsAddr)
 		}
 		srv, err := vpn.NewServer(srvCfg, appCl)
 		if err != nil {
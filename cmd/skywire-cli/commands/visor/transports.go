@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -34,6 +35,9 @@ func init() {
 	RootCmd.AddCommand(tpCmd)
 	tpCmd.AddCommand(
 		lsTypesCmd,
+		statusTpCmd,
+		pingTpCmd,
+		dmsgSessionsCmd,
 		lsTpCmd,
 		idCmd,
 		addTpCmd,
@@ -74,6 +78,117 @@ var lsTypesCmd = &cobra.Command{
 	},
 }
 
+var statusTpCmd = &cobra.Command{
+	Use:                   "status",
+	Short:                 "Status of the networks of the local visor",
+	Long:                  "\n  Status of the networks used by the local visor for transports",
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, _ []string) {
+		rpcClient, err := clirpc.Client(cmd.Flags())
+		if err != nil {
+			os.Exit(1)
+		}
+		statuses, err := rpcClient.NetworkStatus()
+		internal.Catch(cmd.Flags(), err)
+
+		var b bytes.Buffer
+		w := tabwriter.NewWriter(&b, 0, 0, 5, ' ', tabwriter.TabIndent)
+		_, err = fmt.Fprintln(w, "type\tready\taddrs\tlisteners\tconns\tlast_error")
+		internal.Catch(cmd.Flags(), err)
+		for _, s := range statuses {
+			addrs := s.LocalAddrs
+			if s.ExternalAddr != "" {
+				addrs = append(addrs, s.ExternalAddr)
+			}
+			lastErr := s.LastError
+			if lastErr != "" {
+				lastErr = fmt.Sprintf("%s (%s)", lastErr, s.LastErrorAt.Format(time.RFC3339))
+			}
+			_, err = fmt.Fprintf(w, "%s\t%t\t%s\t%d\t%d\t%s\n", s.Network, s.Ready,
+				strings.Join(addrs, ","), s.Listeners, s.ActiveConns, lastErr)
+			internal.Catch(cmd.Flags(), err)
+		}
+		internal.Catch(cmd.Flags(), w.Flush())
+		internal.PrintOutput(cmd.Flags(), statuses, b.String())
+	},
+}
+
+var persistDmsgSessions bool
+
+func init() {
+	dmsgSessionsCmd.Flags().BoolVarP(&persistDmsgSessions, "persist", "p", false, "save the number of sessions to the config")
+}
+
+var dmsgSessionsCmd = &cobra.Command{
+	Use:   "dmsg-sessions <count>",
+	Short: "Set number of sessions with dmsg servers",
+	Long: `
+	Set number of sessions the local visor keeps with dmsg servers
+
+	Sessions are dialed or closed in the background,
+	sessions carrying transports are closed once the transports are done`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		n, err := strconv.Atoi(args[0])
+		internal.Catch(cmd.Flags(), err)
+		rpcClient, err := clirpc.Client(cmd.Flags())
+		if err != nil {
+			os.Exit(1)
+		}
+		internal.Catch(cmd.Flags(), rpcClient.SetDmsgSessions(n, persistDmsgSessions))
+		internal.PrintOutput(cmd.Flags(), "OK", "OK\n")
+	},
+}
+
+var (
+	pingTpType  string
+	pingTimeout time.Duration
+)
+
+func init() {
+	pingTpCmd.Flags().StringVarP(&pingTpType, "type", "t", "", "type of network to ping over, all networks if unspecified")
+	pingTpCmd.Flags().DurationVarP(&pingTimeout, "timeout", "o", 0, "if specified, sets an operation timeout")
+}
+
+var pingTpCmd = &cobra.Command{
+	Use:                   "ping (-t <type>) <remote-public-key>",
+	Short:                 "Latency to a remote visor per network",
+	Long:                  "\n  Round-trip time to a remote visor over the networks of the local visor",
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		pk := internal.ParsePK(cmd.Flags(), "remote-public-key", args[0])
+		rpcClient, err := clirpc.Client(cmd.Flags())
+		if err != nil {
+			os.Exit(1)
+		}
+		results, err := rpcClient.PingNetworks(pk, pingTpType, pingTimeout)
+		internal.Catch(cmd.Flags(), err)
+
+		netTypes := make([]string, 0, len(results))
+		for netType := range results {
+			netTypes = append(netTypes, string(netType))
+		}
+		sort.Strings(netTypes)
+
+		var b bytes.Buffer
+		w := tabwriter.NewWriter(&b, 0, 0, 5, ' ', tabwriter.TabIndent)
+		_, err = fmt.Fprintln(w, "type\tlatency\terror")
+		internal.Catch(cmd.Flags(), err)
+		for _, netType := range netTypes {
+			res := results[network.Type(netType)]
+			latency := "-"
+			if res.Error == "" {
+				latency = res.Latency.String()
+			}
+			_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", netType, latency, res.Error)
+			internal.Catch(cmd.Flags(), err)
+		}
+		internal.Catch(cmd.Flags(), w.Flush())
+		internal.PrintOutput(cmd.Flags(), results, b.String())
+	},
+}
+
 func init() {
 	lsTpCmd.Flags().StringSliceVarP(&filterTypes, "types", "t", filterTypes, "show transport(s) type(s) comma-separated")
 	lsTpCmd.Flags().StringSliceVarP(&filterPubKeys, "pks", "p", filterPubKeys, "show transport(s) for public key(s) comma-separated")
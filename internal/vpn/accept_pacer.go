@@ -0,0 +1,57 @@
+// Package vpn internal/vpn/accept_pacer.go
+package vpn
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// acceptPacer spaces out client handshakes, so that clients reconnecting all at once
+// (e.g. after server restart) are admitted gradually instead of hammering `IPGenerator`
+// and the system networking setup simultaneously.
+type acceptPacer struct {
+	interval time.Duration
+	jitter   time.Duration
+	maxDelay time.Duration
+
+	mx   sync.Mutex
+	next time.Time
+}
+
+func newAcceptPacer(interval, jitter, maxDelay time.Duration) *acceptPacer {
+	return &acceptPacer{
+		interval: interval,
+		jitter:   jitter,
+		maxDelay: maxDelay,
+	}
+}
+
+// reserve reserves the next free admission slot and returns the time to wait for it.
+// If the wait would exceed the max delay, no slot is reserved, `ok` is false and
+// `delay` is the time after which the client should reconnect.
+func (p *acceptPacer) reserve() (delay time.Duration, ok bool) {
+	if p.interval <= 0 {
+		return 0, true
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	now := time.Now()
+	slot := p.next
+	if slot.Before(now) {
+		slot = now
+	}
+
+	if p.maxDelay > 0 && slot.Sub(now) > p.maxDelay {
+		return slot.Sub(now), false
+	}
+
+	if p.jitter > 0 {
+		slot = slot.Add(time.Duration(rand.Int63n(int64(p.jitter)))) //nolint:gosec
+	}
+	p.next = slot.Add(p.interval)
+
+	return slot.Sub(now), true
+}
@@ -0,0 +1,69 @@
+// Package vpn internal/vpn/accept_pacer_test.go
+package vpn
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptPacer(t *testing.T) {
+	t.Run("simultaneous connects are spaced out", func(t *testing.T) {
+		const (
+			clients  = 20
+			interval = 10 * time.Millisecond
+		)
+
+		p := newAcceptPacer(interval, 5*time.Millisecond, 0)
+
+		start := time.Now()
+		admitted := make([]time.Duration, 0, clients)
+		var mx sync.Mutex
+		var wg sync.WaitGroup
+		for i := 0; i < clients; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				delay, ok := p.reserve()
+				require.True(t, ok)
+				mx.Lock()
+				admitted = append(admitted, time.Since(start)+delay)
+				mx.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		sort.Slice(admitted, func(i, j int) bool { return admitted[i] < admitted[j] })
+		// allow some slack for the time spent between taking `start` and reserving
+		const slack = 2 * time.Millisecond
+		for i := 1; i < len(admitted); i++ {
+			require.GreaterOrEqual(t, admitted[i]-admitted[i-1], interval-slack)
+		}
+		require.GreaterOrEqual(t, admitted[clients-1], (clients-1)*interval-slack)
+	})
+
+	t.Run("clients over max delay are told to reconnect later", func(t *testing.T) {
+		p := newAcceptPacer(time.Second, 0, 2*time.Second)
+
+		for i := 0; i < 3; i++ {
+			_, ok := p.reserve()
+			require.True(t, ok)
+		}
+
+		delay, ok := p.reserve()
+		require.False(t, ok)
+		require.Greater(t, delay, 2*time.Second)
+	})
+
+	t.Run("zero interval disables pacing", func(t *testing.T) {
+		p := newAcceptPacer(0, time.Second, 0)
+		for i := 0; i < 10; i++ {
+			delay, ok := p.reserve()
+			require.True(t, ok)
+			require.Zero(t, delay)
+		}
+	})
+}
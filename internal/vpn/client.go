@@ -53,6 +53,8 @@ type Client struct {
 	tunCreated bool
 
 	connectedDuration int64
+	plainHello        bool // Whether the server rejected framed hellos
+	retryPlainHello   bool // Whether the next handshake only sends a plain hello, after a framed one failed
 
 	defaultSystemDNS string //nolint
 }
@@ -174,8 +176,8 @@ func (c *Client) Serve() error {
 
 	r := netutil.NewRetrier(nil, netutil.DefaultInitBackoff, netutil.DefaultMaxBackoff, 3, netutil.DefaultFactor).
 		WithErrWhitelist(errHandshakeStatusForbidden, errHandshakeStatusInternalError, errHandshakeNoFreeIPs,
-			errHandshakeStatusBadRequest, errNoTransportFound, errTransportNotFound, errErrSetupNode, errNotPermitted,
-			errErrServerOffline)
+			errHandshakeStatusBadRequest, errHandshakeStatusVersionMismatch, errNoTransportFound, errTransportNotFound,
+			errErrSetupNode, errNotPermitted, errErrServerOffline)
 
 	err := r.Do(context.Background(), func() error {
 		if c.isClosed() {
@@ -185,8 +187,8 @@ func (c *Client) Serve() error {
 		if err := c.dialServeConn(); err != nil {
 			switch err {
 			case errHandshakeStatusForbidden, errHandshakeStatusInternalError, errHandshakeNoFreeIPs,
-				errHandshakeStatusBadRequest, errNoTransportFound, errTransportNotFound, errErrSetupNode, errNotPermitted,
-				errErrServerOffline:
+				errHandshakeStatusBadRequest, errHandshakeStatusVersionMismatch, errNoTransportFound, errTransportNotFound,
+				errErrSetupNode, errNotPermitted, errErrServerOffline:
 				c.setAppError(err)
 				c.resetConnDuration()
 				return err
@@ -674,19 +676,32 @@ func (c *Client) shakeHands(conn net.Conn) (TUNIP, TUNGateway net.IP, err error)
 	cHello := ClientHello{
 		UnavailablePrivateIPs: unavailableIPs,
 		Passcode:              c.cfg.Passcode,
+		Version:               protocolVersion,
 	}
 
 	const handshakeTimeout = 5 * time.Second
 
 	fmt.Printf("Sending client hello: %v\n", cHello)
 
-	if err := WriteJSONWithTimeout(conn, &cHello, handshakeTimeout); err != nil {
-		return nil, nil, fmt.Errorf("error sending client hello: %w", err)
-	}
+	// a conn closed on a framed hello may be an older server as well as a restarting one,
+	// so plain hello is only sent once then, and framed hellos are sent again on next reconnect
+	framed := !c.plainHello && !c.retryPlainHello
+	c.retryPlainHello = false
 
-	var sHello ServerHello
-	if err := ReadJSONWithTimeout(conn, &sHello, handshakeTimeout); err != nil {
-		fmt.Printf("error reading server hello: %v\n", err)
+	sHello, err := ClientHandshake(conn, cHello, framed, handshakeTimeout)
+	switch {
+	case errors.Is(err, errFramedHelloRejected):
+		fmt.Println("Server rejected framed hello, falling back to plain hellos")
+		c.plainHello = true
+	case errors.Is(err, errFramedHelloFailed):
+		fmt.Println("Framed hello got no reply, retrying with a plain hello")
+		c.retryPlainHello = true
+	}
+	if sHello.Status == HandshakeStatusServerFull {
+		c.waitReconnectAfter(sHello.ReconnectAfter)
+	}
+	if err != nil {
+		fmt.Printf("error during handshake: %v\n", err)
 		if strings.Contains(err.Error(), appnet.ErrServiceOffline(skyenv.VPNServerPort).Error()) {
 			err = appserver.RPCErr{
 				Err: err.Error(),
@@ -697,11 +712,23 @@ func (c *Client) shakeHands(conn net.Conn) (TUNIP, TUNGateway net.IP, err error)
 
 	fmt.Printf("Got server hello: %v", sHello)
 
-	if sHello.Status != HandshakeStatusOK {
-		return nil, nil, sHello.Status.getError()
+	return sHello.TUNIP, sHello.TUNGateway, nil
+}
+
+// waitReconnectAfter waits for the reconnect delay requested by server,
+// or until the client gets closed.
+func (c *Client) waitReconnectAfter(d time.Duration) {
+	const maxReconnectAfter = time.Minute
+	if d > maxReconnectAfter {
+		d = maxReconnectAfter
 	}
 
-	return sHello.TUNIP, sHello.TUNGateway, nil
+	fmt.Printf("Server is full, reconnecting after %v\n", d)
+
+	select {
+	case <-time.After(d):
+	case <-c.closeC:
+	}
 }
 
 func (c *Client) dialServer(appCl *app.Client, pk cipher.PubKey) (net.Conn, error) {
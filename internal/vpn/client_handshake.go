@@ -0,0 +1,48 @@
+// Package vpn internal/vpn/client_handshake.go
+package vpn
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ClientHandshake performs the client side of the Client/Server handshake over `conn`.
+// It sends `cHello`, length-prefixed if `framed`, and waits for the server hello for at most
+// `timeout`. Server hello is returned along with the error matching its status. Handshakes
+// timing out return `errHandshakeTimeout`, which is retried by the client, unlike the rejections
+// by server. Framed hellos answered with a plain failed hello return `errFramedHelloRejected`,
+// the ones left unanswered otherwise return `errFramedHelloFailed`, since servers of older
+// releases close the conn on them.
+func ClientHandshake(conn net.Conn, cHello ClientHello, framed bool, timeout time.Duration) (ServerHello, error) {
+	if err := WriteHelloWithTimeout(conn, &cHello, framed, timeout); err != nil {
+		return ServerHello{}, handshakeErr("error sending client hello", err)
+	}
+
+	var sHello ServerHello
+	framedReply, err := ReadHelloWithTimeout(conn, &sHello, timeout)
+	if err != nil {
+		err = handshakeErr("error reading server hello", err)
+		if framed && !errors.Is(err, errHandshakeTimeout) {
+			err = fmt.Errorf("%w: %w", errFramedHelloFailed, err)
+		}
+		return ServerHello{}, err
+	}
+
+	err = sHello.Status.getError()
+	if framed && !framedReply && err != nil {
+		err = fmt.Errorf("%w: %w", errFramedHelloRejected, err)
+	}
+
+	return sHello, err
+}
+
+// handshakeErr wraps handshake I/O errors, classifying the timed out ones.
+func handshakeErr(msg string, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%s: %w", msg, errHandshakeTimeout)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
@@ -0,0 +1,141 @@
+// Package vpn internal/vpn/client_handshake_test.go
+package vpn
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientHandshake(t *testing.T) {
+	const timeout = time.Second
+
+	cHello := ClientHello{
+		UnavailablePrivateIPs: []net.IP{net.IPv4(192, 168, 1, 1)},
+		Passcode:              "1234",
+	}
+
+	// serve reads the client hello and answers with `sHello` the way it was sent
+	serve := func(t *testing.T, conn net.Conn, sHello ServerHello) <-chan ClientHello {
+		gotCh := make(chan ClientHello, 1)
+		go func() {
+			var got ClientHello
+			framed, err := ReadHello(conn, &got)
+			if err != nil {
+				return
+			}
+			require.True(t, framed)
+			gotCh <- got
+			require.NoError(t, WriteHello(conn, &sHello, framed))
+		}()
+		return gotCh
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer closePipe(t, c1, c2)
+
+		want := ServerHello{
+			Status:     HandshakeStatusOK,
+			TUNIP:      net.IPv4(192, 168, 2, 2),
+			TUNGateway: net.IPv4(192, 168, 2, 1),
+		}
+		gotCh := serve(t, c2, want)
+
+		sHello, err := ClientHandshake(c1, cHello, true, timeout)
+		require.NoError(t, err)
+		require.Equal(t, cHello, <-gotCh)
+		require.True(t, want.TUNIP.Equal(sHello.TUNIP))
+		require.True(t, want.TUNGateway.Equal(sHello.TUNGateway))
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer closePipe(t, c1, c2)
+
+		serve(t, c2, ServerHello{Status: HandshakeStatusForbidden})
+
+		sHello, err := ClientHandshake(c1, cHello, true, timeout)
+		require.Equal(t, errHandshakeStatusForbidden, err)
+		require.Equal(t, HandshakeStatusForbidden, sHello.Status)
+	})
+
+	t.Run("no free IPs", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer closePipe(t, c1, c2)
+
+		serve(t, c2, ServerHello{Status: HandshakeNoFreeIPs})
+
+		sHello, err := ClientHandshake(c1, cHello, true, timeout)
+		require.Equal(t, errHandshakeNoFreeIPs, err)
+		require.Equal(t, HandshakeNoFreeIPs, sHello.Status)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer closePipe(t, c1, c2)
+
+		// server reads the client hello but never answers
+		go func() {
+			var got ClientHello
+			_, _ = ReadHello(c2, &got) //nolint:errcheck
+		}()
+
+		start := time.Now()
+		_, err := ClientHandshake(c1, cHello, true, 100*time.Millisecond)
+		require.ErrorIs(t, err, errHandshakeTimeout)
+		require.Less(t, time.Since(start), timeout)
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer closePipe(t, c1, c2)
+
+		// servers of older releases only read and send plain hellos
+		go func() {
+			var got ClientHello
+			if err := ReadJSON(c2, &got); err != nil {
+				return
+			}
+			require.NoError(t, WriteJSON(c2, &ServerHello{Status: HandshakeStatusForbidden}))
+		}()
+
+		_, err := ClientHandshake(c1, cHello, false, timeout)
+		require.Equal(t, errHandshakeStatusForbidden, err)
+	})
+
+	t.Run("framed hello unanswered", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer c1.Close() //nolint:errcheck
+
+		// servers of older releases fail to parse framed hellos and close the conn
+		go func() {
+			var got ClientHello
+			require.Error(t, ReadJSON(c2, &got))
+			require.NoError(t, c2.Close())
+		}()
+
+		_, err := ClientHandshake(c1, cHello, true, timeout)
+		require.ErrorIs(t, err, errFramedHelloFailed)
+		require.NotErrorIs(t, err, errFramedHelloRejected)
+	})
+
+	t.Run("framed hello rejected", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer c1.Close() //nolint:errcheck
+
+		go func() {
+			var got ClientHello
+			if _, err := ReadHello(c2, &got); err != nil {
+				return
+			}
+			require.NoError(t, WriteHello(c2, &ServerHello{Status: HandshakeStatusBadRequest}, false))
+		}()
+
+		_, err := ClientHandshake(c1, cHello, true, timeout)
+		require.ErrorIs(t, err, errFramedHelloRejected)
+		require.ErrorIs(t, err, errHandshakeStatusBadRequest)
+	})
+}
@@ -5,8 +5,18 @@ import (
 	"net"
 )
 
+// protocolVersion is the version of the Client/Server handshake protocol. Hellos of
+// older clients carry no version and are compatible with version 1.
+const protocolVersion = 1
+
 // ClientHello is a message sent by client during the Client/Server handshake.
 type ClientHello struct {
 	UnavailablePrivateIPs []net.IP `json:"unavailable_private_ips"`
 	Passcode              string   `json:"passcode"`
+	Version               int      `json:"version,omitempty"`
+}
+
+// compatible reports whether the server speaks the protocol version of the hello.
+func (h ClientHello) compatible() bool {
+	return h.Version == 0 || h.Version == protocolVersion
 }
@@ -17,10 +17,17 @@ var (
 	errHandshakeStatusInternalError   = errors.New("internal server error")
 	errHandshakeNoFreeIPs             = errors.New("no free IPs left to serve")
 	errHandshakeStatusBadRequest      = errors.New("request was malformed")
+	errHandshakeStatusServerFull      = errors.New("server is full")
+	errHandshakeStatusVersionMismatch = errors.New("client and server protocol versions mismatch")
+	errHandshakeStatusUnknown         = errors.New("unknown handshake status")
+	errHandshakeTimeout               = errors.New("handshake timed out")
+	errFramedHelloRejected            = errors.New("server rejected framed hello")
+	errFramedHelloFailed              = errors.New("framed hello got no reply")
 	errTimeout                        = errors.New("internal error: Timeout")
 	errNotPermitted                   = errors.New("ioctl: operation not permitted")
 	errVPNServerClosed                = errors.New("vpn-server closed")
 	errPermissionDenied               = errors.New("permission denied")
+	errJSONTooLarge                   = errors.New("JSON message exceeds maximum size")
 
 	errNoTransportFound = appserver.RPCErr{
 		Err: router.ErrNoTransportFound.Error(),
@@ -2,7 +2,7 @@
 package vpn
 
 import (
-	"errors"
+	"fmt"
 )
 
 // HandshakeStatus is a status of Client/Server handshake.
@@ -19,6 +19,10 @@ const (
 	HandshakeStatusInternalError
 	// HandshakeStatusForbidden is returned if client had sent the wrong passcode.
 	HandshakeStatusForbidden
+	// HandshakeStatusServerFull is returned if server is admitting too many clients at once.
+	HandshakeStatusServerFull
+	// HandshakeStatusVersionMismatch is returned if client and server protocol versions are incompatible.
+	HandshakeStatusVersionMismatch
 )
 
 func (hs HandshakeStatus) String() string {
@@ -33,6 +37,10 @@ func (hs HandshakeStatus) String() string {
 		return "Internal server error"
 	case HandshakeStatusForbidden:
 		return "Forbidden"
+	case HandshakeStatusServerFull:
+		return "Server is full, try again later"
+	case HandshakeStatusVersionMismatch:
+		return "Protocol version mismatch"
 	default:
 		return "Unknown code"
 	}
@@ -50,7 +58,11 @@ func (hs HandshakeStatus) getError() error {
 		return errHandshakeStatusInternalError
 	case HandshakeStatusForbidden:
 		return errHandshakeStatusForbidden
+	case HandshakeStatusServerFull:
+		return errHandshakeStatusServerFull
+	case HandshakeStatusVersionMismatch:
+		return errHandshakeStatusVersionMismatch
 	default:
-		return errors.New("Unknown error code")
+		return fmt.Errorf("%w: %d", errHandshakeStatusUnknown, hs)
 	}
 }
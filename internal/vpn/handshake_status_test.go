@@ -0,0 +1,46 @@
+// Package vpn internal/vpn/handshake_status_test.go
+package vpn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandshakeStatus_getError(t *testing.T) {
+	tests := []struct {
+		status HandshakeStatus
+		want   error
+	}{
+		{status: HandshakeStatusOK, want: nil},
+		{status: HandshakeStatusBadRequest, want: errHandshakeStatusBadRequest},
+		{status: HandshakeNoFreeIPs, want: errHandshakeNoFreeIPs},
+		{status: HandshakeStatusInternalError, want: errHandshakeStatusInternalError},
+		{status: HandshakeStatusForbidden, want: errHandshakeStatusForbidden},
+		{status: HandshakeStatusServerFull, want: errHandshakeStatusServerFull},
+		{status: HandshakeStatusVersionMismatch, want: errHandshakeStatusVersionMismatch},
+		{status: HandshakeStatus(100), want: errHandshakeStatusUnknown},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.status.String(), func(t *testing.T) {
+			err := tc.status.getError()
+			if tc.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.want)
+		})
+	}
+}
+
+func TestHandshakeStatus_WireValues(t *testing.T) {
+	// values are sent over the wire, so they must never change
+	require.EqualValues(t, 0, HandshakeStatusOK)
+	require.EqualValues(t, 1, HandshakeStatusBadRequest)
+	require.EqualValues(t, 2, HandshakeNoFreeIPs)
+	require.EqualValues(t, 3, HandshakeStatusInternalError)
+	require.EqualValues(t, 4, HandshakeStatusForbidden)
+	require.EqualValues(t, 5, HandshakeStatusServerFull)
+	require.EqualValues(t, 6, HandshakeStatusVersionMismatch)
+}
@@ -2,11 +2,22 @@
 package vpn
 
 import (
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"net"
 	"sync"
 )
 
+// subnetStep is the size of a subnet given to each client.
+const subnetStep = 8
+
+// minReservePrefixLen is the shortest prefix of a network which may be reserved at once.
+const minReservePrefixLen = 16
+
+// maxPoolPrefixLen is the longest prefix of an IP pool which still fits a client subnet.
+const maxPoolPrefixLen = 28
+
 // IPGenerator is used to generate IPs for TUN interfaces.
 type IPGenerator struct {
 	mx           sync.Mutex
@@ -19,13 +30,32 @@ func NewIPGenerator() *IPGenerator {
 	return &IPGenerator{
 		ranges: []*subnetIPIncrementer{
 			// exclude some most commonly used addresses in local networks
-			newSubnetIPIncrementer([4]uint8{192, 168, 2, 0}, [4]uint8{192, 168, 255, 255}, 8),
-			newSubnetIPIncrementer([4]uint8{172, 16, 0, 0}, [4]uint8{172, 31, 255, 255}, 8),
-			newSubnetIPIncrementer([4]uint8{10, 0, 0, 0}, [4]uint8{10, 255, 255, 255}, 8),
+			newSubnetIPIncrementer([4]uint8{192, 168, 2, 0}, [4]uint8{192, 168, 255, 255}, subnetStep),
+			newSubnetIPIncrementer([4]uint8{172, 16, 0, 0}, [4]uint8{172, 31, 255, 255}, subnetStep),
+			newSubnetIPIncrementer([4]uint8{10, 0, 0, 0}, [4]uint8{10, 255, 255, 255}, subnetStep),
 		},
 	}
 }
 
+// NewIPGeneratorWithPool creates IP generator allocating subnets within the `pool`
+// given in CIDR notation. Pool must be a private IPv4 network.
+func NewIPGeneratorWithPool(pool string) (*IPGenerator, error) {
+	lower, upper, ones, err := parsePrivateIPv4CIDR(pool)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP pool %s: %w", pool, err)
+	}
+
+	if ones > maxPoolPrefixLen {
+		return nil, fmt.Errorf("IP pool %s is too small, prefix may be at most /%d", pool, maxPoolPrefixLen)
+	}
+
+	return &IPGenerator{
+		ranges: []*subnetIPIncrementer{
+			newSubnetIPIncrementer(lower, upper, subnetStep),
+		},
+	}, nil
+}
+
 // Reserve reserves `ip` so it will be excluded from the IP generation.
 func (g *IPGenerator) Reserve(ip net.IP) error {
 	octets, err := fetchIPv4Octets(ip)
@@ -33,14 +63,44 @@ func (g *IPGenerator) Reserve(ip net.IP) error {
 		return err
 	}
 
+	g.reserve(octets)
+
+	return nil
+}
+
+// ReserveCIDR reserves all the IPs of the private network `cidr`, so they will be
+// excluded from the IP generation. Network prefix may be at least /16.
+func (g *IPGenerator) ReserveCIDR(cidr string) error {
+	lower, upper, ones, err := parsePrivateIPv4CIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR %s: %w", cidr, err)
+	}
+
+	if ones < minReservePrefixLen {
+		return fmt.Errorf("CIDR %s is too large, prefix must be at least /%d", cidr, minReservePrefixLen)
+	}
+
+	first, last := binary.BigEndian.Uint32(lower[:]), binary.BigEndian.Uint32(upper[:])
+	for ip := first; ; ip++ {
+		var octets [4]uint8
+		binary.BigEndian.PutUint32(octets[:], ip)
+		g.reserve(octets)
+
+		if ip == last {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (g *IPGenerator) reserve(octets [4]uint8) {
 	// of course it's best to reserve it within the range it belongs to.
 	// but it really doesn't matter, we may just reserve it in all incrementing instances,
 	// that is much simpler and works anyway
 	for _, inc := range g.ranges {
 		inc.reserve(octets)
 	}
-
-	return nil
 }
 
 // Next gets next available IP.
@@ -48,10 +108,8 @@ func (g *IPGenerator) Next() (net.IP, error) {
 	g.mx.Lock()
 	defer g.mx.Unlock()
 
-	for i := g.currentRange + 1; i != g.currentRange; i++ {
-		if i >= len(g.ranges) {
-			i = 0
-		}
+	for n := 0; n < len(g.ranges); n++ {
+		i := (g.currentRange + 1 + n) % len(g.ranges)
 
 		ip, err := g.ranges[i].next()
 		if err != nil {
@@ -64,6 +122,32 @@ func (g *IPGenerator) Next() (net.IP, error) {
 	return nil, errors.New("no free IPs left")
 }
 
+// parsePrivateIPv4CIDR returns the first and the last IPs and the prefix length
+// of the private IPv4 network `cidr`.
+func parsePrivateIPv4CIDR(cidr string) (lower, upper [4]uint8, ones int, err error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return lower, upper, 0, err
+	}
+
+	lower, err = fetchIPv4Octets(ipNet.IP)
+	if err != nil {
+		return lower, upper, 0, err
+	}
+
+	for i := range upper {
+		upper[i] = lower[i] | ^ipNet.Mask[i]
+	}
+
+	upperIP := net.IPv4(upper[0], upper[1], upper[2], upper[3])
+	if !ipNet.IP.IsPrivate() || !upperIP.IsPrivate() {
+		return lower, upper, 0, errors.New("not a private network")
+	}
+
+	ones, _ = ipNet.Mask.Size()
+	return lower, upper, ones, nil
+}
+
 func fetchIPv4Octets(ip net.IP) ([4]uint8, error) {
 	ip = ip.To4()
 	if ip == nil {
@@ -0,0 +1,105 @@
+// Package vpn internal/vpn/ip_generator_test.go
+package vpn
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIPGeneratorWithPool(t *testing.T) {
+	t.Run("allocates within pool", func(t *testing.T) {
+		_, pool, err := net.ParseCIDR("10.50.16.0/20")
+		require.NoError(t, err)
+
+		gen, err := NewIPGeneratorWithPool(pool.String())
+		require.NoError(t, err)
+
+		seen := make(map[string]struct{})
+		for {
+			subnet, err := gen.Next()
+			if err != nil {
+				break
+			}
+			// client and server TUN IPs are taken from subnet + 1 to subnet + 4
+			require.True(t, pool.Contains(subnet), subnet.String())
+			octets, err := fetchIPv4Octets(subnet)
+			require.NoError(t, err)
+			require.True(t, pool.Contains(net.IPv4(octets[0], octets[1], octets[2], octets[3]+4)))
+
+			_, ok := seen[subnet.String()]
+			require.False(t, ok, "subnet %s allocated twice", subnet)
+			seen[subnet.String()] = struct{}{}
+		}
+		require.NotEmpty(t, seen)
+	})
+
+	t.Run("smallest pool", func(t *testing.T) {
+		gen, err := NewIPGeneratorWithPool("192.168.100.16/28")
+		require.NoError(t, err)
+
+		subnet, err := gen.Next()
+		require.NoError(t, err)
+		require.Equal(t, net.IPv4(192, 168, 100, 24).String(), subnet.String())
+
+		_, err = gen.Next()
+		require.Error(t, err)
+	})
+
+	t.Run("invalid pools", func(t *testing.T) {
+		for _, pool := range []string{
+			"",
+			"10.0.0.1",
+			"8.8.8.0/24",
+			"172.0.0.0/8",
+			"10.0.0.0/29",
+			"fd00::/64",
+		} {
+			_, err := NewIPGeneratorWithPool(pool)
+			require.Error(t, err, pool)
+		}
+	})
+}
+
+func TestIPGenerator_ReserveCIDR(t *testing.T) {
+	t.Run("reserved IPs are excluded", func(t *testing.T) {
+		gen, err := NewIPGeneratorWithPool("192.168.100.0/26")
+		require.NoError(t, err)
+
+		_, reserved, err := net.ParseCIDR("192.168.100.16/28")
+		require.NoError(t, err)
+		require.NoError(t, gen.ReserveCIDR(reserved.String()))
+
+		var subnets int
+		for {
+			subnet, err := gen.Next()
+			if err != nil {
+				break
+			}
+			subnets++
+
+			octets, err := fetchIPv4Octets(subnet)
+			require.NoError(t, err)
+			for i := uint8(0); i < subnetStep; i++ {
+				ip := net.IPv4(octets[0], octets[1], octets[2], octets[3]+i)
+				require.False(t, reserved.Contains(ip), "subnet %s overlaps reserved %s", subnet, reserved)
+			}
+		}
+		// subnets 192.168.100.8, .32, .40, .48 and .56 remain
+		require.Equal(t, 5, subnets)
+	})
+
+	t.Run("invalid CIDRs", func(t *testing.T) {
+		gen := NewIPGenerator()
+		for _, cidr := range []string{
+			"",
+			"10.0.0.1",
+			"8.8.8.0/24",
+			"10.0.0.0/8",
+			"fd00::/64",
+		} {
+			require.Error(t, gen.ReserveCIDR(cidr), cidr)
+		}
+	})
+}
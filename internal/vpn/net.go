@@ -2,12 +2,22 @@
 package vpn
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"time"
 )
 
+const (
+	// maxJSONSize is the maximum size of a single JSON message exchanged
+	// during the Client/Server handshake.
+	maxJSONSize = 64 * 1024
+	// jsonLenPrefixSize is the size of the length prefix preceding each framed JSON message.
+	jsonLenPrefixSize = 4
+)
+
 // WriteJSONWithTimeout marshals `data` and sends it over the `conn` with the specified write `timeout`.
 func WriteJSONWithTimeout(conn net.Conn, data interface{}, timeout time.Duration) error {
 	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
@@ -76,6 +86,9 @@ func ReadJSON(conn net.Conn, data interface{}) error {
 		}
 
 		dataBytes = append(dataBytes, buf[:n]...)
+		if len(dataBytes) > maxJSONSize {
+			return errJSONTooLarge
+		}
 
 		if n < 1024 {
 			break
@@ -88,3 +101,165 @@ func ReadJSON(conn net.Conn, data interface{}) error {
 
 	return nil
 }
+
+// WriteJSONFramedWithTimeout marshals `data` and sends it length-prefixed over the `conn`
+// with the specified write `timeout`.
+func WriteJSONFramedWithTimeout(conn net.Conn, data interface{}, timeout time.Duration) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return fmt.Errorf("failed to set write deadline: %w", err)
+	}
+
+	if err := WriteJSONFramed(conn, data); err != nil {
+		return err
+	}
+
+	if err := conn.SetWriteDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("failed to remove write deadline: %w", err)
+	}
+
+	return nil
+}
+
+// WriteJSONFramed marshals `data` and sends it over the `conn` prefixed with
+// its big-endian encoded length.
+func WriteJSONFramed(conn net.Conn, data interface{}) error {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error marshaling data: %w", err)
+	}
+
+	if len(dataBytes) > maxJSONSize {
+		return errJSONTooLarge
+	}
+
+	frame := make([]byte, jsonLenPrefixSize+len(dataBytes))
+	binary.BigEndian.PutUint32(frame, uint32(len(dataBytes)))
+	copy(frame[jsonLenPrefixSize:], dataBytes)
+
+	for n, totalSent := 0, 0; totalSent < len(frame); totalSent += n {
+		n, err = conn.Write(frame[totalSent:])
+		if err != nil {
+			return fmt.Errorf("error sending data: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ReadJSONFramedWithTimeout reads a length-prefixed message from the `conn` and unmarshals
+// it into `data` with the specified read `timeout`.
+func ReadJSONFramedWithTimeout(conn net.Conn, data interface{}, timeout time.Duration) error {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return fmt.Errorf("failed to set read deadline: %w", err)
+	}
+
+	if err := ReadJSONFramed(conn, data); err != nil {
+		return err
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("failed to remove read deadline: %w", err)
+	}
+
+	return nil
+}
+
+// ReadJSONFramed reads a length-prefixed message from the `conn` and unmarshals it into `data`.
+// Messages declaring a length above `maxJSONSize` are rejected without being read.
+func ReadJSONFramed(conn net.Conn, data interface{}) error {
+	prefix := make([]byte, jsonLenPrefixSize)
+	if _, err := io.ReadFull(conn, prefix); err != nil {
+		return fmt.Errorf("error reading data length: %w", err)
+	}
+
+	size := binary.BigEndian.Uint32(prefix)
+	if size > maxJSONSize {
+		return errJSONTooLarge
+	}
+
+	dataBytes := make([]byte, size)
+	if _, err := io.ReadFull(conn, dataBytes); err != nil {
+		return fmt.Errorf("error reading data: %w", err)
+	}
+
+	if err := json.Unmarshal(dataBytes, data); err != nil {
+		return fmt.Errorf("error unmarshaling data: %w", err)
+	}
+
+	return nil
+}
+
+// WriteHelloWithTimeout sends the hello `data` over the `conn` with the specified write `timeout`,
+// length-prefixed if `framed`.
+func WriteHelloWithTimeout(conn net.Conn, data interface{}, framed bool, timeout time.Duration) error {
+	if framed {
+		return WriteJSONFramedWithTimeout(conn, data, timeout)
+	}
+	return WriteJSONWithTimeout(conn, data, timeout)
+}
+
+// WriteHello sends the hello `data` over the `conn`, length-prefixed if `framed`.
+func WriteHello(conn net.Conn, data interface{}, framed bool) error {
+	if framed {
+		return WriteJSONFramed(conn, data)
+	}
+	return WriteJSON(conn, data)
+}
+
+// ReadHelloWithTimeout reads a hello from the `conn` like `ReadHello` with the specified read `timeout`.
+func ReadHelloWithTimeout(conn net.Conn, data interface{}, timeout time.Duration) (framed bool, err error) {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return false, fmt.Errorf("failed to set read deadline: %w", err)
+	}
+
+	if framed, err = ReadHello(conn, data); err != nil {
+		return false, err
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return false, fmt.Errorf("failed to remove read deadline: %w", err)
+	}
+
+	return framed, nil
+}
+
+// ReadHello reads a hello from the `conn` and unmarshals it into `data`. Hellos are accepted both
+// length-prefixed and plain, as sent by older releases, and the returned `framed` tells which one
+// was read. Length prefixes start with a zero byte, since `maxJSONSize` fits in three bytes,
+// while plain JSON hellos never do.
+func ReadHello(conn net.Conn, data interface{}) (framed bool, err error) {
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(conn, first); err != nil {
+		return false, fmt.Errorf("error reading data: %w", err)
+	}
+
+	conn = &prefixedConn{Conn: conn, prefix: first}
+	if first[0] == 0 {
+		return true, ReadJSONFramed(conn, data)
+	}
+	return false, ReadJSON(conn, data)
+}
+
+// prefixedConn is a conn whose reads yield `prefix`, the bytes already read from
+// the underlying conn, before the rest of it.
+type prefixedConn struct {
+	net.Conn
+	prefix []byte
+}
+
+// Read implements net.Conn. The read filling the rest of `b` after the prefix keeps
+// `ReadJSON` from taking the prefix alone for the whole message.
+func (c *prefixedConn) Read(b []byte) (int, error) {
+	if len(c.prefix) == 0 {
+		return c.Conn.Read(b)
+	}
+
+	n := copy(b, c.prefix)
+	c.prefix = c.prefix[n:]
+	if len(c.prefix) > 0 || n == len(b) {
+		return n, nil
+	}
+
+	m, err := c.Conn.Read(b[n:])
+	return n + m, err
+}
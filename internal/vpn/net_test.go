@@ -0,0 +1,96 @@
+// Package vpn internal/vpn/net_test.go
+package vpn
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWriteJSONFramed(t *testing.T) {
+	t.Run("normal hello", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer closePipe(t, c1, c2)
+
+		cHello := ClientHello{
+			UnavailablePrivateIPs: []net.IP{net.IPv4(192, 168, 1, 1)},
+			Passcode:              "1234",
+		}
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- WriteJSONFramed(c1, &cHello)
+		}()
+
+		var got ClientHello
+		require.NoError(t, ReadJSONFramed(c2, &got))
+		require.NoError(t, <-errCh)
+		require.Equal(t, cHello, got)
+	})
+
+	t.Run("oversize hello", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+		defer closePipe(t, c1, c2)
+
+		go func() {
+			prefix := make([]byte, jsonLenPrefixSize)
+			binary.BigEndian.PutUint32(prefix, maxJSONSize+1)
+			_, _ = c1.Write(prefix) //nolint:errcheck
+		}()
+
+		var got ClientHello
+		require.ErrorIs(t, ReadJSONFramed(c2, &got), errJSONTooLarge)
+
+		cHello := ClientHello{Passcode: strings.Repeat("a", maxJSONSize)}
+		require.ErrorIs(t, WriteJSONFramed(c1, &cHello), errJSONTooLarge)
+	})
+
+	t.Run("truncated frame", func(t *testing.T) {
+		c1, c2 := net.Pipe()
+
+		go func() {
+			prefix := make([]byte, jsonLenPrefixSize)
+			binary.BigEndian.PutUint32(prefix, 100)
+			_, _ = c1.Write(prefix)                    //nolint:errcheck
+			_, _ = c1.Write([]byte(`{"passcode":"12`)) //nolint:errcheck
+			_ = c1.Close()                             //nolint:errcheck
+		}()
+
+		var got ClientHello
+		require.Error(t, ReadJSONFramed(c2, &got))
+		require.NoError(t, c2.Close())
+	})
+}
+
+func TestReadHello(t *testing.T) {
+	cHello := ClientHello{
+		UnavailablePrivateIPs: []net.IP{net.IPv4(192, 168, 1, 1)},
+		Passcode:              "1234",
+	}
+
+	for _, framed := range []bool{true, false} {
+		c1, c2 := net.Pipe()
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- WriteHello(c1, &cHello, framed)
+		}()
+
+		var got ClientHello
+		gotFramed, err := ReadHello(c2, &got)
+		require.NoError(t, err)
+		require.NoError(t, <-errCh)
+		require.Equal(t, framed, gotFramed)
+		require.Equal(t, cHello, got)
+		closePipe(t, c1, c2)
+	}
+}
+
+func closePipe(t *testing.T, conns ...net.Conn) {
+	for _, conn := range conns {
+		require.NoError(t, conn.Close())
+	}
+}
@@ -8,8 +8,10 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/skycoin/skywire-utilities/pkg/netutil"
+	"github.com/skycoin/skywire/internal/vpn/vpnmetrics"
 	"github.com/skycoin/skywire/pkg/app"
 	"github.com/skycoin/skywire/pkg/app/appserver"
 )
@@ -18,24 +20,44 @@ import (
 type Server struct {
 	cfg                        ServerConfig
 	lisMx                      sync.Mutex
-	lis                        net.Listener
+	lis                        []net.Listener
 	serveOnce                  sync.Once
 	ipGen                      *IPGenerator
+	pacer                      *acceptPacer
+	metrics                    vpnmetrics.Metrics
 	defaultNetworkInterface    string
 	defaultNetworkInterfaceIPs []net.IP
 	ipv4ForwardingVal          string
 	ipv6ForwardingVal          string
 	iptablesForwardPolicy      string
 	appCl                      *app.Client
+	trafficMx                  sync.Mutex
+	traffic                    map[*clientTraffic]struct{}
+	closeC                     chan struct{}
+	closeOnce                  sync.Once
 }
 
 // NewServer creates VPN server instance.
 func NewServer(cfg ServerConfig, appCl *app.Client) (*Server, error) {
 	var defaultNetworkIfc string
 	s := &Server{
-		cfg:   cfg,
-		ipGen: NewIPGenerator(),
-		appCl: appCl,
+		cfg:    cfg,
+		ipGen:  NewIPGenerator(),
+		pacer:  newAcceptPacer(cfg.AcceptInterval, cfg.AcceptJitter, cfg.MaxAcceptDelay),
+		appCl:  appCl,
+		closeC: make(chan struct{}),
+	}
+	s.metrics = cfg.Metrics
+	if s.metrics == nil {
+		s.metrics = vpnmetrics.NewEmpty()
+	}
+
+	if cfg.IPPool != "" {
+		ipGen, err := NewIPGeneratorWithPool(cfg.IPPool)
+		if err != nil {
+			return nil, err
+		}
+		s.ipGen = ipGen
 	}
 
 	defaultNetworkIfcs, err := netutil.DefaultNetworkInterface()
@@ -63,6 +85,13 @@ func NewServer(cfg ServerConfig, appCl *app.Client) (*Server, error) {
 
 	fmt.Printf("Got IPs of interface %s: %v\n", defaultNetworkIfc, defaultNetworkIfcIPs)
 
+	// settings left by a killed server would be taken for the original ones
+	if cfg.StateFile != "" {
+		if err := RestoreSystemState(cfg.StateFile); err != nil {
+			print(fmt.Sprintf("Error restoring system state: %v\n", err))
+		}
+	}
+
 	ipv4ForwardingVal, err := GetIPv4ForwardingValue()
 	if err != nil {
 		return nil, fmt.Errorf("error getting IPv4 forwarding value: %w", err)
@@ -93,9 +122,18 @@ func NewServer(cfg ServerConfig, appCl *app.Client) (*Server, error) {
 
 // Serve accepts connections from `l` and serves them.
 func (s *Server) Serve(l net.Listener) error {
+	return s.ServeAll([]net.Listener{l})
+}
+
+// ServeAll accepts connections from all of `ls` and serves them, sharing client
+// IP space between listeners. It returns once all of the listeners fail.
+func (s *Server) ServeAll(ls []net.Listener) error {
 	serveErr := errors.New("already serving")
 	s.serveOnce.Do(func() {
 		s.setAppStatus(appserver.AppDetailedStatusStarting)
+		s.saveSystemState(false)
+		defer s.removeSystemState()
+
 		if err := EnableIPv4Forwarding(); err != nil {
 			serveErr = fmt.Errorf("error enabling IPv4 forwarding: %w", err)
 			return
@@ -114,6 +152,8 @@ func (s *Server) Serve(l net.Listener) error {
 			s.revertIPv6ForwardingValue()
 		}()
 
+		// saved beforehand, so the rule is removed even if the server is killed right after adding it
+		s.saveSystemState(true)
 		if err := EnableIPMasquerading(s.defaultNetworkInterface); err != nil {
 			serveErr = fmt.Errorf("error enabling IP masquerading for %s: %w", s.defaultNetworkInterface, err)
 			return
@@ -136,26 +176,55 @@ func (s *Server) Serve(l net.Listener) error {
 		}()
 
 		s.lisMx.Lock()
-		s.lis = l
+		s.lis = append(s.lis, ls...)
 		s.lisMx.Unlock()
 		s.setAppStatus(appserver.AppDetailedStatusRunning)
-		for {
-			conn, err := s.lis.Accept()
-			if err != nil {
-				serveErr = fmt.Errorf("failed to accept client connection: %w", err)
-				return
-			}
 
-			go s.serveConn(conn)
+		if s.cfg.TrafficLogInterval > 0 {
+			done := make(chan struct{})
+			defer close(done)
+			go s.logTraffic(s.cfg.TrafficLogInterval, done)
 		}
+
+		serveErr = s.serveListeners(ls)
 	})
 
 	s.setAppError(serveErr)
 	return serveErr
 }
 
+// serveListeners accepts connections from all of `ls` till all of them fail.
+func (s *Server) serveListeners(ls []net.Listener) error {
+	errs := make([]error, len(ls))
+
+	var wg sync.WaitGroup
+	wg.Add(len(ls))
+	for i, l := range ls {
+		go func(i int, l net.Listener) {
+			defer wg.Done()
+
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					errs[i] = fmt.Errorf("failed to accept client connection on %s: %w", l.Addr(), err)
+					return
+				}
+
+				go s.serveConn(conn)
+			}
+		}(i, l)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
 // Close shuts server down.
 func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closeC)
+	})
+
 	s.lisMx.Lock()
 	defer s.lisMx.Unlock()
 
@@ -163,15 +232,22 @@ func (s *Server) Close() error {
 	s.revertIPv6ForwardingValue()
 	s.disableIPMasquerading()
 	s.restoreIPTablesForwardPolicy()
+	s.removeSystemState()
 
-	if s.lis == nil {
-		return nil
-	}
+	return s.closeListeners()
+}
 
-	err := s.lis.Close()
+// closeListeners closes all of the served listeners. Must be called with `lisMx` held.
+func (s *Server) closeListeners() error {
+	var errs []error
+	for _, l := range s.lis {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	s.lis = nil
 
-	return err
+	return errors.Join(errs...)
 }
 
 func (s *Server) revertIPv4ForwardingValue() {
@@ -215,6 +291,23 @@ func (s *Server) closeConn(conn net.Conn) {
 func (s *Server) serveConn(conn net.Conn) {
 	defer s.closeConn(conn)
 
+	s.metrics.RecordAccept()
+
+	delay, ok := s.pacer.reserve()
+	if !ok {
+		print(fmt.Sprintf("Too many clients connecting, asking client %s to reconnect after %v\n", conn.RemoteAddr(), delay))
+		s.sendServerFullHello(conn, delay)
+		return
+	}
+	// clients waiting for their admission slot are dropped once the server is closed
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-s.closeC:
+		return
+	}
+
 	tunIP, tunGateway, allowTrafficToLocalNet, err := s.shakeHands(conn)
 	if err != nil {
 		print(fmt.Sprintf("Error negotiating with client %s: %v\n", conn.RemoteAddr(), err))
@@ -222,6 +315,9 @@ func (s *Server) serveConn(conn net.Conn) {
 	}
 	defer allowTrafficToLocalNet()
 
+	s.metrics.RecordClientConnected()
+	defer s.metrics.RecordClientDisconnected()
+
 	tun, err := newTUNDevice()
 	if err != nil {
 		print(fmt.Sprintf("Error allocating TUN interface: %v\n", err))
@@ -240,12 +336,25 @@ func (s *Server) serveConn(conn net.Conn) {
 		return
 	}
 
+	traffic := newClientTraffic(conn.RemoteAddr().String(), tunIP)
+	s.addClientTraffic(traffic)
+	defer s.removeClientTraffic(traffic)
+
+	s.forward(conn, tun, traffic)
+}
+
+// forward passes traffic between client `conn` and `tun` till either side fails.
+func (s *Server) forward(conn net.Conn, tun TUNDevice, traffic *clientTraffic) {
 	connToTunDoneCh := make(chan struct{})
 	tunToConnCh := make(chan struct{})
 	go func() {
 		defer close(connToTunDoneCh)
 
-		if _, err := io.Copy(tun, conn); err != nil {
+		connToTUN := func(n uint64) {
+			s.metrics.AddBytesIn(n)
+			traffic.connToTUN.add(n)
+		}
+		if _, err := io.Copy(&meteredWriter{w: tun, add: connToTUN}, conn); err != nil {
 			// when the vpn-client is closed we get the error "EOF"
 			if err.Error() != io.EOF.Error() {
 				print(fmt.Sprintf("Error resending traffic from VPN client to TUN %s: %v\n", tun.Name(), err))
@@ -255,7 +364,11 @@ func (s *Server) serveConn(conn net.Conn) {
 	go func() {
 		defer close(tunToConnCh)
 
-		if _, err := io.Copy(conn, tun); err != nil {
+		tunToConn := func(n uint64) {
+			s.metrics.AddBytesOut(n)
+			traffic.tunToConn.add(n)
+		}
+		if _, err := io.Copy(&meteredWriter{w: conn, add: tunToConn}, tun); err != nil {
 			// when the vpn-client is closed we get the error "read tun: file already closed"
 			if err.Error() != "read tun: file already closed" {
 				print(fmt.Sprintf("Error resending traffic from TUN %s to VPN client: %v\n", tun.Name(), err))
@@ -271,8 +384,12 @@ func (s *Server) serveConn(conn net.Conn) {
 }
 
 func (s *Server) shakeHands(conn net.Conn) (tunIP, tunGateway net.IP, unsecureVPN func(), err error) {
+	defer func() { s.metrics.RecordHandshake(err == nil) }()
+
+	// hellos are answered the way clients send them, older releases only send plain ones
 	var cHello ClientHello
-	if err := ReadJSON(conn, &cHello); err != nil {
+	framed, err := ReadHello(conn, &cHello)
+	if err != nil {
 		return nil, nil, nil, fmt.Errorf("error reading client hello: %w", err)
 	}
 
@@ -281,28 +398,34 @@ func (s *Server) shakeHands(conn net.Conn) (tunIP, tunGateway net.IP, unsecureVP
 
 	fmt.Printf("Got client hello: %v", cHello)
 
+	if !cHello.compatible() {
+		s.sendServerErrHello(conn, framed, HandshakeStatusVersionMismatch)
+		return nil, nil, nil, fmt.Errorf("client protocol version %d is incompatible with %d", cHello.Version, protocolVersion)
+	}
+
 	if s.cfg.Passcode != "" && cHello.Passcode != s.cfg.Passcode {
-		s.sendServerErrHello(conn, HandshakeStatusForbidden)
+		s.sendServerErrHello(conn, framed, HandshakeStatusForbidden)
 		return nil, nil, nil, errors.New("got wrong passcode from client")
 	}
 
 	for _, ip := range cHello.UnavailablePrivateIPs {
 		if err := s.ipGen.Reserve(ip); err != nil {
 			// this happens only on malformed IP
-			s.sendServerErrHello(conn, HandshakeStatusBadRequest)
+			s.sendServerErrHello(conn, framed, HandshakeStatusBadRequest)
 			return nil, nil, nil, fmt.Errorf("error reserving IP %s: %w", ip.String(), err)
 		}
 	}
 
 	subnet, err := s.ipGen.Next()
 	if err != nil {
-		s.sendServerErrHello(conn, HandshakeNoFreeIPs)
+		s.metrics.RecordIPPoolExhausted()
+		s.sendServerErrHello(conn, framed, HandshakeNoFreeIPs)
 		return nil, nil, nil, fmt.Errorf("error getting free subnet IP: %w", err)
 	}
 
 	subnetOctets, err := fetchIPv4Octets(subnet)
 	if err != nil {
-		s.sendServerErrHello(conn, HandshakeStatusInternalError)
+		s.sendServerErrHello(conn, framed, HandshakeStatusInternalError)
 		return nil, nil, nil, fmt.Errorf("error breaking IP into octets: %w", err)
 	}
 
@@ -322,7 +445,7 @@ func (s *Server) shakeHands(conn net.Conn) (tunIP, tunGateway net.IP, unsecureVP
 
 	if s.cfg.Secure {
 		if err := BlockIPToLocalNetwork(cTUNIP, sTUNIP); err != nil {
-			s.sendServerErrHello(conn, HandshakeStatusInternalError)
+			s.sendServerErrHello(conn, framed, HandshakeStatusInternalError)
 			return nil, nil, nil,
 				fmt.Errorf("error securing local network for IP %s: %w", cTUNIP, err)
 		}
@@ -340,7 +463,7 @@ func (s *Server) shakeHands(conn net.Conn) (tunIP, tunGateway net.IP, unsecureVP
 		TUNGateway: cTUNGateway,
 	}
 
-	if err := WriteJSON(conn, &sHello); err != nil {
+	if err := WriteHello(conn, &sHello, framed); err != nil {
 		unsecureVPN()
 		return nil, nil, nil, fmt.Errorf("error finishing handshake: error sending server hello: %w", err)
 	}
@@ -360,11 +483,24 @@ func (s *Server) setAppError(appErr error) {
 	}
 }
 
-func (s *Server) sendServerErrHello(conn net.Conn, status HandshakeStatus) {
+func (s *Server) sendServerErrHello(conn net.Conn, framed bool, status HandshakeStatus) {
 	sHello := ServerHello{
 		Status: status,
 	}
 
+	if err := WriteHello(conn, &sHello, framed); err != nil {
+		print(fmt.Sprintf("Error sending server hello: %v\n", err))
+	}
+}
+
+// sendServerFullHello sends a plain hello, since it is sent before the client hello is read.
+// Clients accept both plain and framed hellos.
+func (s *Server) sendServerFullHello(conn net.Conn, reconnectAfter time.Duration) {
+	sHello := ServerHello{
+		Status:         HandshakeStatusServerFull,
+		ReconnectAfter: reconnectAfter,
+	}
+
 	if err := WriteJSON(conn, &sHello); err != nil {
 		print(fmt.Sprintf("Error sending server hello: %v\n", err))
 	}
@@ -386,3 +522,18 @@ func (s *Server) validateInterface(ifcs []string, selectedIfc string) bool {
 	}
 	return false
 }
+
+// meteredWriter reports the amount of bytes written through it.
+type meteredWriter struct {
+	w   io.Writer
+	add func(n uint64)
+}
+
+// Write implements io.Writer.
+func (mw *meteredWriter) Write(p []byte) (int, error) {
+	n, err := mw.w.Write(p)
+	if n > 0 {
+		mw.add(uint64(n))
+	}
+	return n, err
+}
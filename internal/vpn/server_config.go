@@ -1,9 +1,35 @@
 // Package vpn internal/vpn/server_config.go
 package vpn
 
+import (
+	"time"
+
+	"github.com/skycoin/skywire/internal/vpn/vpnmetrics"
+)
+
 // ServerConfig is a configuration for VPN server.
 type ServerConfig struct {
 	Passcode         string
 	Secure           bool
 	NetworkInterface string
+	// AcceptInterval is the minimal interval between two client handshakes.
+	// Zero disables pacing.
+	AcceptInterval time.Duration
+	// AcceptJitter is the max random delay added to each handshake slot.
+	AcceptJitter time.Duration
+	// MaxAcceptDelay is the max time a client may wait for its handshake slot,
+	// clients that would wait longer are told to reconnect later. Zero means no limit.
+	MaxAcceptDelay time.Duration
+	// IPPool is a private IPv4 network in CIDR notation to allocate client subnets from.
+	// Empty means the default private ranges.
+	IPPool string
+	// Metrics collects server metrics. Nil disables metrics collection.
+	Metrics vpnmetrics.Metrics
+	// StateFile is where the original system network settings are kept while
+	// the server runs. Settings left changed by a killed server are restored from
+	// it on start. Empty disables keeping the state.
+	StateFile string
+	// TrafficLogInterval is how often traffic of every client is printed per direction.
+	// Zero disables traffic logging.
+	TrafficLogInterval time.Duration
 }
@@ -3,6 +3,7 @@ package vpn
 
 import (
 	"net"
+	"time"
 )
 
 // ServerHello is a message sent by server during the Client/Server handshake.
@@ -10,4 +11,7 @@ type ServerHello struct {
 	Status     HandshakeStatus `json:"status"`
 	TUNIP      net.IP          `json:"tun_ip"`
 	TUNGateway net.IP          `json:"tun_gateway"`
+	// ReconnectAfter is set along with `HandshakeStatusServerFull`, it's the time
+	// after which the client may try to connect again.
+	ReconnectAfter time.Duration `json:"reconnect_after,omitempty"`
 }
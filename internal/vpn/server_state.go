@@ -0,0 +1,146 @@
+// Package vpn internal/vpn/server_state.go
+package vpn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SystemState holds the system network settings changed by the server, as they
+// were before the change. It is persisted while the server runs, so the settings
+// can be restored after the server is killed.
+type SystemState struct {
+	IPv4Forwarding        string `json:"ipv4_forwarding"`
+	IPv6Forwarding        string `json:"ipv6_forwarding"`
+	IPTablesForwardPolicy string `json:"iptables_forward_policy"`
+	// MasqueradingInterface is set while IP masquerading is enabled for the interface.
+	MasqueradingInterface string `json:"masquerading_interface,omitempty"`
+}
+
+// systemSettings changes the system network settings.
+type systemSettings struct {
+	setIPv4Forwarding        func(val string) error
+	setIPv6Forwarding        func(val string) error
+	setIPTablesForwardPolicy func(policy string) error
+	disableIPMasquerading    func(ifcName string) error
+}
+
+var osSystemSettings = systemSettings{
+	setIPv4Forwarding:        SetIPv4ForwardingValue,
+	setIPv6Forwarding:        SetIPv6ForwardingValue,
+	setIPTablesForwardPolicy: SetIPTablesForwardPolicy,
+	disableIPMasquerading:    DisableIPMasquerading,
+}
+
+// RestoreSystemState reverts the system network settings left changed by a server
+// which did not shut down cleanly, according to the state file at `path`.
+// It does nothing if there is no state file. The state file is removed even
+// if some of the settings fail to restore.
+func RestoreSystemState(path string) error {
+	return restoreSystemState(path, osSystemSettings)
+}
+
+func restoreSystemState(path string, sys systemSettings) error {
+	state, err := readSystemState(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading VPN server state %s: %w", path, err)
+	}
+
+	fmt.Printf("Restoring system state left by VPN server: %+v\n", state)
+
+	var errs []error
+	if state.MasqueradingInterface != "" {
+		if err := sys.disableIPMasquerading(state.MasqueradingInterface); err != nil {
+			errs = append(errs, fmt.Errorf("error disabling IP masquerading for %s: %w", state.MasqueradingInterface, err))
+		}
+	}
+	if state.IPTablesForwardPolicy != "" {
+		if err := sys.setIPTablesForwardPolicy(state.IPTablesForwardPolicy); err != nil {
+			errs = append(errs, fmt.Errorf("error restoring iptables forward policy to %s: %w", state.IPTablesForwardPolicy, err))
+		}
+	}
+	if state.IPv4Forwarding != "" {
+		if err := sys.setIPv4Forwarding(state.IPv4Forwarding); err != nil {
+			errs = append(errs, fmt.Errorf("error reverting IPv4 forwarding: %w", err))
+		}
+	}
+	if state.IPv6Forwarding != "" {
+		if err := sys.setIPv6Forwarding(state.IPv6Forwarding); err != nil {
+			errs = append(errs, fmt.Errorf("error reverting IPv6 forwarding: %w", err))
+		}
+	}
+
+	if err := os.Remove(path); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func readSystemState(path string) (SystemState, error) {
+	var state SystemState
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// writeSystemState replaces the state file at `path` atomically,
+// so a killed server never leaves it partially written. The file is
+// created readable by the owner only.
+func writeSystemState(path string, state SystemState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()           //nolint:errcheck
+		os.Remove(tmp.Name()) //nolint:errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name()) //nolint:errcheck
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// saveSystemState persists the original system settings, so they are restored
+// by `RestoreSystemState` if the server does not shut down cleanly.
+func (s *Server) saveSystemState(masquerading bool) {
+	if s.cfg.StateFile == "" {
+		return
+	}
+	state := SystemState{
+		IPv4Forwarding:        s.ipv4ForwardingVal,
+		IPv6Forwarding:        s.ipv6ForwardingVal,
+		IPTablesForwardPolicy: s.iptablesForwardPolicy,
+	}
+	if masquerading {
+		state.MasqueradingInterface = s.defaultNetworkInterface
+	}
+	if err := writeSystemState(s.cfg.StateFile, state); err != nil {
+		print(fmt.Sprintf("Error saving system state to %s: %v\n", s.cfg.StateFile, err))
+	}
+}
+
+// removeSystemState removes the state file once the system settings are restored.
+func (s *Server) removeSystemState() {
+	if s.cfg.StateFile == "" {
+		return
+	}
+	if err := os.Remove(s.cfg.StateFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		print(fmt.Sprintf("Error removing system state %s: %v\n", s.cfg.StateFile, err))
+	}
+}
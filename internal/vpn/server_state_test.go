@@ -0,0 +1,111 @@
+// Package vpn internal/vpn/server_state_test.go
+package vpn
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSystemSettings records changes of the system settings
+type fakeSystemSettings struct {
+	calls []string
+	err   error
+}
+
+func (f *fakeSystemSettings) settings() systemSettings {
+	record := func(name string) func(string) error {
+		return func(val string) error {
+			f.calls = append(f.calls, name+"="+val)
+			return f.err
+		}
+	}
+	return systemSettings{
+		setIPv4Forwarding:        record("ipv4"),
+		setIPv6Forwarding:        record("ipv6"),
+		setIPTablesForwardPolicy: record("policy"),
+		disableIPMasquerading:    record("masquerading"),
+	}
+}
+
+func TestRestoreSystemState(t *testing.T) {
+	newServer := func(stateFile string) *Server {
+		return &Server{
+			cfg:                     ServerConfig{StateFile: stateFile},
+			defaultNetworkInterface: "eth0",
+			ipv4ForwardingVal:       "0",
+			ipv6ForwardingVal:       "0",
+			iptablesForwardPolicy:   "DROP",
+		}
+	}
+
+	t.Run("no state file", func(t *testing.T) {
+		var sys fakeSystemSettings
+		require.NoError(t, restoreSystemState(filepath.Join(t.TempDir(), "state.json"), sys.settings()))
+		require.Empty(t, sys.calls)
+	})
+
+	t.Run("leftover state", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		// server killed after enabling masquerading
+		newServer(path).saveSystemState(true)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		var sys fakeSystemSettings
+		require.NoError(t, restoreSystemState(path, sys.settings()))
+		require.Equal(t, []string{"masquerading=eth0", "policy=DROP", "ipv4=0", "ipv6=0"}, sys.calls)
+		_, err = os.Stat(path)
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		// restoring is done once
+		sys.calls = nil
+		require.NoError(t, restoreSystemState(path, sys.settings()))
+		require.Empty(t, sys.calls)
+	})
+
+	t.Run("masquerading not enabled", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		newServer(path).saveSystemState(false)
+
+		var sys fakeSystemSettings
+		require.NoError(t, restoreSystemState(path, sys.settings()))
+		require.Equal(t, []string{"policy=DROP", "ipv4=0", "ipv6=0"}, sys.calls)
+	})
+
+	t.Run("failed restore", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		newServer(path).saveSystemState(true)
+
+		sys := fakeSystemSettings{err: errors.New("failed")}
+		require.Error(t, restoreSystemState(path, sys.settings()))
+		// all the settings are tried, stale state is not restored again
+		require.Len(t, sys.calls, 4)
+		_, err := os.Stat(path)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("clean shutdown", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		s := newServer(path)
+		s.saveSystemState(true)
+		s.removeSystemState()
+
+		var sys fakeSystemSettings
+		require.NoError(t, restoreSystemState(path, sys.settings()))
+		require.Empty(t, sys.calls)
+	})
+
+	t.Run("corrupted state file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		require.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+
+		var sys fakeSystemSettings
+		require.Error(t, restoreSystemState(path, sys.settings()))
+		require.Empty(t, sys.calls)
+	})
+}
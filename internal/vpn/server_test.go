@@ -0,0 +1,214 @@
+// Package vpn internal/vpn/server_test.go
+package vpn
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire/internal/vpn/vpnmetrics"
+)
+
+type fakeMetrics struct {
+	accepts           int64
+	handshakesSuccess int64
+	handshakesFailed  int64
+	activeClients     int64
+	poolExhaustions   int64
+	bytesIn           uint64
+	bytesOut          uint64
+}
+
+var _ vpnmetrics.Metrics = (*fakeMetrics)(nil)
+
+func (m *fakeMetrics) RecordAccept() { atomic.AddInt64(&m.accepts, 1) }
+
+func (m *fakeMetrics) RecordHandshake(success bool) {
+	if success {
+		atomic.AddInt64(&m.handshakesSuccess, 1)
+	} else {
+		atomic.AddInt64(&m.handshakesFailed, 1)
+	}
+}
+
+func (m *fakeMetrics) RecordClientConnected()    { atomic.AddInt64(&m.activeClients, 1) }
+func (m *fakeMetrics) RecordClientDisconnected() { atomic.AddInt64(&m.activeClients, -1) }
+func (m *fakeMetrics) RecordIPPoolExhausted()    { atomic.AddInt64(&m.poolExhaustions, 1) }
+func (m *fakeMetrics) AddBytesIn(n uint64)       { atomic.AddUint64(&m.bytesIn, n) }
+func (m *fakeMetrics) AddBytesOut(n uint64)      { atomic.AddUint64(&m.bytesOut, n) }
+
+func newTestServer(cfg ServerConfig, ipGen *IPGenerator) (*Server, *fakeMetrics) {
+	m := &fakeMetrics{}
+	cfg.Metrics = m
+	return &Server{
+		cfg:     cfg,
+		ipGen:   ipGen,
+		pacer:   newAcceptPacer(0, 0, 0),
+		metrics: m,
+		closeC:  make(chan struct{}),
+	}, m
+}
+
+// handshake runs client side of the handshake against `s.serveConn`.
+func handshake(t *testing.T, s *Server, cHello ClientHello) ServerHello {
+	cConn, sConn := net.Pipe()
+	defer cConn.Close() //nolint:errcheck
+
+	go s.serveConn(sConn)
+
+	require.NoError(t, WriteJSON(cConn, &cHello))
+	var sHello ServerHello
+	require.NoError(t, ReadJSON(cConn, &sHello))
+	return sHello
+}
+
+func TestServer_Metrics(t *testing.T) {
+	t.Run("forbidden", func(t *testing.T) {
+		s, m := newTestServer(ServerConfig{Passcode: "1234"}, NewIPGenerator())
+
+		sHello := handshake(t, s, ClientHello{Passcode: "4321"})
+		require.Equal(t, HandshakeStatusForbidden, sHello.Status)
+
+		require.Eventually(t, func() bool { return atomic.LoadInt64(&m.handshakesFailed) == 1 }, time.Second, 10*time.Millisecond)
+		require.EqualValues(t, 1, atomic.LoadInt64(&m.accepts))
+		require.Zero(t, atomic.LoadInt64(&m.handshakesSuccess))
+		require.Zero(t, atomic.LoadInt64(&m.poolExhaustions))
+		require.Zero(t, atomic.LoadInt64(&m.activeClients))
+	})
+
+	t.Run("version mismatch", func(t *testing.T) {
+		s, m := newTestServer(ServerConfig{}, NewIPGenerator())
+
+		sHello := handshake(t, s, ClientHello{Version: protocolVersion + 1})
+		require.Equal(t, HandshakeStatusVersionMismatch, sHello.Status)
+		require.Equal(t, errHandshakeStatusVersionMismatch, sHello.Status.getError())
+
+		require.Eventually(t, func() bool { return atomic.LoadInt64(&m.handshakesFailed) == 1 }, time.Second, 10*time.Millisecond)
+		require.Zero(t, atomic.LoadInt64(&m.handshakesSuccess))
+	})
+
+	t.Run("ip pool exhausted", func(t *testing.T) {
+		ipGen := &IPGenerator{
+			ranges: []*subnetIPIncrementer{
+				newSubnetIPIncrementer([4]uint8{10, 0, 0, 0}, [4]uint8{10, 0, 0, 16}, 8),
+			},
+		}
+		_, err := ipGen.Next()
+		require.NoError(t, err)
+
+		s, m := newTestServer(ServerConfig{}, ipGen)
+
+		sHello := handshake(t, s, ClientHello{})
+		require.Equal(t, HandshakeNoFreeIPs, sHello.Status)
+
+		require.Eventually(t, func() bool { return atomic.LoadInt64(&m.handshakesFailed) == 1 }, time.Second, 10*time.Millisecond)
+		require.EqualValues(t, 1, atomic.LoadInt64(&m.poolExhaustions))
+	})
+
+	t.Run("handshake success", func(t *testing.T) {
+		s, m := newTestServer(ServerConfig{}, NewIPGenerator())
+
+		cConn, sConn := net.Pipe()
+		defer closePipe(t, cConn, sConn)
+
+		go func() {
+			_ = WriteJSON(cConn, &ClientHello{Version: protocolVersion}) //nolint:errcheck
+			var sHello ServerHello
+			_ = ReadJSON(cConn, &sHello) //nolint:errcheck
+		}()
+
+		_, _, _, err := s.shakeHands(sConn)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, atomic.LoadInt64(&m.handshakesSuccess))
+		require.Zero(t, atomic.LoadInt64(&m.handshakesFailed))
+	})
+
+	t.Run("framed hello answered framed", func(t *testing.T) {
+		s, _ := newTestServer(ServerConfig{Passcode: "1234"}, NewIPGenerator())
+
+		cConn, sConn := net.Pipe()
+		defer cConn.Close() //nolint:errcheck
+		go s.serveConn(sConn)
+
+		require.NoError(t, WriteJSONFramed(cConn, &ClientHello{Passcode: "4321"}))
+		var sHello ServerHello
+		require.NoError(t, ReadJSONFramed(cConn, &sHello))
+		require.Equal(t, HandshakeStatusForbidden, sHello.Status)
+	})
+
+	t.Run("paced client dropped on close", func(t *testing.T) {
+		s, m := newTestServer(ServerConfig{}, NewIPGenerator())
+		s.pacer = newAcceptPacer(time.Hour, 0, 0)
+		_, ok := s.pacer.reserve()
+		require.True(t, ok)
+
+		cConn, sConn := net.Pipe()
+		defer cConn.Close() //nolint:errcheck
+		go s.serveConn(sConn)
+		require.Eventually(t, func() bool { return atomic.LoadInt64(&m.accepts) == 1 }, time.Second, 10*time.Millisecond)
+
+		s.closeOnce.Do(func() { close(s.closeC) })
+		require.NoError(t, cConn.SetReadDeadline(time.Now().Add(time.Second)))
+		_, err := cConn.Read(make([]byte, 1))
+		require.ErrorIs(t, err, io.EOF)
+		require.Zero(t, atomic.LoadInt64(&m.handshakesFailed))
+	})
+
+	t.Run("bytes copied", func(t *testing.T) {
+		m := &fakeMetrics{}
+		var buf bytes.Buffer
+
+		w := &meteredWriter{w: &buf, add: m.AddBytesIn}
+		n, err := w.Write([]byte("hello"))
+		require.NoError(t, err)
+		require.Equal(t, 5, n)
+		require.EqualValues(t, 5, atomic.LoadUint64(&m.bytesIn))
+		require.Zero(t, atomic.LoadUint64(&m.bytesOut))
+	})
+}
+
+func TestServer_ServeListeners(t *testing.T) {
+	s, m := newTestServer(ServerConfig{Passcode: "1234"}, NewIPGenerator())
+
+	var ls []net.Listener
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		ls = append(ls, l)
+	}
+
+	s.lisMx.Lock()
+	s.lis = ls
+	s.lisMx.Unlock()
+
+	serveErrCh := make(chan error, 1)
+	go func() { serveErrCh <- s.serveListeners(ls) }()
+
+	for _, l := range ls {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+
+		// wrong passcode gets a response without touching TUN interfaces
+		require.NoError(t, WriteJSON(conn, &ClientHello{Passcode: "4321"}))
+		var sHello ServerHello
+		require.NoError(t, ReadJSON(conn, &sHello))
+		require.Equal(t, HandshakeStatusForbidden, sHello.Status)
+		require.NoError(t, conn.Close())
+	}
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&m.accepts) == 2 }, time.Second, 10*time.Millisecond)
+
+	s.lisMx.Lock()
+	require.NoError(t, s.closeListeners())
+	s.lisMx.Unlock()
+
+	serveErr := <-serveErrCh
+	for _, l := range ls {
+		require.ErrorIs(t, serveErr, net.ErrClosed)
+		require.Contains(t, serveErr.Error(), l.Addr().String())
+	}
+}
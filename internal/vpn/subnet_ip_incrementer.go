@@ -57,8 +57,9 @@ func (inc *subnetIPIncrementer) next() (net.IP, error) {
 					// need to check all of the IPs within the generated subnet.
 					// since we're excluding some of the IPs from generation, these
 					// may be within some of the generated ranges.
-					for i := o4; i < o4+inc.step; i++ {
-						generatedIP[3] = i
+					// iterate over offsets, so the last subnet of the octet doesn't overflow
+					for i := uint8(0); i < inc.step; i++ {
+						generatedIP[3] = o4 + i
 
 						if _, ok := inc.reserved[generatedIP]; ok {
 							isReserved = true
@@ -0,0 +1,124 @@
+// Package vpn internal/vpn/traffic.go
+package vpn
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DirectionStats is the traffic passed in a single direction.
+// Every write to TUN or from it to the client is a single packet.
+type DirectionStats struct {
+	Bytes   uint64 `json:"bytes"`
+	Packets uint64 `json:"packets"`
+}
+
+// ClientTraffic is the traffic of a connected client per direction.
+type ClientTraffic struct {
+	Client      string         `json:"client"`
+	TUNIP       net.IP         `json:"tun_ip"`
+	ConnectedAt time.Time      `json:"connected_at"`
+	ConnToTUN   DirectionStats `json:"conn_to_tun"`
+	TUNToConn   DirectionStats `json:"tun_to_conn"`
+}
+
+// trafficCounter counts traffic passed in a single direction.
+type trafficCounter struct {
+	bytes   atomic.Uint64
+	packets atomic.Uint64
+}
+
+func (c *trafficCounter) add(n uint64) {
+	c.bytes.Add(n)
+	c.packets.Add(1)
+}
+
+func (c *trafficCounter) stats() DirectionStats {
+	return DirectionStats{Bytes: c.bytes.Load(), Packets: c.packets.Load()}
+}
+
+// clientTraffic counts traffic of a single client.
+type clientTraffic struct {
+	client      string
+	tunIP       net.IP
+	connectedAt time.Time
+	connToTUN   trafficCounter
+	tunToConn   trafficCounter
+}
+
+func newClientTraffic(client string, tunIP net.IP) *clientTraffic {
+	return &clientTraffic{client: client, tunIP: tunIP, connectedAt: time.Now()}
+}
+
+func (t *clientTraffic) stats() ClientTraffic {
+	return ClientTraffic{
+		Client:      t.client,
+		TUNIP:       t.tunIP,
+		ConnectedAt: t.connectedAt,
+		ConnToTUN:   t.connToTUN.stats(),
+		TUNToConn:   t.tunToConn.stats(),
+	}
+}
+
+func (s *Server) addClientTraffic(t *clientTraffic) {
+	s.trafficMx.Lock()
+	defer s.trafficMx.Unlock()
+	if s.traffic == nil {
+		s.traffic = make(map[*clientTraffic]struct{})
+	}
+	s.traffic[t] = struct{}{}
+}
+
+func (s *Server) removeClientTraffic(t *clientTraffic) {
+	s.trafficMx.Lock()
+	defer s.trafficMx.Unlock()
+	delete(s.traffic, t)
+}
+
+// Traffic returns traffic of all connected clients, ordered by connection time.
+func (s *Server) Traffic() []ClientTraffic {
+	s.trafficMx.Lock()
+	stats := make([]ClientTraffic, 0, len(s.traffic))
+	for t := range s.traffic {
+		stats = append(stats, t.stats())
+	}
+	s.trafficMx.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].ConnectedAt.Before(stats[j].ConnectedAt)
+	})
+	return stats
+}
+
+// logTraffic prints traffic of all clients every `interval`, along with the amount
+// passed since the previous summary, till `done` is closed.
+func (s *Server) logTraffic(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := make(map[string]ClientTraffic)
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+
+		next := make(map[string]ClientTraffic)
+		for _, t := range s.Traffic() {
+			p := prev[t.Client]
+			if !p.ConnectedAt.Equal(t.ConnectedAt) {
+				// the client reconnected
+				p = ClientTraffic{}
+			}
+			fmt.Printf("Traffic of client %s (TUN IP %s): conn->tun %d B / %d packets (+%d B), tun->conn %d B / %d packets (+%d B)\n",
+				t.Client, t.TUNIP, t.ConnToTUN.Bytes, t.ConnToTUN.Packets, t.ConnToTUN.Bytes-p.ConnToTUN.Bytes,
+				t.TUNToConn.Bytes, t.TUNToConn.Packets, t.TUNToConn.Bytes-p.TUNToConn.Bytes)
+			next[t.Client] = t
+		}
+		prev = next
+	}
+}
@@ -0,0 +1,87 @@
+// Package vpn internal/vpn/traffic_test.go
+package vpn
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTUN is a TUN device backed by a pipe
+type fakeTUN struct {
+	net.Conn
+}
+
+func (fakeTUN) Name() string { return "faketun" }
+
+func TestServer_Traffic(t *testing.T) {
+	s, m := newTestServer(ServerConfig{}, NewIPGenerator())
+
+	clientConn, conn := net.Pipe()
+	tunConn, tunPeer := net.Pipe()
+	defer closePipe(t, clientConn, conn)
+	defer closePipe(t, tunConn, tunPeer)
+
+	traffic := newClientTraffic("client", net.IPv4(192, 168, 1, 4))
+	s.addClientTraffic(traffic)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.forward(conn, fakeTUN{Conn: tunConn}, traffic)
+	}()
+
+	// asymmetric traffic: 3 packets from the client, 1 packet to it
+	packet := bytes.Repeat([]byte{1}, 100)
+	for i := 0; i < 3; i++ {
+		_, err := clientConn.Write(packet)
+		require.NoError(t, err)
+		got := make([]byte, len(packet))
+		_, err = io.ReadFull(tunPeer, got)
+		require.NoError(t, err)
+	}
+	_, err := tunPeer.Write(packet[:10])
+	require.NoError(t, err)
+	got := make([]byte, 10)
+	_, err = io.ReadFull(clientConn, got)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		stats := s.Traffic()
+		return len(stats) == 1 && stats[0].TUNToConn.Packets == 1
+	}, time.Second, 10*time.Millisecond)
+	stats := s.Traffic()[0]
+	require.Equal(t, "client", stats.Client)
+	require.Equal(t, DirectionStats{Bytes: 300, Packets: 3}, stats.ConnToTUN)
+	require.Equal(t, DirectionStats{Bytes: 10, Packets: 1}, stats.TUNToConn)
+	require.EqualValues(t, 300, atomic.LoadUint64(&m.bytesIn))
+	require.EqualValues(t, 10, atomic.LoadUint64(&m.bytesOut))
+
+	// forwarding stops once the client disconnects
+	require.NoError(t, clientConn.Close())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("forwarding did not stop")
+	}
+	s.removeClientTraffic(traffic)
+	require.Empty(t, s.Traffic())
+}
+
+func TestServer_Traffic_Order(t *testing.T) {
+	s, _ := newTestServer(ServerConfig{}, NewIPGenerator())
+	first := newClientTraffic("first", nil)
+	second := newClientTraffic("second", nil)
+	second.connectedAt = first.connectedAt.Add(time.Second)
+	s.addClientTraffic(second)
+	s.addClientTraffic(first)
+
+	stats := s.Traffic()
+	require.Len(t, stats, 2)
+	require.Equal(t, "first", stats[0].Client)
+	require.Equal(t, "second", stats[1].Client)
+}
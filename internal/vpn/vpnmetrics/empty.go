@@ -0,0 +1,31 @@
+// Package vpnmetrics internal/vpn/vpnmetrics/empty.go
+package vpnmetrics
+
+// NewEmpty creates a new metrics implementation that does nothing.
+func NewEmpty() Empty {
+	return Empty{}
+}
+
+// Empty is a `Metrics` implementation which does nothing.
+type Empty struct{}
+
+// RecordAccept implements `Metrics`.
+func (Empty) RecordAccept() {}
+
+// RecordHandshake implements `Metrics`.
+func (Empty) RecordHandshake(bool) {}
+
+// RecordClientConnected implements `Metrics`.
+func (Empty) RecordClientConnected() {}
+
+// RecordClientDisconnected implements `Metrics`.
+func (Empty) RecordClientDisconnected() {}
+
+// RecordIPPoolExhausted implements `Metrics`.
+func (Empty) RecordIPPoolExhausted() {}
+
+// AddBytesIn implements `Metrics`.
+func (Empty) AddBytesIn(uint64) {}
+
+// AddBytesOut implements `Metrics`.
+func (Empty) AddBytesOut(uint64) {}
@@ -0,0 +1,82 @@
+// Package vpnmetrics internal/vpn/vpnmetrics/metrics.go
+package vpnmetrics
+
+import (
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/skycoin/skywire-utilities/pkg/metricsutil"
+)
+
+// Metrics collects VPN server metrics in prometheus format.
+type Metrics interface {
+	RecordAccept()
+	RecordHandshake(success bool)
+	RecordClientConnected()
+	RecordClientDisconnected()
+	RecordIPPoolExhausted()
+	AddBytesIn(n uint64)
+	AddBytesOut(n uint64)
+}
+
+// VictoriaMetrics implements `Metrics` using Victoria Metrics.
+type VictoriaMetrics struct {
+	acceptedConns     *metrics.Counter
+	handshakesSuccess *metrics.Counter
+	handshakesFailed  *metrics.Counter
+	activeClients     *metricsutil.VictoriaMetricsIntGaugeWrapper
+	ipPoolExhaustions *metrics.Counter
+	bytesIn           *metrics.Counter
+	bytesOut          *metrics.Counter
+}
+
+// NewVictoriaMetrics returns the Victoria Metrics implementation of Metrics.
+func NewVictoriaMetrics() *VictoriaMetrics {
+	return &VictoriaMetrics{
+		acceptedConns:     metrics.GetOrCreateCounter("vpn_server_accepted_conns_total"),
+		handshakesSuccess: metrics.GetOrCreateCounter("vpn_server_handshakes_total{success=\"true\"}"),
+		handshakesFailed:  metrics.GetOrCreateCounter("vpn_server_handshakes_total{success=\"false\"}"),
+		activeClients:     metricsutil.NewVictoriaMetricsIntGauge("vpn_server_active_clients"),
+		ipPoolExhaustions: metrics.GetOrCreateCounter("vpn_server_ip_pool_exhausted_total"),
+		bytesIn:           metrics.GetOrCreateCounter("vpn_server_bytes_in_total"),
+		bytesOut:          metrics.GetOrCreateCounter("vpn_server_bytes_out_total"),
+	}
+}
+
+// RecordAccept implements `Metrics`.
+func (m *VictoriaMetrics) RecordAccept() {
+	m.acceptedConns.Inc()
+}
+
+// RecordHandshake implements `Metrics`.
+func (m *VictoriaMetrics) RecordHandshake(success bool) {
+	if success {
+		m.handshakesSuccess.Inc()
+	} else {
+		m.handshakesFailed.Inc()
+	}
+}
+
+// RecordClientConnected implements `Metrics`.
+func (m *VictoriaMetrics) RecordClientConnected() {
+	m.activeClients.Inc()
+}
+
+// RecordClientDisconnected implements `Metrics`.
+func (m *VictoriaMetrics) RecordClientDisconnected() {
+	m.activeClients.Dec()
+}
+
+// RecordIPPoolExhausted implements `Metrics`.
+func (m *VictoriaMetrics) RecordIPPoolExhausted() {
+	m.ipPoolExhaustions.Inc()
+}
+
+// AddBytesIn implements `Metrics`.
+func (m *VictoriaMetrics) AddBytesIn(n uint64) {
+	m.bytesIn.Add(int(n))
+}
+
+// AddBytesOut implements `Metrics`.
+func (m *VictoriaMetrics) AddBytesOut(n uint64) {
+	m.bytesOut.Add(int(n))
+}
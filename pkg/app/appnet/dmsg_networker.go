@@ -49,6 +49,8 @@ func (n *DmsgNetworker) Listen(addr Addr) (net.Listener, error) {
 }
 
 // ListenContext starts listening on local `addr` in the dmsg network with context.
-func (n *DmsgNetworker) ListenContext(_ context.Context, addr Addr) (net.Listener, error) {
-	return n.dmsgC.Listen(uint16(addr.Port))
+func (n *DmsgNetworker) ListenContext(ctx context.Context, addr Addr) (net.Listener, error) {
+	return listenContext(ctx, func() (net.Listener, error) {
+		return n.dmsgC.Listen(uint16(addr.Port))
+	})
 }
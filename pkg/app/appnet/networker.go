@@ -103,3 +103,34 @@ func ListenContext(ctx context.Context, addr Addr) (net.Listener, error) {
 
 	return networker.ListenContext(ctx, addr)
 }
+
+type listenResult struct {
+	lis net.Listener
+	err error
+}
+
+// listenContext runs `listen` until it returns or `ctx` is done. If `ctx` is done first,
+// the listener created afterwards is closed, so it doesn't leak.
+func listenContext(ctx context.Context, listen func() (net.Listener, error)) (net.Listener, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	resCh := make(chan listenResult, 1)
+	go func() {
+		lis, err := listen()
+		resCh <- listenResult{lis: lis, err: err}
+	}()
+
+	select {
+	case res := <-resCh:
+		return res.lis, res.err
+	case <-ctx.Done():
+		go func() {
+			if res := <-resCh; res.err == nil {
+				res.lis.Close() //nolint: errcheck, gosec
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
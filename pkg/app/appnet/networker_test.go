@@ -5,6 +5,7 @@ import (
 	"context"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -117,3 +118,61 @@ func prepAddr() Addr {
 		Port:   addrPort,
 	}
 }
+
+// closeTrackingListener is a net.Listener which reports when it's closed.
+type closeTrackingListener struct {
+	net.Listener
+	closed chan struct{}
+}
+
+func (l *closeTrackingListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func TestListenContext(t *testing.T) {
+	t.Run("listen succeeds", func(t *testing.T) {
+		want := &closeTrackingListener{closed: make(chan struct{})}
+		lis, err := listenContext(context.Background(), func() (net.Listener, error) {
+			return want, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, want, lis)
+	})
+
+	t.Run("already cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := listenContext(ctx, func() (net.Listener, error) {
+			t.Fatal("listen must not be called")
+			return nil, nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("cancelled while listening", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// stub blocks, like dmsg client which is still connecting
+		release := make(chan struct{})
+		lis := &closeTrackingListener{closed: make(chan struct{})}
+
+		start := time.Now()
+		_, err := listenContext(ctx, func() (net.Listener, error) {
+			<-release
+			return lis, nil
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), time.Second)
+
+		// listener created after cancellation is closed
+		close(release)
+		select {
+		case <-lis.closed:
+		case <-time.After(time.Second):
+			t.Fatal("listener created after cancellation was not closed")
+		}
+	})
+}
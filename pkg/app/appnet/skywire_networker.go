@@ -108,6 +108,10 @@ func (r *SkywireNetworker) Listen(addr Addr) (net.Listener, error) {
 func (r *SkywireNetworker) ListenContext(ctx context.Context, addr Addr) (net.Listener, error) {
 	const bufSize = 1000000
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	lis := &skywireListener{
 		addr:     addr,
 		connsCh:  make(chan net.Conn, bufSize),
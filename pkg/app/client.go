@@ -2,6 +2,7 @@
 package app
 
 import (
+	"fmt"
 	"io"
 	"net"
 	"net/rpc"
@@ -163,6 +164,30 @@ func (c *Client) Listen(n appnet.Type, port routing.Port) (net.Listener, error)
 	return listener, nil
 }
 
+// ListenRange listens on all ports from `startPort` to `endPort` inclusive and
+// returns a single listener accepting connections to any of them.
+func (c *Client) ListenRange(n appnet.Type, startPort, endPort routing.Port) (*MultiListener, error) {
+	if startPort > endPort {
+		return nil, fmt.Errorf("invalid port range %d-%d", startPort, endPort)
+	}
+
+	listeners := make([]net.Listener, 0, int(endPort-startPort)+1)
+	for port := int(startPort); port <= int(endPort); port++ {
+		lis, err := c.Listen(n, routing.Port(port))
+		if err != nil {
+			for _, lis := range listeners {
+				if err := lis.Close(); err != nil {
+					c.log.WithError(err).Error("Unexpected error while closing listener.")
+				}
+			}
+			return nil, fmt.Errorf("listen on port %d: %w", port, err)
+		}
+		listeners = append(listeners, lis)
+	}
+
+	return NewMultiListener(listeners...), nil
+}
+
 // Close closes client/server communication entirely. It closes all open
 // listeners and connections.
 func (c *Client) Close() {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
@@ -218,6 +219,77 @@ func TestClient_Listen(t *testing.T) {
 	})
 }
 
+func TestClient_ListenRange(t *testing.T) {
+	l := logging.MustGetLogger("app2_client")
+	visorPK, _ := cipher.GenerateKeyPair()
+	remotePK, _ := cipher.GenerateKeyPair()
+
+	const startPort, endPort = routing.Port(10), routing.Port(12)
+	localAddr := func(port routing.Port) appnet.Addr {
+		return appnet.Addr{Net: appnet.TypeSkynet, PubKey: visorPK, Port: port}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		stopAccepting := make(chan struct{})
+		rpc := &appserver.MockRPCIngressClient{}
+		for port := startPort; port <= endPort; port++ {
+			lisID, connID := uint16(port), uint16(port)+100
+			remote := appnet.Addr{Net: appnet.TypeSkynet, PubKey: remotePK, Port: port + 1000}
+			rpc.On("Listen", localAddr(port)).Return(lisID, nil)
+			rpc.On("Accept", lisID).Return(connID, remote, nil).Once()
+			// further accepts block until the listeners are closed
+			rpc.On("Accept", lisID).Run(func(mock.Arguments) { <-stopAccepting }).
+				Return(uint16(0), appnet.Addr{}, errors.New("closed"))
+			rpc.On("CloseListener", lisID).Return(nil)
+			rpc.On("CloseConn", connID).Return(nil)
+		}
+		cl := prepClient(l, visorPK, rpc)
+
+		lis, err := cl.ListenRange(appnet.TypeSkynet, startPort, endPort)
+		require.NoError(t, err)
+		require.Len(t, lis.Addrs(), int(endPort-startPort)+1)
+
+		// connections dialed to every port in the range are accepted
+		ports := make(map[routing.Port]bool)
+		for port := startPort; port <= endPort; port++ {
+			conn, err := lis.Accept()
+			require.NoError(t, err)
+			local := conn.LocalAddr().(appnet.Addr)
+			remote := conn.RemoteAddr().(appnet.Addr)
+			require.Equal(t, local.Port+1000, remote.Port)
+			ports[local.Port] = true
+		}
+		require.Len(t, ports, int(endPort-startPort)+1)
+
+		close(stopAccepting)
+		require.NoError(t, lis.Close())
+		for port := startPort; port <= endPort; port++ {
+			rpc.AssertCalled(t, "CloseListener", uint16(port))
+		}
+	})
+
+	t.Run("listen error closes listeners", func(t *testing.T) {
+		listenErr := errors.New("listen error")
+		rpc := &appserver.MockRPCIngressClient{}
+		rpc.On("Listen", localAddr(startPort)).Return(uint16(startPort), nil)
+		rpc.On("Listen", localAddr(startPort+1)).Return(uint16(0), listenErr)
+		rpc.On("CloseListener", uint16(startPort)).Return(nil)
+		cl := prepClient(l, visorPK, rpc)
+
+		lis, err := cl.ListenRange(appnet.TypeSkynet, startPort, endPort)
+		require.ErrorIs(t, err, listenErr)
+		require.Nil(t, lis)
+		rpc.AssertCalled(t, "CloseListener", uint16(startPort))
+		rpc.AssertNotCalled(t, "Listen", localAddr(endPort))
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		cl := prepClient(l, visorPK, &appserver.MockRPCIngressClient{})
+		_, err := cl.ListenRange(appnet.TypeSkynet, endPort, startPort)
+		require.Error(t, err)
+	})
+}
+
 func TestClient_Close(t *testing.T) {
 	l := logging.MustGetLogger("app2_client")
 	visorPK, _ := cipher.GenerateKeyPair()
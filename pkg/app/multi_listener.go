@@ -0,0 +1,108 @@
+// Package app pkg/app/multi_listener.go
+package app
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// MultiListener accepts connections of several listeners in a single `Accept` loop.
+// Implements `net.Listener`.
+type MultiListener struct {
+	listeners []net.Listener
+	accepted  chan acceptResult
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// NewMultiListener starts accepting connections of all `listeners`, which are
+// owned by the returned listener and closed along with it.
+func NewMultiListener(listeners ...net.Listener) *MultiListener {
+	l := &MultiListener{
+		listeners: listeners,
+		accepted:  make(chan acceptResult),
+		done:      make(chan struct{}),
+	}
+	l.wg.Add(len(listeners))
+	for _, lis := range listeners {
+		go l.accept(lis)
+	}
+	go func() {
+		l.wg.Wait()
+		close(l.accepted)
+	}()
+	return l
+}
+
+// accept passes connections of `lis` to `Accept` until `lis` fails.
+func (l *MultiListener) accept(lis net.Listener) {
+	defer l.wg.Done()
+	for {
+		conn, err := lis.Accept()
+		select {
+		case l.accepted <- acceptResult{conn: conn, err: err}:
+		case <-l.done:
+			if conn != nil {
+				conn.Close() //nolint:errcheck
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Accept accepts a connection from any of the listeners. An error of a single
+// listener is returned once, while the other listeners keep accepting.
+// `net.ErrClosed` is returned once all listeners stopped or the listener is closed.
+func (l *MultiListener) Accept() (net.Conn, error) {
+	select {
+	case res, ok := <-l.accepted:
+		if !ok {
+			return nil, net.ErrClosed
+		}
+		return res.conn, res.err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes all listeners and returns the first error of them.
+func (l *MultiListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		for _, lis := range l.listeners {
+			// listeners which stopped on their own are already closed
+			if cErr := lis.Close(); cErr != nil && !errors.Is(cErr, net.ErrClosed) && err == nil {
+				err = cErr
+			}
+		}
+	})
+	return err
+}
+
+// Addr returns address of the first listener, nil if there are none.
+func (l *MultiListener) Addr() net.Addr {
+	if len(l.listeners) == 0 {
+		return nil
+	}
+	return l.listeners[0].Addr()
+}
+
+// Addrs returns addresses of all listeners.
+func (l *MultiListener) Addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(l.listeners))
+	for _, lis := range l.listeners {
+		addrs = append(addrs, lis.Addr())
+	}
+	return addrs
+}
@@ -0,0 +1,62 @@
+// Package app pkg/app/multi_listener_test.go
+package app
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiListener(t *testing.T) {
+	const n = 3
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		listeners = append(listeners, lis)
+	}
+	multi := NewMultiListener(listeners...)
+	require.Equal(t, listeners[0].Addr(), multi.Addr())
+	require.Len(t, multi.Addrs(), n)
+
+	// connections dialed to every listener are accepted
+	accepted := make(map[string]bool)
+	for _, lis := range listeners {
+		conn, err := net.Dial("tcp", lis.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close() //nolint:errcheck
+
+		aConn, err := multi.Accept()
+		require.NoError(t, err)
+		defer aConn.Close() //nolint:errcheck
+		accepted[aConn.LocalAddr().String()] = true
+	}
+	require.Len(t, accepted, n)
+
+	// a stopped listener does not affect the others
+	require.NoError(t, listeners[0].Close())
+	_, err := multi.Accept()
+	require.Error(t, err)
+	conn, err := net.Dial("tcp", listeners[1].Addr().String())
+	require.NoError(t, err)
+	defer conn.Close() //nolint:errcheck
+	aConn, err := multi.Accept()
+	require.NoError(t, err)
+	require.NoError(t, aConn.Close())
+
+	// close unblocks pending accept
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := multi.Accept()
+		errCh <- err
+	}()
+	require.NoError(t, multi.Close())
+	select {
+	case err := <-errCh:
+		require.ErrorIs(t, err, net.ErrClosed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("accept was not unblocked by close")
+	}
+}
@@ -21,10 +21,17 @@ type DmsgConfig struct {
 	ConnectedServersType string        `json:"servers_type"`
 }
 
-// New makes new dmsg client from configuration
-func New(pk cipher.PubKey, sk cipher.SecKey, eb *appevent.Broadcaster, conf *DmsgConfig, httpC *http.Client, masterLogger *logging.MasterLogger) *dmsg.Client {
+// New makes new dmsg client from configuration. The client keeps a single
+// session with delegated servers, unless it is configured to connect to all
+// the servers, the rest of configured sessions is kept by Sessions
+func New(pk cipher.PubKey, sk cipher.SecKey, eb *appevent.Broadcaster, conf *DmsgConfig, httpC *http.Client, masterLogger *logging.MasterLogger) (*dmsg.Client, *Sessions) {
+	minSessions := conf.SessionsCount
+	if minSessions > 1 {
+		minSessions = 1
+	}
+	dc := disc.NewHTTP(conf.Discovery, httpC, masterLogger.PackageLogger("dmsgC:disc"))
 	dmsgConf := &dmsg.Config{
-		MinSessions: conf.SessionsCount,
+		MinSessions: minSessions,
 		Callbacks: &dmsg.ClientCallbacks{
 			OnSessionDial: func(network, addr string) error {
 				data := appevent.TCPDialData{RemoteNet: network, RemoteAddr: addr}
@@ -42,8 +49,9 @@ func New(pk cipher.PubKey, sk cipher.SecKey, eb *appevent.Broadcaster, conf *Dms
 		ConnectedServersType: conf.ConnectedServersType,
 	}
 	dmsgConf.ClientType = "visor"
-	dmsgC := dmsg.NewClient(pk, sk, disc.NewHTTP(conf.Discovery, httpC, masterLogger.PackageLogger("dmsgC:disc")), dmsgConf)
+	dmsgC := dmsg.NewClient(pk, sk, dc, dmsgConf)
 	dmsgC.SetLogger(masterLogger.PackageLogger("dmsgC"))
 	dmsgC.SetMasterLogger(masterLogger)
-	return dmsgC
+	sessions := NewSessions(dmsgC, dc, conf, masterLogger.PackageLogger("dmsgC:sessions"))
+	return dmsgC, sessions
 }
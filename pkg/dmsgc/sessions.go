@@ -0,0 +1,176 @@
+// Package dmsgc pkg/dmsgc/sessions.go
+package dmsgc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/skycoin/dmsg/pkg/disc"
+	"github.com/skycoin/dmsg/pkg/dmsg"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+)
+
+// sessionsCheckInterval is how often the number of sessions is checked
+const sessionsCheckInterval = 30 * time.Second
+
+var (
+	// ErrInvalidSessionsCount is returned when less than a single session is requested
+	ErrInvalidSessionsCount = errors.New("dmsg sessions count has to be at least 1")
+	// ErrAllServersSessions is returned when the client connects to all the
+	// servers, which does not change at runtime
+	ErrAllServersSessions = errors.New("dmsg client connects to all servers")
+)
+
+// Sessions keeps the target number of sessions of dmsg client with delegated
+// servers. dmsg client only keeps the number of sessions it was created with,
+// so Sessions dials the sessions over that number and closes the surplus ones
+// when the target is lowered. Sessions carrying streams are not closed until
+// the streams are done.
+type Sessions struct {
+	dmsgC       *dmsg.Client
+	dc          disc.APIClient
+	serversType string
+	log         *logging.Logger
+	interval    time.Duration
+
+	mx     sync.Mutex
+	target int
+	update chan struct{}
+}
+
+// NewSessions creates Sessions keeping the configured number of sessions of dmsgC
+func NewSessions(dmsgC *dmsg.Client, dc disc.APIClient, conf *DmsgConfig, log *logging.Logger) *Sessions {
+	return &Sessions{
+		dmsgC:       dmsgC,
+		dc:          dc,
+		serversType: conf.ConnectedServersType,
+		log:         log,
+		interval:    sessionsCheckInterval,
+		target:      conf.SessionsCount,
+		update:      make(chan struct{}, 1),
+	}
+}
+
+// Target returns the number of sessions kept, zero if the client
+// connects to all the servers
+func (s *Sessions) Target() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.target
+}
+
+// SetTarget changes the number of sessions kept. Sessions are dialed or closed
+// in the background
+func (s *Sessions) SetTarget(n int) error {
+	if n < 1 {
+		return ErrInvalidSessionsCount
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.target == 0 {
+		return ErrAllServersSessions
+	}
+	s.target = n
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Serve keeps the target number of sessions until ctx is done
+func (s *Sessions) Serve(ctx context.Context) {
+	if s.Target() == 0 {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.adjust(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.update:
+		}
+	}
+}
+
+// adjust dials or closes sessions to reach the target
+func (s *Sessions) adjust(ctx context.Context) {
+	target := s.Target()
+	sessions := s.dmsgC.AllSessions()
+	switch {
+	case len(sessions) < target:
+		s.dial(ctx, target-len(sessions))
+	case len(sessions) > target:
+		s.shed(sessions, len(sessions)-target)
+	}
+}
+
+// dial establishes up to n new sessions with available servers
+func (s *Sessions) dial(ctx context.Context, n int) {
+	entries, err := s.dc.AvailableServers(ctx)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to discover dmsg servers")
+		return
+	}
+	rand.Shuffle(len(entries), func(i, j int) {
+		entries[i], entries[j] = entries[j], entries[i]
+	})
+	for _, entry := range entries {
+		if n == 0 || ctx.Err() != nil {
+			return
+		}
+		if !s.ofServersType(entry) {
+			continue
+		}
+		if _, ok := s.dmsgC.Session(entry.Static); ok {
+			continue
+		}
+		if err := s.dmsgC.EnsureSession(ctx, entry); err != nil {
+			s.log.WithError(err).Debugf("Failed to establish session with %s", entry.Static)
+			continue
+		}
+		n--
+	}
+	if n > 0 {
+		s.log.Debugf("Not enough dmsg servers available, %d sessions missing", n)
+	}
+}
+
+// ofServersType is true for the servers dmsg client connects to
+func (s *Sessions) ofServersType(entry *disc.Entry) bool {
+	switch s.serversType {
+	case "official", "community":
+		return entry.Server.ServerType == s.serversType
+	default:
+		return true
+	}
+}
+
+// shed closes up to n sessions which carry no streams
+func (s *Sessions) shed(sessions []dmsg.ClientSession, n int) {
+	busy := make(map[cipher.PubKey]bool)
+	for _, stream := range s.dmsgC.AllStreams() {
+		busy[stream.ServerPK()] = true
+	}
+	for _, session := range sessions {
+		if n == 0 {
+			return
+		}
+		if busy[session.RemotePK()] {
+			continue
+		}
+		s.log.Debugf("Closing session with %s", session.RemotePK())
+		if err := session.Close(); err != nil {
+			s.log.WithError(err).Warnf("Failed to close session with %s", session.RemotePK())
+		}
+		n--
+	}
+}
@@ -0,0 +1,81 @@
+// Package dmsgc pkg/dmsgc/sessions_test.go
+package dmsgc
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/pkg/dmsg"
+	"github.com/skycoin/dmsg/pkg/dmsgtest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+)
+
+func sessionsCount(dmsgC *dmsg.Client) func() int {
+	return func() int { return len(dmsgC.AllSessions()) }
+}
+
+func TestSessions(t *testing.T) {
+	const port = 10
+
+	env := dmsgtest.NewEnv(t, 10*time.Second)
+	require.NoError(t, env.Startup(0, 3, 0, &dmsg.Config{MinSessions: 1}))
+	t.Cleanup(env.Shutdown)
+
+	dmsgC, err := env.NewClient(&dmsg.Config{MinSessions: 1})
+	require.NoError(t, err)
+	remote, err := env.NewClient(&dmsg.Config{MinSessions: 1})
+	require.NoError(t, err)
+
+	sessions := NewSessions(dmsgC, env.Discovery(), &DmsgConfig{SessionsCount: 1}, logging.MustGetLogger("dmsgc_sessions"))
+	sessions.interval = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sessions.Serve(ctx)
+
+	require.ErrorIs(t, sessions.SetTarget(0), ErrInvalidSessionsCount)
+
+	require.NoError(t, sessions.SetTarget(3))
+	require.Equal(t, 3, sessions.Target())
+	require.Eventually(t, func() bool { return sessionsCount(dmsgC)() == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	lis, err := remote.Listen(port)
+	require.NoError(t, err)
+	defer lis.Close() //nolint:errcheck
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		io.Copy(conn, conn) //nolint:errcheck
+	}()
+	// dials fail while sessions of the remote are still being set up
+	var stream *dmsg.Stream
+	require.Eventually(t, func() bool {
+		stream, err = dmsgC.DialStream(ctx, dmsg.Addr{PK: remote.LocalPK(), Port: port})
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	defer stream.Close() //nolint:errcheck
+
+	// the session carrying the stream is kept
+	require.NoError(t, sessions.SetTarget(1))
+	require.Eventually(t, func() bool { return sessionsCount(dmsgC)() == 1 }, 5*time.Second, 10*time.Millisecond)
+	_, ok := dmsgC.Session(stream.ServerPK())
+	require.True(t, ok)
+
+	_, err = stream.Write([]byte("ping"))
+	require.NoError(t, err)
+	echo := make([]byte, 4)
+	_, err = io.ReadFull(stream, echo)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(echo))
+}
+
+func TestSessions_AllServers(t *testing.T) {
+	sessions := NewSessions(nil, nil, &DmsgConfig{SessionsCount: 0}, logging.MustGetLogger("dmsgc_sessions"))
+	require.ErrorIs(t, sessions.SetTarget(2), ErrAllServersSessions)
+	require.Zero(t, sessions.Target())
+}
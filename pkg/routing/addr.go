@@ -4,6 +4,7 @@ package routing
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
@@ -31,34 +32,53 @@ func (a Addr) String() string {
 }
 
 // Set implements pflag.Value for Addr.
+// Address without a colon sets only the public key. Empty port and the "~" sentinel
+// used for unset ports both set port to 0. On error a is left unchanged.
 func (a *Addr) Set(s string) error {
-	parts := strings.Split(s, ":")
-	for i, part := range parts {
-		parts[i] = strings.TrimSpace(part)
-	}
-	switch len(parts) {
-	case 0:
-		a.PubKey = cipher.PubKey{}
-		a.Port = 0
-		return nil
-	case 1:
-		return a.PubKey.Set(parts[0])
-	case 2:
-		if parts[0] == "" {
-			a.PubKey = cipher.PubKey{}
-		} else {
-			if err := a.PubKey.Set(parts[0]); err != nil {
-				return err
-			}
-		}
-		if parts[1] == "~" || parts[1] == "" {
-			a.Port = 0
-		} else {
-			_, err := fmt.Sscan(parts[1], &a.Port)
+	if !strings.Contains(s, ":") {
+		var pk cipher.PubKey
+		if err := pk.Set(strings.TrimSpace(s)); err != nil {
 			return err
 		}
+		a.PubKey = pk
 		return nil
-	default:
-		return errors.New("invalid dmsg.Addr string")
 	}
+	pk, port, err := splitAddr(s)
+	if err != nil {
+		return err
+	}
+	a.PubKey, a.Port = pk, port
+	return nil
+}
+
+// ErrInvalidAddr is returned on attempt to parse a malformed address.
+var ErrInvalidAddr = errors.New("invalid skywire address")
+
+// splitAddr parses address of the "<pk>:<port>" form. Port is split at the last colon,
+// so that addresses with extra colons fail to parse as a public key rather than
+// being cut in an arbitrary place.
+func splitAddr(s string) (cipher.PubKey, Port, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return cipher.PubKey{}, 0, fmt.Errorf("%w %q: missing port", ErrInvalidAddr, s)
+	}
+	pkStr, portStr := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+
+	var pk cipher.PubKey
+	if strings.Contains(pkStr, ":") {
+		return cipher.PubKey{}, 0, fmt.Errorf("%w %q: public key contains colons", ErrInvalidAddr, s)
+	}
+	// empty and all zero public keys are parsed as null public key
+	if err := pk.UnmarshalText([]byte(pkStr)); err != nil {
+		return cipher.PubKey{}, 0, fmt.Errorf("%w %q: %v", ErrInvalidAddr, s, err)
+	}
+
+	if portStr == "" || portStr == "~" {
+		return pk, 0, nil
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return cipher.PubKey{}, 0, fmt.Errorf("%w %q: bad port: %v", ErrInvalidAddr, s, err)
+	}
+	return pk, Port(port), nil
 }
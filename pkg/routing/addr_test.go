@@ -0,0 +1,72 @@
+// Package routing pkg/routing/addr_test.go
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+func TestAddr_Set(t *testing.T) {
+	pk, _ := cipher.GenerateKeyPair()
+
+	tests := []struct {
+		name    string
+		s       string
+		want    Addr
+		wantErr bool
+	}{
+		{name: "pk and port", s: pk.Hex() + ":8", want: Addr{PubKey: pk, Port: 8}},
+		{name: "unset port sentinel", s: pk.Hex() + ":~", want: Addr{PubKey: pk}},
+		{name: "empty port", s: pk.Hex() + ":", want: Addr{PubKey: pk}},
+		{name: "spaces", s: " " + pk.Hex() + " : 8 ", want: Addr{PubKey: pk, Port: 8}},
+		{name: "empty pk", s: ":8", want: Addr{Port: 8}},
+		{name: "pk only", s: pk.Hex(), want: Addr{PubKey: pk}},
+		{name: "extra colons", s: "[::1]:" + pk.Hex() + ":8", wantErr: true},
+		{name: "ipv6 address", s: "[::1]:8", wantErr: true},
+		{name: "bad pk", s: "zz:8", wantErr: true},
+		{name: "port out of range", s: pk.Hex() + ":65536", wantErr: true},
+		{name: "negative port", s: pk.Hex() + ":-1", wantErr: true},
+		{name: "trailing garbage in port", s: pk.Hex() + ":8x", wantErr: true},
+		{name: "garbage", s: "~", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			orig := Addr{PubKey: pk, Port: 1}
+			a := orig
+			err := a.Set(tc.s)
+			if tc.wantErr {
+				require.Error(t, err)
+				require.Equal(t, orig, a, "address changed on error")
+				return
+			}
+			require.NoError(t, err)
+			if tc.s == pk.Hex() {
+				// port is kept when only pk is given
+				tc.want.Port = orig.Port
+			}
+			require.Equal(t, tc.want, a)
+		})
+	}
+}
+
+func FuzzAddr_Set(f *testing.F) {
+	pk, _ := cipher.GenerateKeyPair()
+	for _, s := range []string{pk.Hex() + ":8", pk.Hex() + ":~", ":", "::", "[::1]:8", "~", "a:b:c", ""} {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		var a Addr
+		if err := a.Set(s); err != nil {
+			return
+		}
+		// parsed addresses survive a round trip
+		var b Addr
+		require.NoError(t, b.Set(a.String()))
+		require.Equal(t, a, b)
+	})
+}
@@ -27,6 +27,7 @@ const (
 	// Transport port constants.
 
 	TransportPort     uint16 = 45   // TransportPort Listening port of a visor for incoming transports.
+	TransportPingPort uint16 = 49   // TransportPingPort Listening port of a visor for network latency probes. Like TransportPort, it is a port of network clients, so a dmsg port on dmsg.
 	PublicAutoconnect        = true // PublicAutoconnect ...
 
 	// Dmsgpty constants.
@@ -40,13 +41,14 @@ const (
 
 	// Default skywire app constants.
 
-	SkychatName         = "skychat"  // SkychatName ...
-	SkychatPort  uint16 = 1          // SkychatPort ...
-	SkychatAddr         = ":8001"    // SkychatAddr ...
-	PingTestName        = "pingtest" // PingTestName ...
-	PingTestPort uint16 = 2          // PingTestPort ...
-	SkysocksName        = "skysocks" // SkysocksName ...
-	SkysocksPort uint16 = 3          // SkysocksPort ...
+	SkychatName              = "skychat"  // SkychatName ...
+	SkychatPort       uint16 = 1          // SkychatPort ...
+	SkychatFramedPort uint16 = 4          // SkychatFramedPort Listening port of skychat for conns carrying length-prefixed frames, SkychatPort ones carry plain text.
+	SkychatAddr              = ":8001"    // SkychatAddr ...
+	PingTestName             = "pingtest" // PingTestName ...
+	PingTestPort      uint16 = 2          // PingTestPort ...
+	SkysocksName             = "skysocks" // SkysocksName ...
+	SkysocksPort      uint16 = 3          // SkysocksPort ...
 
 	SkysocksClientName        = "skysocks-client" // SkysocksClientName ...
 	SkysocksClientPort uint16 = 13                // SkysocksClientPort ...
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 	"time"
 
@@ -23,6 +24,7 @@ import (
 
 const reconnectPhaseDelay = 10 * time.Second
 const reconnectRemoteTimeout = 3 * time.Second
+const networkCheckInterval = time.Second
 
 // PersistentTransports is a persistent transports description
 type PersistentTransports struct {
@@ -38,8 +40,19 @@ type ManagerConfig struct {
 	LogStore                  LogStore
 	PersistentTransportsCache []PersistentTransports
 	PTpsCacheMu               sync.RWMutex
+	OnNetworkAdded            func(netType network.Type) // called after a network is added at runtime
+	OnNetworkRemoved          func(netType network.Type) // called after a network is removed at runtime
+	OnNetworkDown             func(netType network.Type) // called when a network loses connectivity
+	OnNetworkUp               func(netType network.Type) // called when a network regains connectivity
 }
 
+var (
+	// ErrNetworkAdded is returned when adding a network that the manager already has
+	ErrNetworkAdded = errors.New("network is already added")
+	// ErrNetworkNotAdded is returned when removing a network that the manager does not have
+	ErrNetworkNotAdded = errors.New("network is not added")
+)
+
 // Manager manages Transports.
 type Manager struct {
 	Logger   *logging.Logger
@@ -56,8 +69,20 @@ type Manager struct {
 	readyOnce sync.Once // ensure we only ready once.
 	ready     chan struct{}
 
-	factory    network.ClientFactory
-	netClients map[network.Type]network.Client
+	factory      network.ClientFactory
+	netClients   map[network.Type]network.Client
+	netListeners map[network.Type]network.Listener
+	// netAdded is closed and replaced whenever a network client is added
+	netAdded chan struct{}
+	// echo listeners answering latency probes of remote visors
+	pingListeners map[network.Type]network.Listener
+	quality       *network.QualityScorer
+
+	// networks that are initialized, but have no connectivity
+	downNets  map[network.Type]struct{}
+	downNetMx sync.Mutex
+	// netMonitors stop connectivity monitors of networks and wait for them to return
+	netMonitors map[network.Type]func()
 }
 
 // NewManager creates a Manager with the provided configuration and transport factories.
@@ -66,25 +91,120 @@ func NewManager(log *logging.Logger, arClient addrresolver.APIClient, ebc *appev
 	if log == nil {
 		log = logging.MustGetLogger("tp_manager")
 	}
+	quality := network.NewQualityScorer(0)
+	if factory.Metrics == nil {
+		factory.Metrics = network.NewMetrics()
+	}
+	if factory.RateLimiter == nil {
+		factory.RateLimiter = network.NewRateLimiter(nil)
+	}
+	onConn := factory.OnConn
+	factory.OnConn = func(ev network.ConnEvent) {
+		quality.OnConn(ev)
+		if onConn != nil {
+			onConn(ev)
+		}
+	}
 	tm := &Manager{
-		Logger:     log,
-		Conf:       config,
-		tps:        make(map[uuid.UUID]*ManagedTransport),
-		readCh:     make(chan routing.Packet, 20),
-		done:       make(chan struct{}),
-		ready:      make(chan struct{}),
-		netClients: make(map[network.Type]network.Client),
-		arClient:   arClient,
-		factory:    factory,
-		ebc:        ebc,
+		Logger:        log,
+		Conf:          config,
+		tps:           make(map[uuid.UUID]*ManagedTransport),
+		readCh:        make(chan routing.Packet, 20),
+		done:          make(chan struct{}),
+		ready:         make(chan struct{}),
+		netClients:    make(map[network.Type]network.Client),
+		netListeners:  make(map[network.Type]network.Listener),
+		netAdded:      make(chan struct{}),
+		pingListeners: make(map[network.Type]network.Listener),
+		downNets:      make(map[network.Type]struct{}),
+		netMonitors:   make(map[network.Type]func()),
+		arClient:      arClient,
+		factory:       factory,
+		quality:       quality,
+		ebc:           ebc,
 	}
 	return tm, nil
 }
 
-// InitDmsgClient initilizes the dmsg client and also adds dmsgC to the factory
+// InitDmsgClient initilizes the dmsg client and also adds dmsgC to the factory.
+// Dmsg network is considered down while dmsgC has no sessions to dmsg servers
 func (tm *Manager) InitDmsgClient(ctx context.Context, dmsgC *dmsg.Client) {
 	tm.factory.DmsgC = dmsgC
 	tm.InitClient(ctx, network.DMSG, 0)
+	tm.startMonitor(ctx, network.DMSG, dmsgC.SessionCount, networkCheckInterval)
+}
+
+// startMonitor runs monitorNetwork until the network is removed
+func (tm *Manager) startMonitor(ctx context.Context, netType network.Type, sessions func() int, interval time.Duration) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	tm.mx.Lock()
+	tm.netMonitors[netType] = func() {
+		cancel()
+		<-done
+	}
+	tm.mx.Unlock()
+
+	tm.wg.Add(1)
+	go func() {
+		defer close(done)
+		tm.monitorNetwork(ctx, netType, sessions, interval)
+	}()
+}
+
+// monitorNetwork periodically checks the number of sessions of the network
+// and marks it down while there are none
+func (tm *Manager) monitorNetwork(ctx context.Context, netType network.Type, sessions func() int, interval time.Duration) {
+	defer tm.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			tm.setNetworkUp(netType, sessions() > 0)
+		case <-ctx.Done():
+			return
+		case <-tm.done:
+			return
+		}
+	}
+}
+
+func (tm *Manager) setNetworkUp(netType network.Type, up bool) {
+	tm.downNetMx.Lock()
+	if _, down := tm.downNets[netType]; down != up {
+		tm.downNetMx.Unlock()
+		return
+	}
+	if up {
+		delete(tm.downNets, netType)
+	} else {
+		tm.downNets[netType] = struct{}{}
+	}
+	tm.downNetMx.Unlock()
+
+	if up {
+		tm.Logger.Infof("Network %s is up", netType)
+		if tm.Conf.OnNetworkUp != nil {
+			tm.Conf.OnNetworkUp(netType)
+		}
+		return
+	}
+	tm.Logger.Warnf("Network %s is down", netType)
+	if tm.Conf.OnNetworkDown != nil {
+		tm.Conf.OnNetworkDown(netType)
+	}
+}
+
+// IsNetworkReady returns true when the network is initialized and is not down
+func (tm *Manager) IsNetworkReady(netType network.Type) bool {
+	if !tm.IsKnownNetwork(netType) {
+		return false
+	}
+	tm.downNetMx.Lock()
+	defer tm.downNetMx.Unlock()
+	_, down := tm.downNets[netType]
+	return !down
 }
 
 // Serve starts all network clients and starts accepting connections
@@ -153,6 +273,7 @@ func (tm *Manager) InitClient(ctx context.Context, netType network.Type, port in
 	}
 	tm.mx.Lock()
 	tm.netClients[netType] = client
+	tm.notifyNetworkAdded()
 	tm.mx.Unlock()
 	tm.runClient(ctx, netType)
 
@@ -161,11 +282,142 @@ func (tm *Manager) InitClient(ctx context.Context, netType network.Type, port in
 	tm.readyOnce.Do(func() { close(tm.ready) })
 }
 
+// AddNetwork initializes a client of the given network while the manager is
+// running and starts accepting transports over it
+func (tm *Manager) AddNetwork(ctx context.Context, netType network.Type, port int) error {
+	if tm.isClosing() {
+		return io.ErrClosedPipe
+	}
+	tm.mx.Lock()
+	if _, ok := tm.netClients[netType]; ok {
+		tm.mx.Unlock()
+		return fmt.Errorf("%w: %s", ErrNetworkAdded, netType)
+	}
+	client, err := tm.factory.MakeClient(netType, port)
+	if err != nil {
+		tm.mx.Unlock()
+		return err
+	}
+	tm.netClients[netType] = client
+	tm.notifyNetworkAdded()
+	tm.mx.Unlock()
+	tm.runClient(ctx, netType)
+
+	tm.readyOnce.Do(func() { close(tm.ready) })
+	if tm.Conf.OnNetworkAdded != nil {
+		tm.Conf.OnNetworkAdded(netType)
+	}
+	return nil
+}
+
+// RemoveNetwork closes transports of the given network, stops accepting new
+// ones and closes its client
+func (tm *Manager) RemoveNetwork(netType network.Type) error {
+	if tm.isClosing() {
+		return io.ErrClosedPipe
+	}
+	tm.mx.Lock()
+	client, ok := tm.netClients[netType]
+	if !ok {
+		tm.mx.Unlock()
+		return fmt.Errorf("%w: %s", ErrNetworkNotAdded, netType)
+	}
+	lis := tm.netListeners[netType]
+	pingLis := tm.pingListeners[netType]
+	delete(tm.netClients, netType)
+	delete(tm.netListeners, netType)
+	delete(tm.pingListeners, netType)
+	stopMonitor := tm.netMonitors[netType]
+	delete(tm.netMonitors, netType)
+	var tps []*ManagedTransport
+	for id, tp := range tm.tps {
+		if tp.Entry.Type == netType {
+			tps = append(tps, tp)
+			delete(tm.tps, id)
+		}
+	}
+	tm.mx.Unlock()
+
+	// the monitor is stopped before the state it sets is cleared
+	if stopMonitor != nil {
+		stopMonitor()
+	}
+	tm.downNetMx.Lock()
+	delete(tm.downNets, netType)
+	tm.downNetMx.Unlock()
+
+	for _, tp := range tps {
+		tp.close()
+	}
+	if lis != nil {
+		if err := lis.Close(); err != nil {
+			tm.Logger.WithError(err).Warnf("Failed to close %s listener", netType)
+		}
+	}
+	if pingLis != nil {
+		if err := pingLis.Close(); err != nil {
+			tm.Logger.WithError(err).Warnf("Failed to close %s echo listener", netType)
+		}
+	}
+	if err := client.Close(); err != nil {
+		return fmt.Errorf("close %s client: %w", netType, err)
+	}
+
+	if tm.Conf.OnNetworkRemoved != nil {
+		tm.Conf.OnNetworkRemoved(netType)
+	}
+	return nil
+}
+
 // Ready checks if the transport manager is ready with atleast one transport
 func (tm *Manager) Ready() <-chan struct{} {
 	return tm.ready
 }
 
+// notifyNetworkAdded wakes up goroutines waiting for networks to be added,
+// tm.mx has to be held
+func (tm *Manager) notifyNetworkAdded() {
+	close(tm.netAdded)
+	tm.netAdded = make(chan struct{})
+}
+
+// WaitReady blocks until the client of the network is ready to accept
+// transports, waiting for the network to be added first if needed. Clients
+// not implementing network.ReadyReporter are ready once added
+func (tm *Manager) WaitReady(ctx context.Context, netType network.Type) error {
+	for {
+		tm.mx.RLock()
+		client, ok := tm.netClients[netType]
+		added := tm.netAdded
+		tm.mx.RUnlock()
+		if ok {
+			return tm.waitClientReady(ctx, client)
+		}
+		select {
+		case <-added:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tm.done:
+			return io.ErrClosedPipe
+		}
+	}
+}
+
+func (tm *Manager) waitClientReady(ctx context.Context, client network.Client) error {
+	reporter, ok := client.(network.ReadyReporter)
+	if !ok {
+		return nil
+	}
+	select {
+	case <-reporter.Ready():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-tm.done:
+		return io.ErrClosedPipe
+	}
+}
+
 func (tm *Manager) runClient(ctx context.Context, netType network.Type) {
 	if tm.isClosing() {
 		return
@@ -185,10 +437,31 @@ func (tm *Manager) runClient(ctx context.Context, netType network.Type) {
 		return
 	}
 	tm.Logger.Debugf("listening on network: %s", client.Type())
+	tm.mx.Lock()
+	tm.netListeners[netType] = lis
+	tm.mx.Unlock()
 	if client.Type() != network.DMSG {
 		tm.wg.Add(1)
 	}
 	go tm.acceptTransports(ctx, lis, netType)
+	tm.serveEcho(client)
+}
+
+// serveEcho answers latency probes of remote visors over the client
+func (tm *Manager) serveEcho(client network.Client) {
+	lis, err := client.Listen(skyenv.TransportPingPort)
+	if err != nil {
+		tm.Logger.WithError(err).Warnf("Failed to listen for %s latency probes", client.Type())
+		return
+	}
+	tm.mx.Lock()
+	tm.pingListeners[client.Type()] = lis
+	tm.mx.Unlock()
+	go func() {
+		if err := network.ServeEcho(lis); err != nil && !errors.Is(err, network.ErrListenerClosed) {
+			tm.Logger.WithError(err).Warnf("Stopped answering %s latency probes", client.Type())
+		}
+	}()
 }
 
 func (tm *Manager) acceptTransports(ctx context.Context, lis network.Listener, t network.Type) {
@@ -204,11 +477,8 @@ func (tm *Manager) acceptTransports(ctx context.Context, lis network.Listener, t
 		default:
 			if err := tm.acceptTransport(ctx, lis); err != nil {
 				log := tm.Logger.WithError(err)
-				if errors.Is(err, dmsg.ErrEntityClosed) {
-					log.Debug("Dmsg client stopped serving.")
-					return
-				}
-				if errors.Is(err, io.ErrClosedPipe) {
+				if errors.Is(err, network.ErrListenerClosed) || errors.Is(err, context.Canceled) {
+					log.Debugf("Stopped accepting %s transports.", lis.Network())
 					return
 				}
 				log.Warnf("Failed to accept transport")
@@ -264,8 +534,180 @@ func (tm *Manager) Stcpr() (network.Client, bool) {
 	return c, ok
 }
 
+// Stcp returns stcp client
+func (tm *Manager) Stcp() (network.STCPClient, bool) {
+	tm.mx.Lock()
+	defer tm.mx.Unlock()
+	c, ok := tm.netClients[network.STCP]
+	if !ok {
+		return nil, false
+	}
+	stcpC, ok := c.(network.STCPClient)
+	return stcpC, ok
+}
+
+// DialAny dials remote visor on the given skywire port over the networks
+// in the given order, skipping those that are not initialized, and returns
+// the first established transport. Networks that are down are skipped too.
+// Empty order means network.DefaultDialOrder.
+// The network with the best recent dial quality to the remote is tried first.
+// See network.DialAny for details
+func (tm *Manager) DialAny(ctx context.Context, remote cipher.PubKey, port uint16, order []network.Type, concurrent bool) (network.Transport, error) {
+	if len(order) == 0 {
+		order = network.DefaultDialOrder
+	}
+	if best, ok := tm.quality.BestNetwork(remote); ok {
+		order = preferNetwork(order, best)
+	}
+
+	tm.mx.RLock()
+	clients := make([]network.Client, 0, len(order))
+	tm.downNetMx.Lock()
+	for _, netType := range order {
+		_, down := tm.downNets[netType]
+		if client, ok := tm.netClients[netType]; ok && !down {
+			clients = append(clients, client)
+		}
+	}
+	tm.downNetMx.Unlock()
+	tm.mx.RUnlock()
+
+	return network.DialAny(ctx, clients, remote, port, concurrent)
+}
+
+// DialFrom dials remote visor over the given network with the raw connection
+// bound to localPort. It fails with network.ErrSourcePortUnsupported if the
+// network can't bind outgoing connections
+func (tm *Manager) DialFrom(ctx context.Context, netType network.Type, localPort uint16, remote cipher.PubKey, port uint16) (network.Transport, error) {
+	tm.mx.RLock()
+	client, ok := tm.netClients[netType]
+	tm.mx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNetworkNotAdded, netType)
+	}
+	return network.DialFrom(ctx, client, localPort, remote, port)
+}
+
+// DialWithOptions dials remote visor over the given network with the raw
+// connection bound as given by opts, see network.Dial
+func (tm *Manager) DialWithOptions(ctx context.Context, netType network.Type, remote cipher.PubKey, port uint16, opts network.DialOptions) (network.Transport, error) {
+	tm.mx.RLock()
+	client, ok := tm.netClients[netType]
+	tm.mx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNetworkNotAdded, netType)
+	}
+	return network.Dial(ctx, client, remote, port, opts)
+}
+
+// preferNetwork returns a copy of order with netType moved to the front,
+// if it is present there
+func preferNetwork(order []network.Type, netType network.Type) []network.Type {
+	for i, t := range order {
+		if t == netType {
+			res := make([]network.Type, 0, len(order))
+			res = append(res, t)
+			res = append(res, order[:i]...)
+			return append(res, order[i+1:]...)
+		}
+	}
+	return order
+}
+
+// Metrics returns current metrics of all the networks
+func (tm *Manager) Metrics() map[network.Type]network.NetworkStats {
+	return tm.factory.Metrics.Snapshot()
+}
+
+// SetRateLimit changes bandwidth limit of transports of the network,
+// including the open ones. Zero rates are unlimited
+func (tm *Manager) SetRateLimit(netType network.Type, limit network.RateLimit) {
+	tm.factory.RateLimiter.SetLimit(netType, limit)
+}
+
+// RateLimit returns bandwidth limit of transports of the network
+func (tm *Manager) RateLimit(netType network.Type) network.RateLimit {
+	return tm.factory.RateLimiter.Limit(netType)
+}
+
+// NetworkStatus returns status snapshots of all the networks sorted by type.
+// Networks which do not report status are only reported by type
+func (tm *Manager) NetworkStatus() []network.NetworkStatus {
+	tm.mx.RLock()
+	defer tm.mx.RUnlock()
+	statuses := make([]network.NetworkStatus, 0, len(tm.netClients))
+	for netType, client := range tm.netClients {
+		if reporter, ok := client.(network.StatusReporter); ok {
+			statuses = append(statuses, reporter.Status())
+			continue
+		}
+		statuses = append(statuses, network.NetworkStatus{Network: netType})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Network < statuses[j].Network
+	})
+	return statuses
+}
+
+// PingResult is the round-trip time to a remote visor over a network,
+// or the error probing it
+type PingResult struct {
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// Ping measures round-trip time to the remote visor over the given network
+func (tm *Manager) Ping(ctx context.Context, netType network.Type, remote cipher.PubKey) (time.Duration, error) {
+	tm.mx.RLock()
+	client, ok := tm.netClients[netType]
+	tm.mx.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrNetworkNotAdded, netType)
+	}
+	return network.Ping(ctx, client, remote, skyenv.TransportPingPort)
+}
+
+// PingAll measures round-trip time to the remote visor over all the networks
+// concurrently, so the paths can be compared
+func (tm *Manager) PingAll(ctx context.Context, remote cipher.PubKey) map[network.Type]PingResult {
+	tm.mx.RLock()
+	clients := make([]network.Client, 0, len(tm.netClients))
+	for _, client := range tm.netClients {
+		clients = append(clients, client)
+	}
+	tm.mx.RUnlock()
+
+	var mx sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[network.Type]PingResult, len(clients))
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client network.Client) {
+			defer wg.Done()
+			var res PingResult
+			latency, err := network.Ping(ctx, client, remote, skyenv.TransportPingPort)
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Latency = latency
+			}
+			mx.Lock()
+			results[client.Type()] = res
+			mx.Unlock()
+		}(client)
+	}
+	wg.Wait()
+	return results
+}
+
+// BestNetwork returns the network with the best recent dial quality to the
+// remote visor and false if it was not dialed yet
+func (tm *Manager) BestNetwork(remote cipher.PubKey) (network.Type, bool) {
+	return tm.quality.BestNetwork(remote)
+}
+
 func (tm *Manager) acceptTransport(ctx context.Context, lis network.Listener) error {
-	transport, err := lis.AcceptTransport() // TODO: tcp panic.
+	transport, err := lis.AcceptContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -536,32 +978,45 @@ func (tm *Manager) Local() cipher.PubKey {
 }
 
 // Close closes opened transports, network clients
-// and all service tasks of transport manager
-func (tm *Manager) Close() {
+// and all service tasks of transport manager.
+// Returned error joins failures of closing the clients
+func (tm *Manager) Close() error {
 	select {
 	case <-tm.done:
-		return
+		return nil
 	default:
 	}
 	close(tm.done)
 	tm.mx.Lock()
-	defer tm.mx.Unlock()
 
 	for _, tr := range tm.tps {
 		tr.close()
 	}
-	for _, client := range tm.netClients {
-		err := client.Close()
-		if err != nil {
-			tm.Logger.WithError(err).Warnf("Failed to close %s client", client.Type())
+	for netType, lis := range tm.pingListeners {
+		if err := lis.Close(); err != nil {
+			tm.Logger.WithError(err).Warnf("Failed to close %s echo listener", netType)
 		}
 	}
-	err := tm.arClient.Close()
-	if err != nil {
-		tm.Logger.WithError(err).Warnf("Failed to close arClient")
+	var errs []error
+	for netType, client := range tm.netClients {
+		if err := client.Close(); err != nil {
+			tm.Logger.WithError(err).Warnf("Failed to close %s client", netType)
+			errs = append(errs, fmt.Errorf("close %s client: %w", netType, err))
+		}
+	}
+	if tm.arClient != nil {
+		if err := tm.arClient.Close(); err != nil {
+			tm.Logger.WithError(err).Warnf("Failed to close arClient")
+			errs = append(errs, fmt.Errorf("close arClient: %w", err))
+		}
 	}
+	tm.mx.Unlock()
+
+	// goroutines may still take tm.mx until they see tm.done, e.g. network monitors
+	// calling OnNetworkUp or OnNetworkDown callbacks
 	tm.wg.Wait()
 	close(tm.readCh)
+	return errors.Join(errs...)
 }
 
 func (tm *Manager) isClosing() bool {
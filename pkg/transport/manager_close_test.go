@@ -0,0 +1,54 @@
+// Package transport pkg/transport/manager_close_test.go
+package transport
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+	"github.com/skycoin/skywire/pkg/transport/network"
+)
+
+// closeClient records whether it was closed and fails to close with err.
+type closeClient struct {
+	network.Client
+	netType network.Type
+	closed  bool
+	err     error
+}
+
+func (c *closeClient) Close() error {
+	c.closed = true
+	return c.err
+}
+
+func (c *closeClient) Type() network.Type {
+	return c.netType
+}
+
+func TestManager_CloseJoinsClientErrors(t *testing.T) {
+	errSudph := errors.New("sudph close failure")
+	clients := []*closeClient{
+		{netType: network.STCPR},
+		{netType: network.SUDPH, err: errSudph},
+		{netType: network.DMSG},
+	}
+
+	tm, err := NewManager(logging.MustGetLogger("tp_manager"), nil, nil, &ManagerConfig{}, network.ClientFactory{})
+	require.NoError(t, err)
+	for _, c := range clients {
+		tm.netClients[c.netType] = c
+	}
+
+	err = tm.Close()
+	require.ErrorIs(t, err, errSudph)
+	require.ErrorContains(t, err, string(network.SUDPH))
+	for _, c := range clients {
+		require.True(t, c.closed, c.netType)
+	}
+
+	// closing again is a no-op
+	require.NoError(t, tm.Close())
+}
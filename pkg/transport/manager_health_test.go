@@ -0,0 +1,105 @@
+// Package transport pkg/transport/manager_health_test.go
+package transport
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+	"github.com/skycoin/skywire/pkg/transport/network"
+)
+
+func TestManager_MonitorNetwork(t *testing.T) {
+	var mx sync.Mutex
+	var events []string
+	record := func(event string) func(network.Type) {
+		return func(netType network.Type) {
+			mx.Lock()
+			events = append(events, event+" "+string(netType))
+			mx.Unlock()
+		}
+	}
+	conf := &ManagerConfig{OnNetworkDown: record("down"), OnNetworkUp: record("up")}
+	tm, err := NewManager(logging.MustGetLogger("tp_manager"), nil, nil, conf, network.ClientFactory{})
+	require.NoError(t, err)
+	tm.netClients[network.DMSG] = &closeClient{netType: network.DMSG}
+	require.True(t, tm.IsNetworkReady(network.DMSG))
+	require.False(t, tm.IsNetworkReady(network.STCPR))
+
+	var sessions int32 = 1
+	tm.wg.Add(1)
+	go tm.monitorNetwork(context.Background(), network.DMSG, func() int { return int(atomic.LoadInt32(&sessions)) }, time.Millisecond)
+
+	atomic.StoreInt32(&sessions, 0)
+	require.Eventually(t, func() bool { return !tm.IsNetworkReady(network.DMSG) }, time.Second, time.Millisecond)
+	pk, _ := cipher.GenerateKeyPair()
+	_, err = tm.DialAny(context.Background(), pk, 10, nil, false)
+	require.ErrorIs(t, err, network.ErrNoClients)
+
+	atomic.StoreInt32(&sessions, 2)
+	require.Eventually(t, func() bool { return tm.IsNetworkReady(network.DMSG) }, time.Second, time.Millisecond)
+
+	require.NoError(t, tm.Close())
+	require.Equal(t, []string{"down dmsg", "up dmsg"}, events)
+}
+
+func TestManager_RemoveNetworkStopsMonitor(t *testing.T) {
+	tm, err := NewManager(logging.MustGetLogger("tp_manager"), nil, nil, &ManagerConfig{}, network.ClientFactory{})
+	require.NoError(t, err)
+	tm.netClients[network.DMSG] = &closeClient{netType: network.DMSG}
+
+	var checks int32
+	tm.startMonitor(context.Background(), network.DMSG, func() int {
+		atomic.AddInt32(&checks, 1)
+		return 0
+	}, time.Millisecond)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&checks) > 0 }, time.Second, time.Millisecond)
+
+	require.NoError(t, tm.RemoveNetwork(network.DMSG))
+	stopped := atomic.LoadInt32(&checks)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, stopped, atomic.LoadInt32(&checks))
+	tm.downNetMx.Lock()
+	require.Empty(t, tm.downNets)
+	tm.downNetMx.Unlock()
+
+	require.NoError(t, tm.Close())
+}
+
+func TestManager_CloseWhileMonitorCallbackLocks(t *testing.T) {
+	entered := make(chan struct{})
+	closing := make(chan struct{})
+	var once sync.Once
+	var tm *Manager
+	conf := &ManagerConfig{OnNetworkDown: func(network.Type) {
+		once.Do(func() {
+			close(entered)
+			<-closing
+			tm.Networks()
+		})
+	}}
+	tm, err := NewManager(logging.MustGetLogger("tp_manager"), nil, nil, conf, network.ClientFactory{})
+	require.NoError(t, err)
+	tm.netClients[network.DMSG] = &closeClient{netType: network.DMSG}
+
+	tm.startMonitor(context.Background(), network.DMSG, func() int { return 0 }, time.Millisecond)
+	<-entered
+
+	closed := make(chan error)
+	go func() { closed <- tm.Close() }()
+	require.Eventually(t, tm.isClosing, time.Second, time.Millisecond)
+	close(closing)
+
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close deadlocked with a monitor callback")
+	}
+}
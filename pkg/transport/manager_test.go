@@ -2,16 +2,21 @@
 package transport_test
 
 import (
+	"context"
 	"io"
 	"log"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
 	"github.com/skycoin/skywire-utilities/pkg/logging"
+	"github.com/skycoin/skywire/pkg/app/appevent"
 	"github.com/skycoin/skywire/pkg/transport"
+	"github.com/skycoin/skywire/pkg/transport/network"
+	"github.com/skycoin/skywire/pkg/transport/network/stcp"
 )
 
 var masterLogger *logging.MasterLogger
@@ -54,3 +59,121 @@ func TestMakeTransportID(t *testing.T) {
 		require.NotEqual(t, transport.MakeTransportID(keyA, keyA, "a"), transport.MakeTransportID(keyA, keyA, "b"))
 	})
 }
+
+func TestManager_AddRemoveNetwork(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+
+	var added, removed []network.Type
+	conf := &transport.ManagerConfig{
+		PubKey:           pk,
+		SecKey:           sk,
+		OnNetworkAdded:   func(netType network.Type) { added = append(added, netType) },
+		OnNetworkRemoved: func(netType network.Type) { removed = append(removed, netType) },
+	}
+	factory := network.ClientFactory{
+		PK:         pk,
+		SK:         sk,
+		ListenAddr: "127.0.0.1:0",
+		PKTable:    stcp.NewTable(nil),
+		MLogger:    masterLogger,
+	}
+	tm, err := transport.NewManager(masterLogger.PackageLogger("tp_manager"), nil, nil, conf, factory)
+	require.NoError(t, err)
+
+	require.NoError(t, tm.AddNetwork(context.Background(), network.STCP, 0))
+	require.ErrorIs(t, tm.AddNetwork(context.Background(), network.STCP, 0), transport.ErrNetworkAdded)
+	require.Equal(t, []network.Type{network.STCP}, tm.Networks())
+	require.True(t, tm.IsKnownNetwork(network.STCP))
+	<-tm.Ready()
+
+	require.NoError(t, tm.RemoveNetwork(network.STCP))
+	require.ErrorIs(t, tm.RemoveNetwork(network.STCP), transport.ErrNetworkNotAdded)
+	require.Empty(t, tm.Networks())
+	require.False(t, tm.IsKnownNetwork(network.STCP))
+
+	require.Equal(t, []network.Type{network.STCP}, added)
+	require.Equal(t, []network.Type{network.STCP}, removed)
+}
+
+func TestManager_WaitReady(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	factory := network.ClientFactory{
+		PK:         pk,
+		SK:         sk,
+		ListenAddr: "127.0.0.1:0",
+		PKTable:    stcp.NewTable(nil),
+		MLogger:    masterLogger,
+	}
+	conf := &transport.ManagerConfig{PubKey: pk, SecKey: sk}
+	tm, err := transport.NewManager(masterLogger.PackageLogger("tp_manager"), nil, nil, conf, factory)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tm.Close()) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// waiting starts before the network is added
+	readyCh := make(chan error, 1)
+	go func() { readyCh <- tm.WaitReady(ctx, network.STCP) }()
+	require.NoError(t, tm.AddNetwork(ctx, network.STCP, 0))
+	require.NoError(t, <-readyCh)
+
+	stcpC, ok := tm.Stcp()
+	require.True(t, ok)
+	_, err = stcpC.LocalAddr()
+	require.NoError(t, err)
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer shortCancel()
+	require.ErrorIs(t, tm.WaitReady(shortCtx, network.STCPR), context.DeadlineExceeded)
+}
+
+func TestManager_Ping(t *testing.T) {
+	newManager := func(table stcp.PKTable) (*transport.Manager, cipher.PubKey) {
+		pk, sk := cipher.GenerateKeyPair()
+		factory := network.ClientFactory{
+			PK:         pk,
+			SK:         sk,
+			ListenAddr: "127.0.0.1:0",
+			PKTable:    table,
+			EB:         appevent.NewBroadcaster(nil, time.Second),
+			MLogger:    masterLogger,
+		}
+		conf := &transport.ManagerConfig{PubKey: pk, SecKey: sk}
+		tm, err := transport.NewManager(masterLogger.PackageLogger("tp_manager"), nil, nil, conf, factory)
+		require.NoError(t, err)
+		require.NoError(t, tm.AddNetwork(context.Background(), network.STCP, 0))
+		t.Cleanup(func() { require.NoError(t, tm.Close()) })
+		return tm, pk
+	}
+
+	table := stcp.NewTable(nil)
+	local, _ := newManager(table)
+	remote, remotePK := newManager(stcp.NewTable(nil))
+
+	remoteC, ok := remote.Stcp()
+	require.True(t, ok)
+	remoteAddr, err := remoteC.LocalAddr()
+	require.NoError(t, err)
+	table.AddEntry(remotePK, remoteAddr.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rtt, err := local.Ping(ctx, network.STCP, remotePK)
+	require.NoError(t, err)
+	require.Positive(t, rtt)
+
+	_, err = local.Ping(ctx, network.STCPR, remotePK)
+	require.ErrorIs(t, err, transport.ErrNetworkNotAdded)
+
+	results := local.PingAll(ctx, remotePK)
+	require.Len(t, results, 1)
+	require.Empty(t, results[network.STCP].Error)
+	require.Positive(t, results[network.STCP].Latency)
+
+	// visors with unknown address are reported with an error
+	unknownPK, _ := cipher.GenerateKeyPair()
+	results = local.PingAll(ctx, unknownPK)
+	require.Contains(t, results[network.STCP].Error, network.ErrStcpEntryNotFound.Error())
+}
@@ -11,11 +11,12 @@ import (
 	"net"
 	"net/http"
 	"net/url"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AudriusButkevicius/pfilter"
+	"github.com/sirupsen/logrus"
 	"github.com/xtaci/kcp-go"
 
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
@@ -29,6 +30,7 @@ const (
 	// sudphPriority is used to set an order how connection filters apply.
 	sudphPriority            = 1
 	stcprBindPath            = "/bind/stcpr"
+	squicBindPath            = "/bind/squic"
 	addrChSize               = 1024
 	udpKeepHeartbeatInterval = 10 * time.Second
 	udpKeepHeartbeatMessage  = "heartbeat"
@@ -56,6 +58,7 @@ type Error struct {
 // APIClient implements address resolver API client.
 type APIClient interface {
 	BindSTCPR(ctx context.Context, port string) error
+	BindSQUIC(ctx context.Context, port string) error
 	BindSUDPH(filter *pfilter.PacketFilter, handshake Handshake) (<-chan RemoteVisor, error)
 	Resolve(ctx context.Context, netType string, pk cipher.PubKey) (VisorData, error)
 	Transports(ctx context.Context) (map[cipher.PubKey][]string, error)
@@ -84,6 +87,7 @@ type httpClient struct {
 	ready          chan struct{}
 	closed         chan struct{}
 	delBindSudphWg sync.WaitGroup
+	squicBound     atomic.Bool
 }
 
 // NewHTTP creates a new client setting a public key to the client to be used for auth.
@@ -211,16 +215,36 @@ type LocalAddresses struct {
 	Addresses []string `json:"addresses"`
 }
 
+// Addresses returns local port of SUDPH connection to address resolver, if there is one.
 func (c *httpClient) Addresses(_ context.Context) string {
-	if c.sudphConn != nil {
-		return strings.Split(c.sudphConn.LocalAddr().String(), ":")[3]
+	if c.sudphConn == nil {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(c.sudphConn.LocalAddr().String())
+	if err != nil {
+		c.log.WithError(err).Warn("Failed to get SUDPH port")
+		return ""
 	}
-	return ""
+	return port
 }
 
 // BindSTCPR binds client PK to IP:port on address resolver.
 func (c *httpClient) BindSTCPR(ctx context.Context, port string) error {
-	log := c.log.WithField("func", "httpClient.BindSTCPR")
+	return c.bind(ctx, c.log.WithField("func", "httpClient.BindSTCPR"), stcprBindPath, port)
+}
+
+// BindSQUIC binds client PK to IP:port of SQUIC listener on address resolver.
+func (c *httpClient) BindSQUIC(ctx context.Context, port string) error {
+	if err := c.bind(ctx, c.log.WithField("func", "httpClient.BindSQUIC"), squicBindPath, port); err != nil {
+		return err
+	}
+	c.squicBound.Store(true)
+	return nil
+}
+
+// bind binds client PK to local addresses and the given port on address resolver
+// using the bind path of the transport type.
+func (c *httpClient) bind(ctx context.Context, log logrus.FieldLogger, path, port string) error {
 	if !c.isReady() {
 		log.Debug("Address resolver is not ready yet, waiting...")
 		<-c.ready
@@ -237,7 +261,7 @@ func (c *httpClient) BindSTCPR(ctx context.Context, port string) error {
 		Port:      port,
 	}
 	log.Debugf("Address resolver binding with: %v", addresses)
-	resp, err := c.Post(ctx, stcprBindPath, localAddresses)
+	resp, err := c.Post(ctx, path, localAddresses)
 	if err != nil {
 		return err
 	}
@@ -257,7 +281,11 @@ func (c *httpClient) BindSTCPR(ctx context.Context, port string) error {
 
 // delBindSTCPR uinbinds STCPR entry PK to IP:port on address resolver.
 func (c *httpClient) delBindSTCPR(ctx context.Context) error {
-	log := c.log.WithField("func", "httpClient.delBindSTCPR")
+	return c.delBind(ctx, c.log.WithField("func", "httpClient.delBindSTCPR"), stcprBindPath)
+}
+
+// delBind unbinds client PK from address resolver using the bind path of the transport type.
+func (c *httpClient) delBind(ctx context.Context, log logrus.FieldLogger, path string) error {
 	if !c.isReady() {
 		log.Debug("Address resolver is not ready yet, waiting...")
 		<-c.ready
@@ -265,7 +293,7 @@ func (c *httpClient) delBindSTCPR(ctx context.Context) error {
 	}
 
 	log.Debugf("Deleting the binding pk: %v from Address resolver", c.pk.String())
-	resp, err := c.Delete(ctx, stcprBindPath)
+	resp, err := c.Delete(ctx, path)
 	if err != nil {
 		return err
 	}
@@ -300,6 +328,12 @@ func (c *httpClient) BindSUDPH(filter *pfilter.PacketFilter, hs Handshake) (<-ch
 		return nil, err
 	}
 
+	// conn of the previous binding would keep receiving the packets
+	if c.sudphConn != nil {
+		if err := c.sudphConn.Close(); err != nil {
+			log.WithError(err).Warn("Failed to close previous SUDPH connection")
+		}
+	}
 	c.sudphConn = filter.NewConn(sudphPriority, packetfilter.NewAddressFilter(rAddr, c.mLog))
 
 	_, localPort, err := net.SplitHostPort(c.sudphConn.LocalAddr().String())
@@ -344,6 +378,7 @@ func (c *httpClient) BindSUDPH(filter *pfilter.PacketFilter, hs Handshake) (<-ch
 		}
 	}()
 
+	c.delBindSudphWg.Add(1)
 	go func() {
 		if err := c.delBindSUDPH(arConn); err != nil {
 			log.WithError(err).Errorf("Failed to send UDP unbind packet to address-resolver")
@@ -513,7 +548,6 @@ func (c *httpClient) Close() error {
 	}()
 
 	if c.sudphConn != nil {
-		c.delBindSudphWg.Add(1)
 		close(c.closed)
 		c.delBindSudphWg.Wait()
 		if err := c.sudphConn.Close(); err != nil {
@@ -530,6 +564,11 @@ func (c *httpClient) Close() error {
 			c.log.WithError(err).Errorf("Failed to delete STCPR binding")
 		}
 	}
+	if c.squicBound.Load() {
+		if err := c.delBind(context.Background(), c.log.WithField("func", "httpClient.delBindSQUIC"), squicBindPath); err != nil {
+			c.log.WithError(err).Errorf("Failed to delete SQUIC binding")
+		}
+	}
 
 	return nil
 }
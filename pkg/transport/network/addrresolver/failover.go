@@ -0,0 +1,317 @@
+// Package addrresolver pkg/transport/network/addrresolver/failover.go
+package addrresolver
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AudriusButkevicius/pfilter"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+)
+
+const (
+	// endpointCooldown is how long a failed address resolver is tried only
+	// after the healthy ones
+	endpointCooldown = time.Minute
+	// sudphRebindInterval is how often SUDPH is re-bound while all address
+	// resolvers fail
+	sudphRebindInterval = 5 * time.Second
+)
+
+// ErrNoAddressResolver is returned when there are no address resolvers to use.
+var ErrNoAddressResolver = errors.New("no address resolver available")
+
+// readyNotifier is implemented by clients which are not usable until connected
+type readyNotifier interface {
+	readyCh() <-chan struct{}
+}
+
+func (c *httpClient) readyCh() <-chan struct{} {
+	return c.ready
+}
+
+// endpoint is an address resolver client with its health
+type endpoint struct {
+	APIClient
+	failedAt time.Time
+}
+
+func (e *endpoint) ready() <-chan struct{} {
+	if rn, ok := e.APIClient.(readyNotifier); ok {
+		return rn.readyCh()
+	}
+	return closedCh
+}
+
+func (e *endpoint) isReady() bool {
+	select {
+	case <-e.ready():
+		return true
+	default:
+		return false
+	}
+}
+
+var closedCh = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// failoverClient implements APIClient over several address resolvers. Requests
+// go to the first healthy one, failed address resolvers cool down and are only
+// tried after the others.
+type failoverClient struct {
+	log       *logging.Logger
+	endpoints []*endpoint
+	cooldown  time.Duration
+	mx        sync.Mutex // guards health of endpoints and sudph
+
+	sudph       *endpoint // address resolver SUDPH is bound to
+	sudphRebind chan struct{}
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewFailover creates a client using the given address resolver clients in order,
+// moving on to the next one while previous ones fail.
+// A single client is returned as is.
+func NewFailover(log *logging.Logger, clients ...APIClient) (APIClient, error) {
+	switch len(clients) {
+	case 0:
+		return nil, ErrNoAddressResolver
+	case 1:
+		return clients[0], nil
+	}
+	c := &failoverClient{
+		log:         log,
+		cooldown:    endpointCooldown,
+		sudphRebind: make(chan struct{}, 1),
+		closed:      make(chan struct{}),
+	}
+	for _, client := range clients {
+		c.endpoints = append(c.endpoints, &endpoint{APIClient: client})
+	}
+	return c, nil
+}
+
+// candidates returns ready endpoints in the order they should be tried:
+// healthy ones first, then those cooling down, least recently failed first
+func (c *failoverClient) candidates() []*endpoint {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	var healthy, cooling []*endpoint
+	for _, e := range c.endpoints {
+		if !e.isReady() {
+			continue
+		}
+		if time.Since(e.failedAt) < c.cooldown {
+			cooling = append(cooling, e)
+			continue
+		}
+		healthy = append(healthy, e)
+	}
+	sort.SliceStable(cooling, func(i, j int) bool {
+		return cooling[i].failedAt.Before(cooling[j].failedAt)
+	})
+	return append(healthy, cooling...)
+}
+
+// failed starts cooldown of e, SUDPH bound to it is re-bound to another address resolver
+func (c *failoverClient) failed(e *endpoint, err error) {
+	c.log.WithError(err).Warn("Address resolver failed, using the next one")
+	c.mx.Lock()
+	e.failedAt = time.Now()
+	rebind := c.sudph == e
+	c.mx.Unlock()
+	if rebind {
+		select {
+		case c.sudphRebind <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// waitReady blocks until any of the endpoints is ready
+func (c *failoverClient) waitReady(ctx context.Context) error {
+	readyCh := make(chan struct{}, len(c.endpoints))
+	stop := make(chan struct{})
+	defer close(stop)
+	for _, e := range c.endpoints {
+		go func(e *endpoint) {
+			select {
+			case <-e.ready():
+				readyCh <- struct{}{}
+			case <-stop:
+			}
+		}(e)
+	}
+	select {
+	case <-readyCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closed:
+		return ErrNoAddressResolver
+	}
+}
+
+// do calls f with endpoints until one of them succeeds. ErrNoEntry is a valid
+// response, as well as errors after ctx is done, which are returned as is
+func (c *failoverClient) do(ctx context.Context, wait bool, f func(e *endpoint) error) error {
+	candidates := c.candidates()
+	if len(candidates) == 0 {
+		if !wait {
+			return ErrNotReady
+		}
+		if err := c.waitReady(ctx); err != nil {
+			return err
+		}
+		candidates = c.candidates()
+	}
+	var err error
+	for _, e := range candidates {
+		if err = f(e); err == nil || errors.Is(err, ErrNoEntry) || ctx.Err() != nil {
+			return err
+		}
+		c.failed(e, err)
+	}
+	return err
+}
+
+// BindSTCPR implements APIClient.
+func (c *failoverClient) BindSTCPR(ctx context.Context, port string) error {
+	return c.do(ctx, true, func(e *endpoint) error {
+		return e.BindSTCPR(ctx, port)
+	})
+}
+
+// BindSQUIC implements APIClient.
+func (c *failoverClient) BindSQUIC(ctx context.Context, port string) error {
+	return c.do(ctx, true, func(e *endpoint) error {
+		return e.BindSQUIC(ctx, port)
+	})
+}
+
+// Resolve implements APIClient.
+func (c *failoverClient) Resolve(ctx context.Context, netType string, pk cipher.PubKey) (VisorData, error) {
+	var visorData VisorData
+	err := c.do(ctx, false, func(e *endpoint) (err error) {
+		visorData, err = e.Resolve(ctx, netType, pk)
+		return err
+	})
+	return visorData, err
+}
+
+// Transports implements APIClient.
+func (c *failoverClient) Transports(ctx context.Context) (map[cipher.PubKey][]string, error) {
+	var transports map[cipher.PubKey][]string
+	err := c.do(ctx, true, func(e *endpoint) (err error) {
+		transports, err = e.Transports(ctx)
+		return err
+	})
+	return transports, err
+}
+
+// Addresses implements APIClient.
+func (c *failoverClient) Addresses(ctx context.Context) string {
+	c.mx.Lock()
+	e := c.sudph
+	c.mx.Unlock()
+	if e == nil {
+		return ""
+	}
+	return e.Addresses(ctx)
+}
+
+// BindSUDPH implements APIClient. SUDPH is re-bound to another address resolver
+// once the one it is bound to fails, messages of all bindings go to the returned channel
+func (c *failoverClient) BindSUDPH(filter *pfilter.PacketFilter, hs Handshake) (<-chan RemoteVisor, error) {
+	e, addrCh, err := c.bindSUDPH(filter, hs)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan RemoteVisor, addrChSize)
+	go c.serveSUDPH(filter, hs, e, addrCh, out)
+	return out, nil
+}
+
+func (c *failoverClient) bindSUDPH(filter *pfilter.PacketFilter, hs Handshake) (*endpoint, <-chan RemoteVisor, error) {
+	var (
+		bound  *endpoint
+		addrCh <-chan RemoteVisor
+	)
+	err := c.do(context.Background(), true, func(e *endpoint) (err error) {
+		addrCh, err = e.BindSUDPH(filter, hs)
+		bound = e
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	c.mx.Lock()
+	c.sudph = bound
+	c.mx.Unlock()
+	return bound, addrCh, nil
+}
+
+// serveSUDPH forwards messages of the current SUDPH binding to out,
+// re-binding when the bound address resolver fails
+func (c *failoverClient) serveSUDPH(filter *pfilter.PacketFilter, hs Handshake, e *endpoint,
+	addrCh <-chan RemoteVisor, out chan<- RemoteVisor) {
+	defer close(out)
+	for {
+		select {
+		case remote, ok := <-addrCh:
+			if ok {
+				select {
+				case out <- remote:
+				case <-c.closed:
+					return
+				}
+				continue
+			}
+			// the bound address resolver stopped sending messages
+			c.failed(e, errors.New("SUDPH connection closed"))
+		case <-c.sudphRebind:
+		case <-c.closed:
+			return
+		}
+
+		for {
+			newE, newAddrCh, err := c.bindSUDPH(filter, hs)
+			if err == nil {
+				c.log.Debug("Re-bound SUDPH to another address resolver")
+				e, addrCh = newE, newAddrCh
+				break
+			}
+			c.log.WithError(err).Warn("Failed to re-bind SUDPH")
+			select {
+			case <-time.After(sudphRebindInterval):
+			case <-c.closed:
+				return
+			}
+		}
+		// failures during re-binding are handled by it
+		select {
+		case <-c.sudphRebind:
+		default:
+		}
+	}
+}
+
+// Close implements APIClient.
+func (c *failoverClient) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	for _, e := range c.endpoints {
+		if err := e.Close(); err != nil {
+			c.log.WithError(err).Warn("Failed to close address resolver client")
+		}
+	}
+	return nil
+}
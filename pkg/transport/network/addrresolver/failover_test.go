@@ -0,0 +1,116 @@
+// Package addrresolver pkg/transport/network/addrresolver/failover_test.go
+package addrresolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+)
+
+// newTestARServer serves address resolver API, a failing server responds with 5xx
+func newTestARServer(t *testing.T, remoteAddr string, failing bool) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(authHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == http.MethodGet {
+			if err := json.NewEncoder(w).Encode(VisorData{RemoteAddr: remoteAddr}); err != nil {
+				t.Errorf("Failed to encode resolve response: %v", err)
+			}
+		}
+	})))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestFailover(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	remotePK, _ := cipher.GenerateKeyPair()
+	log := logging.MustGetLogger("test_failover")
+
+	primary, primaryHits := newTestARServer(t, "", true)
+	secondary, secondaryHits := newTestARServer(t, "127.0.0.1:1234", false)
+
+	var clients []APIClient
+	for _, srv := range []*httptest.Server{primary, secondary} {
+		c, err := NewHTTP(srv.URL, pk, sk, &http.Client{}, ip, log, masterLogger)
+		require.NoError(t, err)
+		<-c.(*httpClient).ready
+		clients = append(clients, c)
+	}
+	c, err := NewFailover(log, clients...)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	visorData, err := c.Resolve(ctx, "stcpr", remotePK)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1:1234", visorData.RemoteAddr)
+	require.EqualValues(t, 1, primaryHits.Load())
+	require.EqualValues(t, 1, secondaryHits.Load())
+
+	// the failed address resolver cools down and is not tried first
+	require.NoError(t, c.BindSTCPR(ctx, "1234"))
+	_, err = c.Resolve(ctx, "stcpr", remotePK)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, primaryHits.Load())
+	require.EqualValues(t, 3, secondaryHits.Load())
+
+	// the failed address resolver is used again after cooldown
+	c.(*failoverClient).cooldown = 0
+	_, err = c.Resolve(ctx, "stcpr", remotePK)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, primaryHits.Load())
+}
+
+func TestFailover_SUDPH(t *testing.T) {
+	errFailed := errors.New("failed")
+	primaryCh, secondaryCh := make(chan RemoteVisor, 1), make(chan RemoteVisor, 1)
+
+	primary := &MockAPIClient{}
+	primary.On("BindSUDPH", mock.Anything, mock.Anything).Return((<-chan RemoteVisor)(primaryCh), nil)
+	primary.On("Resolve", mock.Anything, mock.Anything, mock.Anything).Return(VisorData{}, errFailed)
+	primary.On("Close").Return(nil)
+	secondaryBound := make(chan struct{})
+	secondary := &MockAPIClient{}
+	secondary.On("BindSUDPH", mock.Anything, mock.Anything).Return((<-chan RemoteVisor)(secondaryCh), nil).
+		Run(func(mock.Arguments) { close(secondaryBound) }).Once()
+	secondary.On("Resolve", mock.Anything, mock.Anything, mock.Anything).Return(VisorData{}, ErrNoEntry)
+	secondary.On("Close").Return(nil)
+
+	c, err := NewFailover(logging.MustGetLogger("test_failover_sudph"), primary, secondary)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, c.Close()) }()
+
+	addrCh, err := c.BindSUDPH(nil, nil)
+	require.NoError(t, err)
+	pk1, _ := cipher.GenerateKeyPair()
+	primaryCh <- RemoteVisor{PK: pk1}
+	require.Equal(t, pk1, (<-addrCh).PK)
+
+	// failure of the bound address resolver re-binds SUDPH to the next one
+	_, err = c.Resolve(context.Background(), "sudph", pk1)
+	require.ErrorIs(t, err, ErrNoEntry)
+	select {
+	case <-secondaryBound:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SUDPH was not re-bound")
+	}
+	pk2, _ := cipher.GenerateKeyPair()
+	secondaryCh <- RemoteVisor{PK: pk2}
+	require.Equal(t, pk2, (<-addrCh).PK)
+}
@@ -30,6 +30,20 @@ func (_m *MockAPIClient) BindSTCPR(ctx context.Context, port string) error {
 	return r0
 }
 
+// BindSQUIC provides a mock function with given fields: ctx, port
+func (_m *MockAPIClient) BindSQUIC(ctx context.Context, port string) error {
+	ret := _m.Called(ctx, port)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, port)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // BindSUDPH provides a mock function with given fields: filter, handshake
 func (_m *MockAPIClient) BindSUDPH(filter *pfilter.PacketFilter, handshake Handshake) (<-chan RemoteVisor, error) {
 	ret := _m.Called(filter, handshake)
@@ -109,6 +123,30 @@ func (_m *MockAPIClient) Resolve(ctx context.Context, netType string, pk cipher.
 	return r0, r1
 }
 
-func (_m *MockAPIClient) Addresses(ctx context.Context) (string, error) {
-	return "", nil
+// Addresses provides a mock function with given fields: ctx
+func (_m *MockAPIClient) Addresses(ctx context.Context) string {
+	return ""
+}
+
+// Transports provides a mock function with given fields: ctx
+func (_m *MockAPIClient) Transports(ctx context.Context) (map[cipher.PubKey][]string, error) {
+	ret := _m.Called(ctx)
+
+	var r0 map[cipher.PubKey][]string
+	if rf, ok := ret.Get(0).(func(context.Context) map[cipher.PubKey][]string); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[cipher.PubKey][]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
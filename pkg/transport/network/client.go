@@ -9,7 +9,9 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/hashicorp/yamux"
 	"github.com/skycoin/dmsg/pkg/dmsg"
 
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
@@ -59,10 +61,42 @@ type ClientFactory struct {
 	EB         *appevent.Broadcaster
 	DmsgC      *dmsg.Client
 	MLogger    *logging.MasterLogger
+	// OnConn is called on every connection lifecycle event of created clients
+	OnConn func(ConnEvent)
+	// Metrics collects metrics of created clients, if set
+	Metrics *Metrics
+	// RateLimiter caps bandwidth of transports of created clients, if set
+	RateLimiter *RateLimiter
+	// DialTimeout bounds dials with contexts that have no deadline.
+	// Zero means DefaultDialTimeout, negative value disables the bound
+	DialTimeout time.Duration
+	// Compression enables transport compression for the peers that support it
+	Compression bool
+	// Mux enables multiplexing transports to the same visor over a single
+	// connection for the peers that support it. It is used by direct networks only
+	Mux bool
+	// MuxIdleTimeout closes multiplexing connections which carry no transports
+	// for the duration. Zero keeps them open until either side closes them
+	MuxIdleTimeout time.Duration
+	// LANDiscovery enables discovery of STCP visors on the local network, if set
+	LANDiscovery *LANDiscoveryConfig
+	// Keepalive configures detection of dead peers per network type,
+	// networks without an entry use defaults
+	Keepalive map[Type]KeepaliveConfig
 }
 
-// MakeClient creates a new client of specified type
+// MakeClient creates a new client of specified type. The type has to be
+// registered with RegisterNetwork, which is done for built-in networks by this package
 func (f *ClientFactory) MakeClient(netType Type, port int) (Client, error) {
+	factory, ok := lookupNetwork(netType)
+	if !ok {
+		return nil, fmt.Errorf("cannot initiate client: %w: %s", ErrUnknownTransportType, netType)
+	}
+	return factory.MakeClient(f, port)
+}
+
+// genericClient creates the base of the clients of built-in networks
+func (f *ClientFactory) genericClient(netType Type) *genericClient {
 	log := logging.MustGetLogger(string(netType))
 	if f.MLogger != nil {
 		log = f.MLogger.PackageLogger(string(netType))
@@ -74,6 +108,9 @@ func (f *ClientFactory) MakeClient(netType Type, port int) (Client, error) {
 	generic.listenStarted = make(chan struct{})
 	generic.done = make(chan struct{})
 	generic.listeners = make(map[uint16]*listener)
+	generic.sessions = make(map[*yamux.Session]*transport)
+	generic.sessionDials = make(map[cipher.PubKey]chan struct{})
+	generic.sessionRefs = make(map[*yamux.Session]*sessionRef)
 	generic.log = log
 	generic.mLog = f.MLogger
 	generic.porter = p
@@ -81,20 +118,30 @@ func (f *ClientFactory) MakeClient(netType Type, port int) (Client, error) {
 	generic.lPK = f.PK
 	generic.lSK = f.SK
 	generic.listenAddr = f.ListenAddr
+	generic.onConn = f.OnConn
+	generic.metrics = f.Metrics.network(netType)
+	generic.limiter = f.RateLimiter.network(netType)
+	generic.status = newClientStatus()
+	generic.defaultDialTimeout = f.dialTimeout()
+	if f.Compression {
+		generic.compression = compressionAlgs
+	}
+	generic.mux = f.Mux
+	generic.muxIdleTimeout = f.MuxIdleTimeout
+	generic.keepalive = f.Keepalive[netType].withDefaults()
+	return generic
+}
 
-	resolved := &resolvedClient{genericClient: generic, ar: f.ARClient}
+// resolvedClient creates the base of the clients of networks using address resolver
+func (f *ClientFactory) resolvedClient(netType Type) *resolvedClient {
+	return &resolvedClient{genericClient: f.genericClient(netType), ar: f.ARClient}
+}
 
-	switch netType {
-	case STCP:
-		return newStcp(generic, f.PKTable), nil
-	case STCPR:
-		return newStcpr(resolved, port), nil
-	case SUDPH:
-		return newSudph(resolved, port), nil
-	case DMSG:
-		return newDmsgClient(f.DmsgC), nil
+func (f *ClientFactory) dialTimeout() time.Duration {
+	if f.DialTimeout == 0 {
+		return DefaultDialTimeout
 	}
-	return nil, fmt.Errorf("cannot initiate client, type %s not supported", netType)
+	return f.DialTimeout
 }
 
 // genericClient unites common logic for all clients
@@ -109,6 +156,24 @@ type genericClient struct {
 	lSK        cipher.SecKey
 	listenAddr string
 	netType    Type
+	onConn     func(ConnEvent)
+	metrics    *netMetrics
+	limiter    *netLimiter
+	status     *clientStatus
+
+	defaultDialTimeout time.Duration
+	// compression lists compression algorithms offered in handshakes
+	compression []string
+	// mux is true if transports are multiplexed over a single connection per visor
+	mux bool
+	// keepalive configures detection of dead peers
+	keepalive KeepaliveConfig
+	// heartbeat is true if the network exchanges heartbeats with the peers,
+	// as it has no keepalives of its own
+	heartbeat bool
+	// onAuthenticated is called, if set, when a remote visor connecting from
+	// remoteAddr proved its key with the handshake
+	onAuthenticated func(rPK cipher.PubKey, remoteAddr net.Addr)
 
 	log    *logging.Logger
 	mLog   *logging.MasterLogger
@@ -121,6 +186,16 @@ type genericClient struct {
 	mu            sync.RWMutex
 	done          chan struct{}
 	closeOnce     sync.Once
+
+	// sessions multiplexing transports, keyed by the underlying transport
+	sessions   map[*yamux.Session]*transport
+	sessionsMu sync.Mutex
+	// sessionDials are dials in progress, which may create sessions
+	sessionDials map[cipher.PubKey]chan struct{}
+	// sessionRefs count transports carried by sessions
+	sessionRefs map[*yamux.Session]*sessionRef
+	// muxIdleTimeout closes sessions without transports, if positive
+	muxIdleTimeout time.Duration
 }
 
 // initTransport will initialize skywire transport over opened raw connection to
@@ -134,8 +209,63 @@ func (c *genericClient) initTransport(ctx context.Context, conn net.Conn, rPK ci
 	lAddr, rAddr := dmsg.Addr{PK: c.lPK, Port: lPort}, dmsg.Addr{PK: rPK, Port: rPort}
 	remoteAddr := conn.RemoteAddr()
 	c.log.Debugf("Performing handshake with %v", remoteAddr)
-	hs := handshake.InitiatorHandshake(c.lSK, lAddr, rAddr)
-	return c.wrapTransport(conn, hs, true, freePort)
+	opts, negotiated := c.handshakeOptions()
+	hs := handshake.InitiatorHandshakeWithOptions(c.lSK, lAddr, rAddr, opts)
+
+	// closing conn as soon as ctx is done aborts the handshake
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close() //nolint: errcheck, gosec
+	})
+	tp, err := c.wrapTransport(conn, hs, true, freePort, negotiated)
+	if !stop() {
+		if err == nil {
+			freePort()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil || !negotiated.mux {
+		return tp, err
+	}
+	session, err := c.startSession(tp, true)
+	if err != nil {
+		return nil, err
+	}
+	return c.openStream(ctx, session, tp, rPort)
+}
+
+// dialTransport dials the remote visor over an open session with it if there
+// is one, otherwise it initializes transport over the connection returned by dial
+func (c *genericClient) dialTransport(ctx context.Context, rPK cipher.PubKey, rPort uint16, dial func() (net.Conn, error)) (*transport, error) {
+	for waited := false; ; waited = true {
+		if tp, ok, err := c.dialSession(ctx, rPK, rPort); ok {
+			return tp, err
+		}
+		if !c.mux || waited {
+			break
+		}
+		wait, done := c.sessionDial(rPK)
+		if wait == nil {
+			defer done()
+			break
+		}
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return c.dialNewTransport(ctx, rPK, rPort, dial)
+}
+
+// dialNewTransport initializes transport over a new raw connection, even if
+// transports to the remote visor could be multiplexed over an open one
+func (c *genericClient) dialNewTransport(ctx context.Context, rPK cipher.PubKey, rPort uint16, dial func() (net.Conn, error)) (*transport, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	c.log.Debugf("Dialed %v:%v@%v", rPK, rPort, conn.RemoteAddr())
+	return c.initTransport(ctx, conn, rPK, rPort)
 }
 
 // acceptTransports continuously accepts incoming transports that come from given listener
@@ -159,6 +289,7 @@ func (c *genericClient) acceptTransports(lis net.Listener) {
 			}
 
 			c.log.Warnf("failed to accept incoming connection: %v", err)
+			c.status.setError(err)
 			if !handshake.IsHandshakeError(err) {
 				c.log.Warnf("stopped serving")
 				return
@@ -167,19 +298,66 @@ func (c *genericClient) acceptTransports(lis net.Listener) {
 	}
 }
 
+// negotiatedFeatures holds features agreed on in a handshake
+type negotiatedFeatures struct {
+	compression string
+	mux         bool
+	heartbeat   bool
+}
+
+// handshakeOptions returns settings for a single handshake and features
+// negotiated in it, which are set once the handshake succeeds
+func (c *genericClient) handshakeOptions() (handshake.Options, *negotiatedFeatures) {
+	negotiated := &negotiatedFeatures{}
+	opts := handshake.Options{
+		Compression: handshake.Compression{
+			Supported:  c.compression,
+			Negotiated: func(alg string) { negotiated.compression = alg },
+		},
+		Mux: handshake.Mux{
+			Supported:  c.mux,
+			Negotiated: func(muxed bool) { negotiated.mux = muxed },
+		},
+		Heartbeat: handshake.Heartbeat{
+			Supported:  c.heartbeat && c.keepalive.enabled(),
+			Negotiated: func(heartbeat bool) { negotiated.heartbeat = heartbeat },
+		},
+	}
+	return opts, negotiated
+}
+
 // wrapTransport performs handshake over provided raw connection and wraps it in
-// network.Transport type using the data obtained from handshake process
-func (c *genericClient) wrapTransport(rawConn net.Conn, hs handshake.Handshake, initiator bool, onClose func()) (*transport, error) {
+// network.Transport type using the data obtained from handshake process.
+// The transport is compressed if compression is negotiated in the handshake
+func (c *genericClient) wrapTransport(rawConn net.Conn, hs handshake.Handshake, initiator bool, onClose func(), negotiated *negotiatedFeatures) (*transport, error) {
+	if err := setTCPKeepalive(rawConn, c.keepalive); err != nil {
+		c.log.WithError(err).Warnf("Failed to set keepalive of connection with %v", rawConn.RemoteAddr())
+	}
 	transport, err := doHandshake(rawConn, hs, c.netType, c.log)
 	if err != nil {
 		onClose()
 		return nil, err
 	}
 	transport.freePort = onClose
+	transport.onClose = c.events().closeFunc(transport.rAddr.PK)
 	c.log.Debugf("Sent handshake to %v, local addr %v, remote addr %v", rawConn.RemoteAddr(), transport.lAddr, transport.rAddr)
+	if negotiated.heartbeat {
+		transport.Conn = newHeartbeatConn(transport.Conn, c.keepalive, c.log)
+	}
 	if err := transport.encrypt(c.lPK, c.lSK, initiator); err != nil {
 		return nil, err
 	}
+	transport.count(c.metrics)
+	transport.Conn = limitConn(transport.Conn, c.limiter)
+	if alg := negotiated.compression; alg != "" {
+		conn, err := compressConn(transport.Conn, alg)
+		if err != nil {
+			transport.Close() //nolint: errcheck, gosec
+			return nil, err
+		}
+		transport.Conn = conn
+		transport.compression = alg
+	}
 	return transport, nil
 }
 
@@ -199,16 +377,34 @@ func (c *genericClient) acceptTransport() error {
 	c.log.Debugf("Accepted connection from %v", remoteAddr)
 
 	onClose := func() {}
-	hs := handshake.ResponderHandshake(handshake.MakeF2PortChecker(c.checkListener))
-	wrappedTransport, err := c.wrapTransport(conn, hs, false, onClose)
+	opts, negotiated := c.handshakeOptions()
+	hs := handshake.ResponderHandshakeWithOptions(handshake.MakeF2PortChecker(c.checkListener), opts)
+	wrappedTransport, err := c.wrapTransport(conn, hs, false, onClose, negotiated)
 	if err != nil {
 		return err
 	}
+	if c.onAuthenticated != nil {
+		c.onAuthenticated(wrappedTransport.rAddr.PK, remoteAddr)
+	}
+	if negotiated.mux {
+		// transports are accepted from the session streams
+		_, err := c.startSession(wrappedTransport, false)
+		return err
+	}
 	lis, err := c.getListener(wrappedTransport.lAddr.Port)
 	if err != nil {
 		return err
 	}
-	return lis.introduce(wrappedTransport)
+	c.events().accepted(wrappedTransport.rAddr.PK)
+	if err := lis.introduce(wrappedTransport); err != nil {
+		wrappedTransport.Close() //nolint: errcheck, gosec
+		return err
+	}
+	return nil
+}
+
+func (c *genericClient) events() connEventer {
+	return connEventer{netType: c.netType, onConn: c.onConn, metrics: c.metrics, status: c.status}
 }
 
 // LocalAddr returns local address. This is network address the client
@@ -255,7 +451,8 @@ func (c *genericClient) Listen(port uint16) (Listener, error) {
 	defer c.mu.Unlock()
 
 	lAddr := dmsg.Addr{PK: c.lPK, Port: port}
-	lis := newListener(lAddr, freePort, c.netType)
+	listenerClosed := c.metrics.listenerOpened()
+	lis := newListener(lAddr, func() { freePort(); listenerClosed() }, c.netType)
 	c.listeners[port] = lis
 
 	return lis, nil
@@ -299,6 +496,7 @@ func (c *genericClient) Close() error {
 				c.log.WithError(err).WithField("addr", lis.Addr().String()).Warnf("Failed to close listener")
 			}
 		}
+		c.closeSessions()
 	})
 
 	return nil
@@ -325,7 +523,10 @@ type dialFunc func(ctx context.Context, addr string) (net.Conn, error)
 func (c *resolvedClient) dialVisor(ctx context.Context, rPK cipher.PubKey, dial dialFunc) (net.Conn, error) {
 	visorData, err := c.ar.Resolve(ctx, string(c.netType), rPK)
 	if err != nil {
-		return nil, fmt.Errorf("resolve PK: %w", err)
+		if errors.Is(err, addrresolver.ErrNotReady) {
+			return nil, fmt.Errorf("%w: resolve PK: %w", ErrNetworkNotReady, err)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrPKNotResolved, err)
 	}
 	c.log.Debugf("Resolved PK %v to visor data %v", rPK, visorData)
 
@@ -0,0 +1,60 @@
+// Package network pkg/transport/network/compression.go
+package network
+
+import (
+	"compress/flate"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// CompressionFlate is the DEFLATE transport compression
+const CompressionFlate = "flate"
+
+// compressionAlgs lists supported transport compression algorithms in the order of preference
+var compressionAlgs = []string{CompressionFlate}
+
+// compressConn wraps conn to transparently compress the stream with alg
+func compressConn(conn net.Conn, alg string) (net.Conn, error) {
+	switch alg {
+	case CompressionFlate:
+		w, err := flate.NewWriter(conn, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		return &flateConn{Conn: conn, r: flate.NewReader(conn), w: w}, nil
+	}
+	return nil, fmt.Errorf("unsupported compression: %s", alg)
+}
+
+// flateConn compresses writes and decompresses reads of the wrapped conn.
+// Every write is flushed, so that the peer receives data without delay
+type flateConn struct {
+	net.Conn
+	r   io.ReadCloser
+	wMx sync.Mutex
+	w   *flate.Writer
+}
+
+// Read implements net.Conn
+func (c *flateConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// Write implements net.Conn
+func (c *flateConn) Write(b []byte) (int, error) {
+	c.wMx.Lock()
+	defer c.wMx.Unlock()
+	n, err := c.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, c.w.Flush()
+}
+
+// Close implements net.Conn
+func (c *flateConn) Close() error {
+	c.r.Close() //nolint: errcheck, gosec
+	return c.Conn.Close()
+}
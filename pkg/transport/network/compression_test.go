@@ -0,0 +1,101 @@
+// Package network pkg/transport/network/compression_test.go
+package network
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire/pkg/app/appevent"
+)
+
+func TestCompression_STCP(t *testing.T) {
+	const port = 10
+	payload := bytes.Repeat([]byte("compressible payload "), 1024)
+
+	newClient := func(compression bool) STCPClient {
+		pk, sk := cipher.GenerateKeyPair()
+		f := &ClientFactory{
+			PK:          pk,
+			SK:          sk,
+			ListenAddr:  "127.0.0.1:0",
+			EB:          appevent.NewBroadcaster(nil, time.Second),
+			Metrics:     NewMetrics(),
+			Compression: compression,
+		}
+		c, err := f.MakeClient(STCP, 0)
+		require.NoError(t, err)
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Close()) })
+		return c.(STCPClient)
+	}
+
+	tests := []struct {
+		name                   string
+		dialerComp, remoteComp bool
+		wantCompression        string
+	}{
+		{name: "negotiated", dialerComp: true, remoteComp: true, wantCompression: CompressionFlate},
+		{name: "remote does not support", dialerComp: true, remoteComp: false},
+		{name: "dialer does not support", dialerComp: false, remoteComp: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dialer, remote := newClient(tc.dialerComp), newClient(tc.remoteComp)
+			lis, err := remote.Listen(port)
+			require.NoError(t, err)
+			defer lis.Close() //nolint: errcheck
+			remoteAddr, err := remote.LocalAddr()
+			require.NoError(t, err)
+			dialer.AddPKEntry(remote.PK(), remoteAddr.String())
+
+			acceptCh := make(chan Transport, 1)
+			go func() {
+				tp, err := lis.AcceptTransport()
+				if err == nil {
+					acceptCh <- tp
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			tp, err := dialer.Dial(ctx, remote.PK(), port)
+			require.NoError(t, err)
+			defer tp.Close() //nolint: errcheck
+			var accepted Transport
+			select {
+			case accepted = <-acceptCh:
+			case <-ctx.Done():
+				t.Fatal("transport was not accepted")
+			}
+			defer accepted.Close() //nolint: errcheck
+
+			require.Equal(t, tc.wantCompression, tp.(*transport).compression)
+			require.Equal(t, tc.wantCompression, accepted.(*transport).compression)
+
+			writeErr := make(chan error, 1)
+			go func() {
+				_, err := tp.Write(payload)
+				writeErr <- err
+			}()
+			got := make([]byte, len(payload))
+			_, err = io.ReadFull(accepted, got)
+			require.NoError(t, err)
+			require.NoError(t, <-writeErr)
+			require.Equal(t, payload, got)
+
+			sent := dialer.(*stcpClient).metrics.bytesOut.Load()
+			if tc.wantCompression != "" {
+				require.Less(t, sent, uint64(len(payload)))
+			} else {
+				require.EqualValues(t, len(payload), sent)
+			}
+		})
+	}
+}
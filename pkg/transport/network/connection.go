@@ -4,6 +4,7 @@ package network
 import (
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/skycoin/dmsg/pkg/dmsg"
@@ -43,13 +44,29 @@ type Transport interface {
 
 	// Network returns network of transport
 	Network() Type
+
+	// Stats returns traffic of transport
+	Stats() ConnStats
+}
+
+// ConnStats is the traffic of a single transport
+type ConnStats struct {
+	BytesIn      uint64        `json:"bytes_in"`
+	BytesOut     uint64        `json:"bytes_out"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Age          time.Duration `json:"age"`
+	LastActivity time.Time     `json:"last_activity"`
 }
 
 type transport struct {
 	net.Conn
+	counters      *connCounters
 	lAddr, rAddr  dmsg.Addr
 	freePort      func()
+	onClose       func()
+	closeOnce     sync.Once
 	transportType Type
+	compression   string
 }
 
 // DoHandshake performs given handshake over given raw connection and wraps
@@ -66,7 +83,7 @@ func doHandshake(rawConn net.Conn, hs handshake.Handshake, netType Type, log *lo
 		if err := rawConn.Close(); err != nil {
 			log.WithError(err).Warnf("Failed to close connection")
 		}
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrHandshakeFailed, err)
 	}
 	handshakedConn := &transport{Conn: rawConn, lAddr: lAddr, rAddr: rAddr, transportType: netType}
 	return handshakedConn, nil
@@ -82,7 +99,7 @@ func (c *transport) encrypt(lPK cipher.PubKey, lSK cipher.SecKey, initator bool)
 
 	wrappedConn, err := EncryptConn(config, c.Conn)
 	if err != nil {
-		return fmt.Errorf("encrypt connection to %v@%v: %w", c.rAddr, c.Conn.RemoteAddr(), err)
+		return fmt.Errorf("%w: encrypt connection to %v@%v: %w", ErrHandshakeFailed, c.rAddr, c.Conn.RemoteAddr(), err)
 	}
 
 	c.Conn = wrappedConn
@@ -126,9 +143,14 @@ func (c *transport) RemoteRawAddr() net.Addr {
 
 // Close implements net.Conn
 func (c *transport) Close() error {
-	if c.freePort != nil {
-		c.freePort()
-	}
+	c.closeOnce.Do(func() {
+		if c.freePort != nil {
+			c.freePort()
+		}
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
 
 	return c.Conn.Close()
 }
@@ -149,3 +171,12 @@ func (c *transport) RemotePort() uint16 { return c.rAddr.Port }
 
 // Network returns network of transport
 func (c *transport) Network() Type { return c.transportType }
+
+// Stats returns traffic of transport
+func (c *transport) Stats() ConnStats { return c.counters.stats() }
+
+// count counts traffic of transport, and of the network if nm is not nil
+func (c *transport) count(nm *netMetrics) {
+	c.counters = newConnCounters()
+	c.Conn = countConn(c.Conn, nm, c.counters)
+}
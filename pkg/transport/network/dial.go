@@ -0,0 +1,251 @@
+// Package network pkg/transport/network/dial.go
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+// ErrNoClients is returned when there are no clients to dial with
+var ErrNoClients = errors.New("no network clients to dial with")
+
+// ErrSourcePortUnsupported is returned when the network can't bind outgoing
+// connections to the requested local port
+var ErrSourcePortUnsupported = errors.New("network does not support dialing from a source port")
+
+// SourcePortDialer is implemented by the clients which can bind outgoing
+// connections to a given local port, as required by some NAT traversal setups
+type SourcePortDialer interface {
+	// DialFrom dials remote visor like Dial, with the raw connection bound to
+	// localPort. A new connection is always made, transports are not multiplexed
+	// over the open ones
+	DialFrom(ctx context.Context, localPort uint16, remote cipher.PubKey, port uint16) (Transport, error)
+}
+
+// DialFrom dials remote visor with the client, binding the raw connection
+// to localPort. It fails with ErrSourcePortUnsupported if the network
+// can't bind outgoing connections
+func DialFrom(ctx context.Context, c Client, localPort uint16, remote cipher.PubKey, port uint16) (Transport, error) {
+	d, ok := c.(SourcePortDialer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSourcePortUnsupported, c.Type())
+	}
+	return d.DialFrom(ctx, localPort, remote, port)
+}
+
+// ErrLocalAddrUnsupported is returned when the network can't bind outgoing
+// connections to the requested local address
+var ErrLocalAddrUnsupported = errors.New("network does not support dialing from a local address")
+
+// ErrInvalidDialOptions is returned when dial options are malformed
+var ErrInvalidDialOptions = errors.New("invalid dial options")
+
+// DialOptions configure the raw connection of a dial. Zero options leave the
+// local address to the OS, as Client.Dial does
+type DialOptions struct {
+	// LocalIP is the address of the local interface the connection originates
+	// from, any interface if nil or unspecified
+	LocalIP net.IP
+	// LocalPort is the local port the connection is bound to, any if zero
+	LocalPort uint16
+}
+
+func (o DialOptions) isZero() bool {
+	return len(o.LocalIP) == 0 && o.LocalPort == 0
+}
+
+func (o DialOptions) validate() error {
+	if len(o.LocalIP) == 0 {
+		return nil
+	}
+	if len(o.LocalIP) != net.IPv4len && len(o.LocalIP) != net.IPv6len {
+		return fmt.Errorf("%w: malformed local IP %v", ErrInvalidDialOptions, o.LocalIP)
+	}
+	if o.LocalIP.IsMulticast() {
+		return fmt.Errorf("%w: multicast local IP %v", ErrInvalidDialOptions, o.LocalIP)
+	}
+	return nil
+}
+
+// tcpAddr returns the local TCP address to bind to, nil for zero options
+func (o DialOptions) tcpAddr() *net.TCPAddr {
+	if o.isZero() {
+		return nil
+	}
+	return &net.TCPAddr{IP: o.LocalIP, Port: int(o.LocalPort)}
+}
+
+// LocalAddrDialer is implemented by the clients which can bind outgoing
+// connections to a local address, so that multi-homed hosts dial from
+// the given interface
+type LocalAddrDialer interface {
+	// DialWithOptions dials remote visor like Dial, with the raw connection
+	// bound as given by opts. Unless opts are zero, a new connection is
+	// always made, transports are not multiplexed over the open ones
+	DialWithOptions(ctx context.Context, opts DialOptions, remote cipher.PubKey, port uint16) (Transport, error)
+}
+
+// LocalAddrInUseError is returned when the local address a dial was requested
+// from is in use. It matches the underlying error, such as syscall.EADDRINUSE
+type LocalAddrInUseError struct {
+	Network   Type
+	LocalAddr string
+	Err       error
+}
+
+// Error implements error
+func (e *LocalAddrInUseError) Error() string {
+	return fmt.Sprintf("dial %s from %s: local address in use: %v", e.Network, e.LocalAddr, e.Err)
+}
+
+// Unwrap implements errors unwrapping
+func (e *LocalAddrInUseError) Unwrap() error {
+	return e.Err
+}
+
+// Dial dials remote visor with the client. Without options it is the same as
+// c.Dial. With options the raw connection is bound as given by them, which
+// fails with ErrLocalAddrUnsupported if the client can't bind outgoing
+// connections. Clients binding to a source port only support LocalPort
+func Dial(ctx context.Context, c Client, remote cipher.PubKey, port uint16, opts ...DialOptions) (Transport, error) {
+	if len(opts) > 1 {
+		return nil, fmt.Errorf("%w: %d options given", ErrInvalidDialOptions, len(opts))
+	}
+	if len(opts) == 0 || opts[0].isZero() {
+		return c.Dial(ctx, remote, port)
+	}
+	o := opts[0]
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	if d, ok := c.(LocalAddrDialer); ok {
+		return d.DialWithOptions(ctx, o, remote, port)
+	}
+	if len(o.LocalIP) == 0 {
+		if d, ok := c.(SourcePortDialer); ok {
+			return d.DialFrom(ctx, o.LocalPort, remote, port)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrLocalAddrUnsupported, c.Type())
+}
+
+// DefaultDialOrder is the order networks are dialed in when no order is given:
+// direct networks first, dmsg last
+var DefaultDialOrder = []Type{STCPR, SQUIC, SUDPH, STCP, DMSG}
+
+// DefaultDialTimeout bounds dials with contexts that have no deadline,
+// unless configured otherwise in ClientFactory
+const DefaultDialTimeout = 30 * time.Second
+
+// DialTimeoutError is returned when dial to remote visor, including the
+// handshake, did not complete before the deadline. It matches ErrDialTimeout
+// and context.DeadlineExceeded
+type DialTimeoutError struct {
+	Network  Type
+	RemotePK cipher.PubKey
+	Err      error
+}
+
+// Error implements error
+func (e *DialTimeoutError) Error() string {
+	return fmt.Sprintf("dial %s to %s timed out: %v", e.Network, e.RemotePK, e.Err)
+}
+
+// Unwrap implements errors unwrapping
+func (e *DialTimeoutError) Unwrap() []error {
+	return []error{ErrDialTimeout, context.DeadlineExceeded, e.Err}
+}
+
+// withDialTimeout bounds ctx with timeout, unless it has a deadline already
+// or timeout is not positive
+func withDialTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// dialTimeoutErr wraps err into DialTimeoutError if the dial ran out of time
+func dialTimeoutErr(ctx context.Context, netType Type, rPK cipher.PubKey, err error) error {
+	if err == nil || (!errors.Is(err, context.DeadlineExceeded) && !errors.Is(ctx.Err(), context.DeadlineExceeded)) {
+		return err
+	}
+	var timeoutErr *DialTimeoutError
+	if errors.As(err, &timeoutErr) {
+		return err
+	}
+	return &DialTimeoutError{Network: netType, RemotePK: rPK, Err: err}
+}
+
+// DialAny dials remote visor on the given skywire port using clients in the
+// given order and returns the first successfully established transport.
+// If concurrent is set, all clients are dialed at once, the remaining dials
+// are cancelled as soon as one succeeds and late transports are closed.
+// If all dials fail, the returned error contains errors of all clients
+func DialAny(ctx context.Context, clients []Client, remote cipher.PubKey, port uint16, concurrent bool) (Transport, error) {
+	if len(clients) == 0 {
+		return nil, ErrNoClients
+	}
+	if concurrent {
+		return dialConcurrent(ctx, clients, remote, port)
+	}
+
+	errs := make([]error, 0, len(clients))
+	for _, c := range clients {
+		tp, err := c.Dial(ctx, remote, port)
+		if err == nil {
+			return tp, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", c.Type(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+type dialResult struct {
+	idx int
+	tp  Transport
+	err error
+}
+
+func dialConcurrent(ctx context.Context, clients []Client, remote cipher.PubKey, port uint16) (Transport, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resCh := make(chan dialResult, len(clients))
+	for i, c := range clients {
+		go func(i int, c Client) {
+			tp, err := c.Dial(ctx, remote, port)
+			resCh <- dialResult{idx: i, tp: tp, err: err}
+		}(i, c)
+	}
+
+	errs := make([]error, len(clients))
+	for range clients {
+		res := <-resCh
+		if res.err != nil {
+			errs[res.idx] = fmt.Errorf("%s: %w", clients[res.idx].Type(), res.err)
+			continue
+		}
+		cancel()
+		go closeLateTransports(resCh, len(clients)-1)
+		return res.tp, nil
+	}
+	return nil, errors.Join(errs...)
+}
+
+// closeLateTransports closes transports of dials that succeeded after the winner
+func closeLateTransports(resCh <-chan dialResult, n int) {
+	for i := 0; i < n; i++ {
+		if res := <-resCh; res.err == nil {
+			res.tp.Close() //nolint: errcheck, gosec
+		}
+	}
+}
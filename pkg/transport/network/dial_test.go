@@ -0,0 +1,330 @@
+// Package network pkg/transport/network/dial_test.go
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/AudriusButkevicius/pfilter"
+	"github.com/skycoin/dmsg/pkg/noise"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+	"github.com/skycoin/skywire/pkg/app/appevent"
+	"github.com/skycoin/skywire/pkg/transport/network/addrresolver"
+)
+
+// fakeClient is a Client that dials using the provided function
+type fakeClient struct {
+	Client
+	netType Type
+	dials   int32
+	dial    func(ctx context.Context) (Transport, error)
+}
+
+func (c *fakeClient) Dial(ctx context.Context, _ cipher.PubKey, _ uint16) (Transport, error) {
+	atomic.AddInt32(&c.dials, 1)
+	return c.dial(ctx)
+}
+
+func (c *fakeClient) Type() Type {
+	return c.netType
+}
+
+func failingClient(netType Type, err error) *fakeClient {
+	return &fakeClient{netType: netType, dial: func(context.Context) (Transport, error) {
+		return nil, err
+	}}
+}
+
+func succeedingClient(t *testing.T, netType Type) *fakeClient {
+	return &fakeClient{netType: netType, dial: func(context.Context) (Transport, error) {
+		c1, c2 := net.Pipe()
+		t.Cleanup(func() { c2.Close() }) //nolint: errcheck, gosec
+		return &transport{Conn: c1, transportType: netType}, nil
+	}}
+}
+
+func TestDialAny(t *testing.T) {
+	pk, _ := cipher.GenerateKeyPair()
+	errStcpr := errors.New("stcpr failure")
+	errSudph := errors.New("sudph failure")
+
+	t.Run("later network succeeds", func(t *testing.T) {
+		for _, concurrent := range []bool{false, true} {
+			stcpr := failingClient(STCPR, errStcpr)
+			dmsg := succeedingClient(t, DMSG)
+
+			tp, err := DialAny(context.Background(), []Client{stcpr, dmsg}, pk, 10, concurrent)
+			require.NoError(t, err)
+			require.Equal(t, DMSG, tp.Network())
+			// in concurrent mode the losing dial may start after the winner returned
+			require.Eventually(t, func() bool { return atomic.LoadInt32(&stcpr.dials) == 1 }, time.Second, time.Millisecond)
+			require.EqualValues(t, 1, atomic.LoadInt32(&dmsg.dials))
+			require.NoError(t, tp.Close())
+		}
+	})
+
+	t.Run("first success stops sequential dialing", func(t *testing.T) {
+		stcpr := succeedingClient(t, STCPR)
+		dmsg := succeedingClient(t, DMSG)
+
+		tp, err := DialAny(context.Background(), []Client{stcpr, dmsg}, pk, 10, false)
+		require.NoError(t, err)
+		require.Equal(t, STCPR, tp.Network())
+		require.EqualValues(t, 0, atomic.LoadInt32(&dmsg.dials))
+		require.NoError(t, tp.Close())
+	})
+
+	t.Run("concurrent dial cancels losers", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		slow := &fakeClient{netType: DMSG, dial: func(ctx context.Context) (Transport, error) {
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		}}
+		fast := succeedingClient(t, STCPR)
+
+		tp, err := DialAny(context.Background(), []Client{slow, fast}, pk, 10, true)
+		require.NoError(t, err)
+		require.Equal(t, STCPR, tp.Network())
+		require.NoError(t, tp.Close())
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("losing dial was not cancelled")
+		}
+	})
+
+	t.Run("all networks fail", func(t *testing.T) {
+		for _, concurrent := range []bool{false, true} {
+			clients := []Client{failingClient(STCPR, errStcpr), failingClient(SUDPH, errSudph)}
+
+			_, err := DialAny(context.Background(), clients, pk, 10, concurrent)
+			require.ErrorIs(t, err, errStcpr)
+			require.ErrorIs(t, err, errSudph)
+		}
+	})
+
+	t.Run("no clients", func(t *testing.T) {
+		_, err := DialAny(context.Background(), nil, pk, 10, false)
+		require.ErrorIs(t, err, ErrNoClients)
+	})
+}
+
+// stubAR is an address resolver binding SUDPH, which resolves visors using
+// the given addresses
+type stubAR struct {
+	addrresolver.APIClient
+	addrs map[cipher.PubKey]string
+}
+
+func (r *stubAR) BindSUDPH(*pfilter.PacketFilter, addrresolver.Handshake) (<-chan addrresolver.RemoteVisor, error) {
+	return make(chan addrresolver.RemoteVisor), nil
+}
+
+func (r *stubAR) Resolve(_ context.Context, _ string, pk cipher.PubKey) (addrresolver.VisorData, error) {
+	addr, ok := r.addrs[pk]
+	if !ok {
+		return addrresolver.VisorData{}, addrresolver.ErrNoEntry
+	}
+	return addrresolver.VisorData{RemoteAddr: addr}, nil
+}
+
+func newTestSUDPHPair(t testing.TB, addr func(remote net.Addr) string) (Client, Client) {
+	newClient := func(ar addrresolver.APIClient) Client {
+		pk, sk := cipher.GenerateKeyPair()
+		f := &ClientFactory{
+			PK:       pk,
+			SK:       sk,
+			ARClient: ar,
+			EB:       appevent.NewBroadcaster(nil, time.Second),
+		}
+		c, err := f.MakeClient(SUDPH, 0)
+		require.NoError(t, err)
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Close()) })
+		return c
+	}
+
+	remote := newClient(&stubAR{})
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+	dialer := newClient(&stubAR{addrs: map[cipher.PubKey]string{remote.PK(): addr(remoteAddr)}})
+	return dialer, remote
+}
+
+// freePort returns a local port which is not in use on the network
+func freePort(t *testing.T, network string) uint16 {
+	var addr net.Addr
+	switch network {
+	case "tcp":
+		lis, err := net.Listen(network, "127.0.0.1:0")
+		require.NoError(t, err)
+		addr = lis.Addr()
+		require.NoError(t, lis.Close())
+	default:
+		conn, err := net.ListenPacket(network, "127.0.0.1:0")
+		require.NoError(t, err)
+		addr = conn.LocalAddr()
+		require.NoError(t, conn.Close())
+	}
+	_, port, err := net.SplitHostPort(addr.String())
+	require.NoError(t, err)
+	p, err := strconv.ParseUint(port, 10, 16)
+	require.NoError(t, err)
+	return uint16(p)
+}
+
+func TestDialFrom(t *testing.T) {
+	tests := []struct {
+		newPair directTransportPair
+		network string
+	}{
+		{newPair: newTestSTCPPair, network: "tcp"},
+		{newPair: newTestSUDPHPair, network: "udp"},
+	}
+	for _, tc := range tests {
+		dialer, remote := tc.newPair(t, loopbackAddr)
+		t.Run(string(dialer.Type()), func(t *testing.T) {
+			lis, err := remote.Listen(testTransportPort)
+			require.NoError(t, err)
+			defer lis.Close() //nolint:errcheck
+
+			localPort := freePort(t, tc.network)
+			acceptCh := make(chan Transport, 1)
+			go func() {
+				tp, err := lis.AcceptTransport()
+				if err == nil {
+					acceptCh <- tp
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			tp, err := DialFrom(ctx, dialer, localPort, remote.PK(), testTransportPort)
+			require.NoError(t, err)
+			defer tp.Close() //nolint:errcheck
+			require.Equal(t, remote.PK(), tp.RemotePK())
+
+			var accepted Transport
+			select {
+			case accepted = <-acceptCh:
+			case <-ctx.Done():
+				t.Fatal("transport was not accepted")
+			}
+			defer accepted.Close() //nolint:errcheck
+
+			// the remote visor sees the connection coming from the source port
+			rawAddr, ok := accepted.RemoteRawAddr().(*noise.Addr)
+			require.True(t, ok)
+			_, port, err := net.SplitHostPort(rawAddr.Addr.String())
+			require.NoError(t, err)
+			require.Equal(t, strconv.Itoa(int(localPort)), port)
+		})
+	}
+}
+
+func TestDialFrom_Unsupported(t *testing.T) {
+	dialer, remote := newTestSQUICPair(t, loopbackAddr)
+	_, err := DialFrom(context.Background(), dialer, freePort(t, "udp"), remote.PK(), testTransportPort)
+	require.ErrorIs(t, err, ErrSourcePortUnsupported)
+}
+
+func TestSUDPH_DialWhileBinding(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	f := &ClientFactory{
+		PK:       pk,
+		SK:       sk,
+		ARClient: &stubAR{},
+		EB:       appevent.NewBroadcaster(nil, time.Second),
+		MLogger:  logging.NewMasterLogger(),
+	}
+	c, err := f.MakeClient(SUDPH, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, c.Close()) })
+
+	// dials are rejected until the socket is bound, which happens concurrently
+	require.NoError(t, c.Start())
+	require.Eventually(t, func() bool {
+		conn, err := c.(*sudphClient).dial("127.0.0.1:1")
+		if err != nil {
+			require.ErrorIs(t, err, ErrNetworkNotReady)
+			return false
+		}
+		return conn.Close() == nil
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestDial_Options(t *testing.T) {
+	dialer, remote := newTestSTCPPair(t, loopbackAddr)
+	lis, err := remote.Listen(testTransportPort)
+	require.NoError(t, err)
+	defer lis.Close() //nolint:errcheck
+	go func() {
+		for {
+			tp, err := lis.AcceptTransport()
+			if err != nil {
+				return
+			}
+			defer tp.Close() //nolint:errcheck
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("local address", func(t *testing.T) {
+		localPort := freePort(t, "tcp")
+		opts := DialOptions{LocalIP: net.IPv4(127, 0, 0, 1), LocalPort: localPort}
+		tp, err := Dial(ctx, dialer, remote.PK(), testTransportPort, opts)
+		require.NoError(t, err)
+		defer tp.Close() //nolint:errcheck
+		rawAddr, ok := tp.LocalRawAddr().(*noise.Addr)
+		require.True(t, ok)
+		require.Equal(t, "127.0.0.1:"+strconv.Itoa(int(localPort)), rawAddr.Addr.String())
+	})
+
+	t.Run("local address in use", func(t *testing.T) {
+		busy, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer busy.Close() //nolint:errcheck
+		opts := DialOptions{LocalPort: uint16(busy.Addr().(*net.TCPAddr).Port)}
+		_, err = Dial(ctx, dialer, remote.PK(), testTransportPort, opts)
+		var inUse *LocalAddrInUseError
+		require.ErrorAs(t, err, &inUse)
+		require.Equal(t, STCP, inUse.Network)
+		require.ErrorIs(t, err, syscall.EADDRINUSE)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Dial(ctx, dialer, remote.PK(), testTransportPort, DialOptions{LocalIP: net.IP{1, 2}})
+		require.ErrorIs(t, err, ErrInvalidDialOptions)
+		_, err = Dial(ctx, dialer, remote.PK(), testTransportPort, DialOptions{LocalIP: net.IPv4(224, 0, 0, 1)})
+		require.ErrorIs(t, err, ErrInvalidDialOptions)
+		_, err = Dial(ctx, dialer, remote.PK(), testTransportPort, DialOptions{}, DialOptions{})
+		require.ErrorIs(t, err, ErrInvalidDialOptions)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		sqDialer, sqRemote := newTestSQUICPair(t, loopbackAddr)
+		opts := DialOptions{LocalIP: net.IPv4(127, 0, 0, 1)}
+		_, err := Dial(ctx, sqDialer, sqRemote.PK(), testTransportPort, opts)
+		require.ErrorIs(t, err, ErrLocalAddrUnsupported)
+	})
+
+	t.Run("no options", func(t *testing.T) {
+		fc := failingClient(STCP, errors.New("dialed"))
+		_, err := Dial(ctx, fc, remote.PK(), testTransportPort)
+		require.EqualError(t, err, "dialed")
+		require.EqualValues(t, 1, atomic.LoadInt32(&fc.dials))
+	})
+}
@@ -3,8 +3,12 @@ package network
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/skycoin/dmsg/pkg/dmsg"
 
@@ -14,11 +18,15 @@ import (
 // dmsgClientAdapter is a wrapper around dmsg.Client to conform to Client
 // interface
 type dmsgClientAdapter struct {
-	dmsgC *dmsg.Client
+	dmsgC       *dmsg.Client
+	events      connEventer
+	dialTimeout time.Duration
+	listeners   atomic.Int64 // open listeners
+	limiter     *netLimiter
 }
 
-func newDmsgClient(dmsgC *dmsg.Client) Client {
-	return &dmsgClientAdapter{dmsgC: dmsgC}
+func newDmsgClient(dmsgC *dmsg.Client, events connEventer, dialTimeout time.Duration, limiter *netLimiter) Client {
+	return &dmsgClientAdapter{dmsgC: dmsgC, events: events, dialTimeout: dialTimeout, limiter: limiter}
 }
 
 // LocalAddr implements interface
@@ -31,11 +39,39 @@ func (c *dmsgClientAdapter) LocalAddr() (net.Addr, error) {
 
 // Dial implements Client interface
 func (c *dmsgClientAdapter) Dial(ctx context.Context, remote cipher.PubKey, port uint16) (Transport, error) {
+	ctx, cancel := withDialTimeout(ctx, c.dialTimeout)
+	defer cancel()
+
+	start := c.events.dialStarted(remote)
 	transport, err := c.dmsgC.DialStream(ctx, dmsg.Addr{PK: remote, Port: port})
+	err = dialTimeoutErr(ctx, DMSG, remote, c.dialErr(err))
+	c.events.dialDone(remote, start, err)
 	if err != nil {
 		return nil, err
 	}
-	return &dmsgTransportAdapter{transport}, nil
+	return newDmsgTransport(transport, c.events, c.limiter), nil
+}
+
+// dialErr wraps errors of dmsg dials into the errors of the package,
+// so that they are classified the same way as the direct ones
+func (c *dmsgClientAdapter) dialErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(err, dmsg.ErrDiscEntryNotFound), errors.Is(err, dmsg.ErrDiscEntryIsNotClient),
+		errors.Is(err, dmsg.ErrDiscEntryHasNoDelegated):
+		return fmt.Errorf("%w: %w", ErrPKNotResolved, err)
+	case errors.Is(err, dmsg.ErrDialRespInvalidSig), errors.Is(err, dmsg.ErrDialRespInvalidHash),
+		errors.Is(err, dmsg.ErrSessionHandshakeExtraBytes):
+		return fmt.Errorf("%w: %w", ErrHandshakeFailed, err)
+	}
+	select {
+	case <-c.dmsgC.Ready():
+		return err
+	default:
+		return fmt.Errorf("%w: %w", ErrNetworkNotReady, err)
+	}
 }
 
 // Start implements Client interface
@@ -47,10 +83,18 @@ func (c *dmsgClientAdapter) Start() error {
 // Listen implements Client interface
 func (c *dmsgClientAdapter) Listen(port uint16) (Listener, error) {
 	lis, err := c.dmsgC.Listen(port)
+	if errors.Is(err, dmsg.ErrPortOccupied) {
+		return nil, fmt.Errorf("%w: %w", ErrPortOccupied, err)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &dmsgListenerAdapter{lis}, nil
+	c.listeners.Add(1)
+	listenerClosed := c.events.metrics.listenerOpened()
+	return newDmsgListenerAdapter(lis, c.events, c.limiter, func() {
+		c.listeners.Add(-1)
+		listenerClosed()
+	}), nil
 }
 
 // PK implements Client interface
@@ -77,17 +121,90 @@ func (c *dmsgClientAdapter) Type() Type {
 
 // wrapper around listener returned by dmsg.Client
 // that conforms to Listener interface
+// dmsg listener has no deadlines, so streams are accepted by a single goroutine
+// and handed over to accepts, which can stop waiting without closing the listener
 type dmsgListenerAdapter struct {
 	*dmsg.Listener
+	events    connEventer
+	limiter   *netLimiter
+	onClose   func()
+	closeOnce sync.Once
+	deadline  acceptDeadline
+
+	startAccepting sync.Once
+	accepted       chan *dmsg.Stream
+	acceptErr      error         // set before acceptDone is closed
+	acceptDone     chan struct{} // closed once accepting streams fails
+	closed         chan struct{}
+}
+
+func newDmsgListenerAdapter(lis *dmsg.Listener, events connEventer, limiter *netLimiter, onClose func()) *dmsgListenerAdapter {
+	return &dmsgListenerAdapter{
+		Listener:   lis,
+		events:     events,
+		limiter:    limiter,
+		onClose:    onClose,
+		accepted:   make(chan *dmsg.Stream),
+		acceptDone: make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+}
+
+// Close implements net.Listener
+func (lis *dmsgListenerAdapter) Close() error {
+	lis.closeOnce.Do(func() {
+		close(lis.closed)
+		lis.onClose()
+	})
+	return lis.Listener.Close()
 }
 
 // AcceptTransport implements Listener interface
 func (lis *dmsgListenerAdapter) AcceptTransport() (Transport, error) {
-	stream, err := lis.Listener.AcceptStream()
-	if err != nil {
-		return nil, err
+	return lis.AcceptContext(context.Background())
+}
+
+// AcceptContext implements Listener interface
+func (lis *dmsgListenerAdapter) AcceptContext(ctx context.Context) (Transport, error) {
+	lis.startAccepting.Do(func() { go lis.acceptStreams() })
+	stop, cause, release := lis.deadline.watch(ctx)
+	defer release()
+	select {
+	case stream := <-lis.accepted:
+		lis.events.accepted(stream.RawRemoteAddr().PK)
+		return newDmsgTransport(stream, lis.events, lis.limiter), nil
+	case <-lis.acceptDone:
+		return nil, lis.acceptErr
+	case <-stop:
+		return nil, cause()
+	}
+}
+
+// SetDeadline implements Listener interface
+func (lis *dmsgListenerAdapter) SetDeadline(t time.Time) error {
+	lis.deadline.set(t)
+	return nil
+}
+
+// acceptStreams accepts dmsg streams until the listener is closed. A stream
+// is kept until the next accept takes it or the listener is closed
+func (lis *dmsgListenerAdapter) acceptStreams() {
+	for {
+		stream, err := lis.Listener.AcceptStream()
+		if err != nil {
+			if errors.Is(err, dmsg.ErrEntityClosed) {
+				err = ErrListenerClosed
+			}
+			lis.acceptErr = err
+			close(lis.acceptDone)
+			return
+		}
+		select {
+		case lis.accepted <- stream:
+		case <-lis.closed:
+			stream.Close() //nolint: errcheck, gosec
+		}
 	}
-	return &dmsgTransportAdapter{stream}, nil
 }
 
 // Network implements Listener interface
@@ -109,6 +226,36 @@ func (lis *dmsgListenerAdapter) Port() uint16 {
 // that conforms to Transport interface
 type dmsgTransportAdapter struct {
 	*dmsg.Stream
+	conn      net.Conn // stream, counting transferred bytes and limiting bandwidth
+	counters  *connCounters
+	onClose   func()
+	closeOnce sync.Once
+}
+
+func newDmsgTransport(stream *dmsg.Stream, events connEventer, limiter *netLimiter) *dmsgTransportAdapter {
+	counters := newConnCounters()
+	return &dmsgTransportAdapter{
+		Stream:   stream,
+		conn:     limitConn(countConn(stream, events.metrics, counters), limiter),
+		counters: counters,
+		onClose:  events.closeFunc(stream.RawRemoteAddr().PK),
+	}
+}
+
+// Read implements net.Conn
+func (c *dmsgTransportAdapter) Read(b []byte) (int, error) {
+	return c.conn.Read(b)
+}
+
+// Write implements net.Conn
+func (c *dmsgTransportAdapter) Write(b []byte) (int, error) {
+	return c.conn.Write(b)
+}
+
+// Close implements net.Conn
+func (c *dmsgTransportAdapter) Close() error {
+	c.closeOnce.Do(c.onClose)
+	return c.Stream.Close()
 }
 
 // LocalPK implements Transport interface
@@ -145,3 +292,8 @@ func (c *dmsgTransportAdapter) RemoteRawAddr() net.Addr {
 func (c *dmsgTransportAdapter) Network() Type {
 	return DMSG
 }
+
+// Stats implements Transport interface
+func (c *dmsgTransportAdapter) Stats() ConnStats {
+	return c.counters.stats()
+}
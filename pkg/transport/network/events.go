@@ -0,0 +1,78 @@
+// Package network pkg/transport/network/events.go
+package network
+
+import (
+	"time"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+// ConnEventType is a type of connection lifecycle event
+type ConnEventType string
+
+const (
+	// ConnEventDialStart is emitted when client starts dialing remote visor
+	ConnEventDialStart ConnEventType = "dial_start"
+	// ConnEventDialSuccess is emitted when dialed transport is established
+	ConnEventDialSuccess ConnEventType = "dial_success"
+	// ConnEventDialFail is emitted when dial to remote visor failed
+	ConnEventDialFail ConnEventType = "dial_fail"
+	// ConnEventAccept is emitted when transport from remote visor is accepted
+	ConnEventAccept ConnEventType = "accept"
+	// ConnEventClose is emitted when established transport is closed
+	ConnEventClose ConnEventType = "close"
+)
+
+// ConnEvent describes a lifecycle event of a single connection
+type ConnEvent struct {
+	Type     ConnEventType
+	Network  Type
+	RemotePK cipher.PubKey
+	Err      error
+	// Latency is time spent dialing, set for dial success and dial fail events only
+	Latency time.Duration
+}
+
+// connEventer emits connection lifecycle events to the configured callback
+// and counts them in network metrics
+type connEventer struct {
+	netType Type
+	onConn  func(ConnEvent)
+	metrics *netMetrics
+	status  *clientStatus
+}
+
+func (e connEventer) send(ev ConnEvent) {
+	e.metrics.record(ev)
+	e.status.record(ev)
+	if e.onConn == nil {
+		return
+	}
+	ev.Network = e.netType
+	e.onConn(ev)
+}
+
+// dialStarted emits dial start event and returns dial start time
+func (e connEventer) dialStarted(rPK cipher.PubKey) time.Time {
+	e.send(ConnEvent{Type: ConnEventDialStart, RemotePK: rPK})
+	return time.Now()
+}
+
+// dialDone emits either dial success or dial fail event, depending on err
+func (e connEventer) dialDone(rPK cipher.PubKey, start time.Time, err error) {
+	ev := ConnEvent{Type: ConnEventDialSuccess, RemotePK: rPK, Err: err, Latency: time.Since(start)}
+	if err != nil {
+		ev.Type = ConnEventDialFail
+	}
+	e.send(ev)
+}
+
+// accepted emits accept event
+func (e connEventer) accepted(rPK cipher.PubKey) {
+	e.send(ConnEvent{Type: ConnEventAccept, RemotePK: rPK})
+}
+
+// closeFunc returns a function emitting close event for the given remote
+func (e connEventer) closeFunc(rPK cipher.PubKey) func() {
+	return func() { e.send(ConnEvent{Type: ConnEventClose, RemotePK: rPK}) }
+}
@@ -0,0 +1,96 @@
+// Package network pkg/transport/network/events_test.go
+package network
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+type eventRecorder struct {
+	mx     sync.Mutex
+	events []ConnEvent
+}
+
+func (r *eventRecorder) record(e ConnEvent) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) types() []ConnEventType {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	var types []ConnEventType
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestClient_ConnEvents(t *testing.T) {
+	const port = 10
+
+	var dialerEvents, remoteEvents eventRecorder
+	dialer := newTestSTCPClient(t, dialerEvents.record)
+	remote := newTestSTCPClient(t, remoteEvents.record)
+
+	lis, err := remote.Listen(port)
+	require.NoError(t, err)
+
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	unknownPK, _ := cipher.GenerateKeyPair()
+	_, err = dialer.Dial(ctx, unknownPK, port)
+	require.Error(t, err)
+	require.Equal(t, []ConnEventType{ConnEventDialStart, ConnEventDialFail}, dialerEvents.types())
+
+	dialer.AddPKEntry(remote.PK(), remoteAddr.String())
+
+	acceptCh := make(chan Transport, 1)
+	go func() {
+		tp, err := lis.AcceptTransport()
+		if err == nil {
+			acceptCh <- tp
+		}
+	}()
+
+	tp, err := dialer.Dial(ctx, remote.PK(), port)
+	require.NoError(t, err)
+
+	var accepted Transport
+	select {
+	case accepted = <-acceptCh:
+	case <-ctx.Done():
+		t.Fatal("transport was not accepted")
+	}
+
+	require.NoError(t, tp.Close())
+	require.Error(t, tp.Close())
+	require.NoError(t, accepted.Close())
+
+	require.Equal(t, []ConnEventType{
+		ConnEventDialStart, ConnEventDialFail,
+		ConnEventDialStart, ConnEventDialSuccess,
+		ConnEventClose,
+	}, dialerEvents.types())
+	require.Equal(t, []ConnEventType{ConnEventAccept, ConnEventClose}, remoteEvents.types())
+
+	dialerEvents.mx.Lock()
+	defer dialerEvents.mx.Unlock()
+	require.True(t, errors.Is(dialerEvents.events[1].Err, ErrStcpEntryNotFound))
+	for _, e := range dialerEvents.events[2:] {
+		require.Equal(t, STCP, e.Network)
+		require.Equal(t, remote.PK(), e.RemotePK)
+	}
+}
@@ -34,10 +34,11 @@ func (err Error) Error() string {
 	return fmt.Sprintln("handshake failed:", string(err))
 }
 
-// IsHandshakeError determines whether the error occurred during the handshake.
+// IsHandshakeError determines whether the error occurred during the handshake,
+// the error may be wrapped.
 func IsHandshakeError(err error) bool {
-	_, ok := err.(Error)
-	return ok
+	var hsErr Error
+	return errors.As(err, &hsErr)
 }
 
 // middleware to add deadline and Error to handshakes.
@@ -62,8 +63,91 @@ func handshakeMiddleware(origin Handshake) Handshake {
 // Handshake represents a handshake.
 type Handshake func(conn net.Conn, deadline time.Time) (lAddr, rAddr dmsg.Addr, err error)
 
+// Compression configures negotiation of the transport compression.
+// Compression is used only if both sides support it, otherwise
+// the handshake falls back to no compression.
+type Compression struct {
+	// Supported compression algorithms in the order of preference.
+	Supported []string
+	// Negotiated is called with the algorithm agreed on by both sides
+	// once the handshake succeeds, empty algorithm means no compression.
+	Negotiated func(alg string)
+}
+
+func (c Compression) supports(alg string) bool {
+	for _, s := range c.Supported {
+		if s == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// choose returns the first algorithm supported by both sides.
+func (c Compression) choose(remote []string) string {
+	for _, alg := range c.Supported {
+		for _, r := range remote {
+			if alg == r {
+				return alg
+			}
+		}
+	}
+	return ""
+}
+
+func (c Compression) negotiated(alg string) {
+	if c.Negotiated != nil {
+		c.Negotiated(alg)
+	}
+}
+
+// Mux configures negotiation of multiplexing transports over the connection.
+// The connection is multiplexed only if both sides support it.
+type Mux struct {
+	// Supported is true if the side can multiplex transports over the connection.
+	Supported bool
+	// Negotiated is called once the handshake succeeds with true
+	// if the connection is to be multiplexed.
+	Negotiated func(muxed bool)
+}
+
+func (m Mux) negotiated(muxed bool) {
+	if m.Negotiated != nil {
+		m.Negotiated(muxed)
+	}
+}
+
+// Heartbeat configures negotiation of heartbeat frames sent over the connection
+// to detect dead peers. Heartbeats are sent only if both sides support them.
+type Heartbeat struct {
+	// Supported is true if the side can exchange heartbeats over the connection.
+	Supported bool
+	// Negotiated is called once the handshake succeeds with true
+	// if heartbeats are to be exchanged.
+	Negotiated func(heartbeat bool)
+}
+
+func (h Heartbeat) negotiated(heartbeat bool) {
+	if h.Negotiated != nil {
+		h.Negotiated(heartbeat)
+	}
+}
+
+// Options configures features negotiated during the handshake.
+type Options struct {
+	Compression Compression
+	Mux         Mux
+	Heartbeat   Heartbeat
+}
+
 // InitiatorHandshake creates the handshake logic on the initiator's side.
 func InitiatorHandshake(lSK cipher.SecKey, localAddr, remoteAddr dmsg.Addr) Handshake {
+	return InitiatorHandshakeWithOptions(lSK, localAddr, remoteAddr, Options{})
+}
+
+// InitiatorHandshakeWithOptions creates the handshake logic on the initiator's side,
+// which also negotiates the features configured by opts.
+func InitiatorHandshakeWithOptions(lSK cipher.SecKey, localAddr, remoteAddr dmsg.Addr, opts Options) Handshake {
 	return handshakeMiddleware(func(conn net.Conn, deadline time.Time) (lAddr, rAddr dmsg.Addr, err error) {
 		if err = writeFrame0(conn); err != nil {
 			return dmsg.Addr{}, dmsg.Addr{}, err
@@ -74,7 +158,14 @@ func InitiatorHandshake(lSK cipher.SecKey, localAddr, remoteAddr dmsg.Addr) Hand
 			return dmsg.Addr{}, dmsg.Addr{}, err
 		}
 
-		f2 := Frame2{SrcAddr: localAddr, DstAddr: remoteAddr, Nonce: f1.Nonce}
+		f2 := Frame2{
+			SrcAddr:     localAddr,
+			DstAddr:     remoteAddr,
+			Nonce:       f1.Nonce,
+			Compression: opts.Compression.choose(f1.Compression),
+			Mux:         opts.Mux.Supported && f1.Mux,
+			Heartbeat:   opts.Heartbeat.Supported && f1.Heartbeat,
+		}
 		if err = f2.Sign(lSK); err != nil {
 			return dmsg.Addr{}, dmsg.Addr{}, err
 		}
@@ -95,6 +186,9 @@ func InitiatorHandshake(lSK cipher.SecKey, localAddr, remoteAddr dmsg.Addr) Hand
 
 		lAddr = localAddr
 		rAddr = remoteAddr
+		opts.Compression.negotiated(f2.Compression)
+		opts.Mux.negotiated(f2.Mux)
+		opts.Heartbeat.negotiated(f2.Heartbeat)
 
 		return lAddr, rAddr, nil
 	})
@@ -113,6 +207,12 @@ func MakeF2PortChecker(portChecker func(port uint16) error) CheckF2 {
 
 // ResponderHandshake creates the handshake logic on the responder's side.
 func ResponderHandshake(checkF2 CheckF2) Handshake {
+	return ResponderHandshakeWithOptions(checkF2, Options{})
+}
+
+// ResponderHandshakeWithOptions creates the handshake logic on the responder's side,
+// which also negotiates the features configured by opts.
+func ResponderHandshakeWithOptions(checkF2 CheckF2, opts Options) Handshake {
 	return handshakeMiddleware(func(conn net.Conn, deadline time.Time) (lAddr, rAddr dmsg.Addr, err error) {
 		if err = readFrame0(conn); err != nil {
 			return dmsg.Addr{}, dmsg.Addr{}, err
@@ -121,7 +221,12 @@ func ResponderHandshake(checkF2 CheckF2) Handshake {
 		var nonce [NonceSize]byte
 		copy(nonce[:], cipher.RandByte(NonceSize))
 
-		if err = writeFrame1(conn, nonce); err != nil {
+		if err = writeFrame1(conn, Frame1{
+			Nonce:       nonce,
+			Compression: opts.Compression.Supported,
+			Mux:         opts.Mux.Supported,
+			Heartbeat:   opts.Heartbeat.Supported,
+		}); err != nil {
 			return dmsg.Addr{}, dmsg.Addr{}, err
 		}
 
@@ -139,11 +244,32 @@ func ResponderHandshake(checkF2 CheckF2) Handshake {
 			return dmsg.Addr{}, dmsg.Addr{}, err
 		}
 
+		if f2.Compression != "" && !opts.Compression.supports(f2.Compression) {
+			err = fmt.Errorf("unsupported compression: %s", f2.Compression)
+			_ = writeFrame3(conn, err) // nolint:errcheck
+			return dmsg.Addr{}, dmsg.Addr{}, err
+		}
+
+		if f2.Mux && !opts.Mux.Supported {
+			err = errors.New("multiplexing is not supported")
+			_ = writeFrame3(conn, err) // nolint:errcheck
+			return dmsg.Addr{}, dmsg.Addr{}, err
+		}
+
+		if f2.Heartbeat && !opts.Heartbeat.Supported {
+			err = errors.New("heartbeats are not supported")
+			_ = writeFrame3(conn, err) // nolint:errcheck
+			return dmsg.Addr{}, dmsg.Addr{}, err
+		}
+
 		lAddr = f2.DstAddr
 		rAddr = f2.SrcAddr
 		if err = writeFrame3(conn, nil); err != nil {
 			return dmsg.Addr{}, dmsg.Addr{}, err
 		}
+		opts.Compression.negotiated(f2.Compression)
+		opts.Mux.negotiated(f2.Mux)
+		opts.Heartbeat.negotiated(f2.Heartbeat)
 
 		return lAddr, rAddr, nil
 	})
@@ -152,6 +278,13 @@ func ResponderHandshake(checkF2 CheckF2) Handshake {
 // Frame1 is the first frame of the handshake (Resp -> Init).
 type Frame1 struct {
 	Nonce [NonceSize]byte
+	// Compression lists compression algorithms supported by the responder.
+	// It is omitted by responders not supporting compression.
+	Compression []string `json:",omitempty"`
+	// Mux is true if the responder can multiplex transports over the connection.
+	Mux bool `json:",omitempty"`
+	// Heartbeat is true if the responder can exchange heartbeats over the connection.
+	Heartbeat bool `json:",omitempty"`
 }
 
 // Frame2 is the second frame of the handshake (Init -> Resp).
@@ -159,7 +292,16 @@ type Frame2 struct {
 	SrcAddr dmsg.Addr
 	DstAddr dmsg.Addr
 	Nonce   [NonceSize]byte
-	Sig     cipher.Sig
+	// Compression is the algorithm chosen by the initiator from the ones
+	// listed in Frame1, it is omitted if compression is not used.
+	Compression string `json:",omitempty"`
+	// Mux is true if the initiator requests multiplexing transports over
+	// the connection, which is done only if Frame1 offers it.
+	Mux bool `json:",omitempty"`
+	// Heartbeat is true if the initiator requests exchanging heartbeats
+	// over the connection, which is done only if Frame1 offers it.
+	Heartbeat bool `json:",omitempty"`
+	Sig       cipher.Sig
 }
 
 // Sign signs Frame2.
@@ -242,8 +384,8 @@ func readFrame0(r io.Reader) error {
 	return nil
 }
 
-func writeFrame1(w io.Writer, nonce [NonceSize]byte) error {
-	return json.NewEncoder(w).Encode(Frame1{Nonce: nonce})
+func writeFrame1(w io.Writer, f1 Frame1) error {
+	return json.NewEncoder(w).Encode(f1)
 }
 
 func readFrame1(r io.Reader) (Frame1, error) {
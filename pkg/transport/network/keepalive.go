@@ -0,0 +1,213 @@
+// Package network pkg/transport/network/keepalive.go
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+)
+
+const (
+	// DefaultKeepaliveInterval is the time between keepalive probes by default
+	DefaultKeepaliveInterval = 15 * time.Second
+	// DefaultKeepaliveProbes is the number of missed probes after which
+	// the peer is considered dead by default
+	DefaultKeepaliveProbes = 4
+)
+
+// KeepaliveConfig configures detection of dead peers of direct connections.
+// TCP based networks use TCP keepalives, SUDPH exchanges heartbeat frames
+// with the peers that support them
+type KeepaliveConfig struct {
+	// Interval is the time between probes, DefaultKeepaliveInterval if zero.
+	// Negative interval disables keepalives
+	Interval time.Duration
+	// Probes is the number of missed probes after which the connection
+	// is closed, DefaultKeepaliveProbes if zero
+	Probes int
+}
+
+// withDefaults returns the config with zero values set to defaults
+func (c KeepaliveConfig) withDefaults() KeepaliveConfig {
+	if c.Interval == 0 {
+		c.Interval = DefaultKeepaliveInterval
+	}
+	if c.Probes <= 0 {
+		c.Probes = DefaultKeepaliveProbes
+	}
+	return c
+}
+
+// enabled is true unless keepalives are disabled
+func (c KeepaliveConfig) enabled() bool {
+	return c.Interval > 0
+}
+
+// setTCPKeepalive configures keepalives of conn, if it is a TCP connection
+func setTCPKeepalive(conn net.Conn, conf KeepaliveConfig) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if !conf.enabled() {
+		return tcpConn.SetKeepAlive(false)
+	}
+	if err := tcpConn.SetKeepAlive(true); err != nil {
+		return err
+	}
+	if err := tcpConn.SetKeepAlivePeriod(conf.Interval); err != nil {
+		return err
+	}
+	return setKeepaliveProbes(tcpConn, conf.Probes)
+}
+
+// Heartbeat frames are exchanged over connections with no keepalives of their
+// own. Data written to the connection is split into frames, and each side sends
+// a ping frame every interval, answered by the other side with a pong frame.
+// Frame header is the type followed by the big endian length of the payload
+const (
+	heartbeatData byte = iota
+	heartbeatPing
+	heartbeatPong
+)
+
+const (
+	heartbeatHeaderSize = 3
+	heartbeatMaxPayload = 1<<16 - 1
+)
+
+// errHeartbeatFrame is returned when the peer sends an unknown frame
+var errHeartbeatFrame = errors.New("unknown heartbeat frame")
+
+// heartbeatConn exchanges heartbeat frames with the peer and closes the
+// connection once the peer misses the configured number of probes. Misses are
+// counted only while the connection is being read, as frames are only received then
+type heartbeatConn struct {
+	net.Conn
+	log  *logging.Logger
+	conf KeepaliveConfig
+
+	wMx sync.Mutex
+	// rMx guards the read state, so that concurrent reads get whole data
+	rMx sync.Mutex
+	// remaining is the number of bytes left of the data frame being read
+	remaining int
+	header    [heartbeatHeaderSize]byte
+
+	readers  atomic.Int32
+	received atomic.Bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newHeartbeatConn(conn net.Conn, conf KeepaliveConfig, log *logging.Logger) *heartbeatConn {
+	c := &heartbeatConn{Conn: conn, log: log, conf: conf, done: make(chan struct{})}
+	go c.probe()
+	return c
+}
+
+// probe sends pings and closes the connection once the peer stops responding
+func (c *heartbeatConn) probe() {
+	ticker := time.NewTicker(c.conf.Interval)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		if received := c.received.Swap(false); received || c.readers.Load() == 0 {
+			missed = 0
+		} else {
+			missed++
+		}
+		if missed >= c.conf.Probes {
+			c.log.Warnf("Peer at %v missed %d heartbeats, closing connection", c.RemoteAddr(), missed)
+			c.Close() //nolint: errcheck, gosec
+			return
+		}
+		if err := c.writeFrame(heartbeatPing, nil); err != nil {
+			c.log.WithError(err).Debugf("Failed to send heartbeat to %v", c.RemoteAddr())
+		}
+	}
+}
+
+func (c *heartbeatConn) writeFrame(typ byte, payload []byte) error {
+	frame := make([]byte, heartbeatHeaderSize+len(payload))
+	frame[0] = typ
+	binary.BigEndian.PutUint16(frame[1:], uint16(len(payload)))
+	copy(frame[heartbeatHeaderSize:], payload)
+	c.wMx.Lock()
+	defer c.wMx.Unlock()
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// Write implements net.Conn
+func (c *heartbeatConn) Write(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		end := n + heartbeatMaxPayload
+		if end > len(b) {
+			end = len(b)
+		}
+		if err := c.writeFrame(heartbeatData, b[n:end]); err != nil {
+			return n, err
+		}
+		n = end
+	}
+	return n, nil
+}
+
+// Read implements net.Conn. Heartbeat frames are handled and skipped
+func (c *heartbeatConn) Read(b []byte) (int, error) {
+	c.rMx.Lock()
+	defer c.rMx.Unlock()
+	c.readers.Add(1)
+	defer c.readers.Add(-1)
+	for c.remaining == 0 {
+		if _, err := io.ReadFull(c.Conn, c.header[:]); err != nil {
+			return 0, err
+		}
+		c.received.Store(true)
+		size := int(binary.BigEndian.Uint16(c.header[1:]))
+		switch c.header[0] {
+		case heartbeatData:
+			c.remaining = size
+		case heartbeatPing, heartbeatPong:
+			if _, err := io.CopyN(io.Discard, c.Conn, int64(size)); err != nil {
+				return 0, err
+			}
+			if c.header[0] == heartbeatPing {
+				if err := c.writeFrame(heartbeatPong, nil); err != nil {
+					return 0, err
+				}
+			}
+		default:
+			return 0, errHeartbeatFrame
+		}
+	}
+	if len(b) > c.remaining {
+		b = b[:c.remaining]
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.received.Store(true)
+	}
+	c.remaining -= n
+	return n, err
+}
+
+// Close implements net.Conn
+func (c *heartbeatConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,26 @@
+//go:build linux
+// +build linux
+
+package network
+
+import (
+	"net"
+	"syscall"
+)
+
+// setKeepaliveProbes sets the number of unanswered keepalive probes after
+// which the connection is dropped
+func setKeepaliveProbes(conn *net.TCPConn, probes int) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, probes)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
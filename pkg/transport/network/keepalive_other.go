@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package network
+
+import "net"
+
+// setKeepaliveProbes is a no-op, the number of keepalive probes is left
+// to the system defaults
+func setKeepaliveProbes(_ *net.TCPConn, _ int) error {
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package network pkg/transport/network/keepalive_test.go
+package network
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/pkg/noise"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+)
+
+// newTestTCPPair returns connected TCP conns, which unlike net.Pipe buffer writes
+func newTestTCPPair(t *testing.T) (net.Conn, net.Conn) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close() //nolint:errcheck
+	c1, err := net.Dial("tcp", lis.Addr().String())
+	require.NoError(t, err)
+	c2, err := lis.Accept()
+	require.NoError(t, err)
+	return c1, c2
+}
+
+func newTestHeartbeatPair(t *testing.T, conf KeepaliveConfig) (*heartbeatConn, *heartbeatConn) {
+	c1, c2 := newTestTCPPair(t)
+	log := logging.MustGetLogger("keepalive")
+	hb1, hb2 := newHeartbeatConn(c1, conf, log), newHeartbeatConn(c2, conf, log)
+	t.Cleanup(func() {
+		hb1.Close() //nolint:errcheck
+		hb2.Close() //nolint:errcheck
+	})
+	return hb1, hb2
+}
+
+func TestHeartbeatConn(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	conf := KeepaliveConfig{Interval: interval, Probes: 3}
+
+	t.Run("data", func(t *testing.T) {
+		hb1, hb2 := newTestHeartbeatPair(t, conf)
+		// both sides read, so that heartbeats are answered
+		go io.Copy(hb2, hb2) //nolint:errcheck
+
+		// payload is larger than a single frame
+		payload := bytes.Repeat([]byte("heartbeat"), heartbeatMaxPayload/4)
+		require.NoError(t, checkEcho(hb1, payload))
+		time.Sleep(10 * interval)
+		require.NoError(t, checkEcho(hb1, []byte("still alive")))
+	})
+
+	t.Run("dead peer", func(t *testing.T) {
+		c1, c2 := newTestTCPPair(t)
+		hb := newHeartbeatConn(c1, conf, logging.MustGetLogger("keepalive"))
+		defer hb.Close() //nolint:errcheck
+		// the peer reads heartbeats but never answers them
+		go io.Copy(io.Discard, c2) //nolint:errcheck
+		defer c2.Close()           //nolint:errcheck
+
+		readErr := make(chan error, 1)
+		go func() {
+			_, err := hb.Read(make([]byte, 1))
+			readErr <- err
+		}()
+		select {
+		case err := <-readErr:
+			require.Error(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("connection to the dead peer was not closed")
+		}
+	})
+
+	t.Run("concurrent reads", func(t *testing.T) {
+		hb1, hb2 := newTestHeartbeatPair(t, conf)
+		const frames, readers = 100, 4
+		go func() {
+			for i := 0; i < frames; i++ {
+				if _, err := hb2.Write(bytes.Repeat([]byte("x"), 100)); err != nil {
+					return
+				}
+			}
+		}()
+
+		// headers of frames are never returned as data
+		total := make(chan int, readers)
+		for i := 0; i < readers; i++ {
+			go func() {
+				n, buf := 0, make([]byte, 64)
+				for n < frames*100/readers {
+					m, err := hb1.Read(buf[:min(len(buf), frames*100/readers-n)])
+					if err != nil || !bytes.Equal(buf[:m], bytes.Repeat([]byte("x"), m)) {
+						break
+					}
+					n += m
+				}
+				total <- n
+			}()
+		}
+		for i := 0; i < readers; i++ {
+			require.Equal(t, frames*100/readers, <-total)
+		}
+	})
+
+	t.Run("not read", func(t *testing.T) {
+		hb1, hb2 := newTestHeartbeatPair(t, conf)
+		// the peer answers, but heartbeats are not received without reading
+		go io.Copy(hb2, hb2) //nolint:errcheck
+		time.Sleep(10 * interval)
+		require.NoError(t, checkEcho(hb1, []byte("not closed")))
+	})
+}
+
+func TestSUDPH_Heartbeat(t *testing.T) {
+	dialer, remote := newTestSUDPHPair(t, loopbackAddr)
+	serveEcho(t, remote, testTransportPort)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tp, err := DialFrom(ctx, dialer, freePort(t, "udp"), remote.PK(), testTransportPort)
+	require.NoError(t, err)
+	defer tp.Close() //nolint:errcheck
+	require.NoError(t, checkEcho(tp, []byte("heartbeats negotiated")))
+	rawConn := tp.(*transport).Conn.(*countingConn).Conn.(*noise.Conn).Conn
+	require.IsType(t, &heartbeatConn{}, rawConn)
+}
+
+func TestSetTCPKeepalive(t *testing.T) {
+	conn, remote := newTestTCPPair(t)
+	defer conn.Close()   //nolint:errcheck
+	defer remote.Close() //nolint:errcheck
+
+	require.NoError(t, setTCPKeepalive(conn, KeepaliveConfig{}.withDefaults()))
+	require.NoError(t, setTCPKeepalive(conn, KeepaliveConfig{Interval: -1}.withDefaults()))
+	// other conns are left as they are
+	c1, c2 := net.Pipe()
+	defer c1.Close() //nolint:errcheck
+	defer c2.Close() //nolint:errcheck
+	require.NoError(t, setTCPKeepalive(c1, KeepaliveConfig{}.withDefaults()))
+}
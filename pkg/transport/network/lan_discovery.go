@@ -0,0 +1,301 @@
+// Package network pkg/transport/network/lan_discovery.go
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+	"github.com/skycoin/skywire/pkg/transport/network/stcp"
+)
+
+const (
+	// DefaultLANDiscoveryGroup is the multicast group STCP addresses are announced to by default
+	DefaultLANDiscoveryGroup = "239.255.77.77:30180"
+	// lanAnnounceInterval is how often STCP address is announced
+	lanAnnounceInterval = 5 * time.Second
+	// lanEntryTTL is how long a discovered entry is kept after the last announcement
+	lanEntryTTL = 3 * lanAnnounceInterval
+	// lanAnnouncementMaxSize bounds size of a received announcement
+	lanAnnouncementMaxSize = 512
+)
+
+// LANDiscoveryConfig configures discovery of STCP visors on the local network.
+// Visors announce their STCP addresses by UDP multicast and add announced addresses
+// of other visors to their PK tables.
+type LANDiscoveryConfig struct {
+	// Interfaces are names of network interfaces to announce on and listen to
+	Interfaces []string `json:"interfaces"`
+	// Group is the multicast group address, DefaultLANDiscoveryGroup if empty
+	Group string `json:"group,omitempty"`
+}
+
+// LANEntry is an STCP address of a remote visor discovered on the local network
+type LANEntry struct {
+	Addr string `json:"addr"`
+	// Verified is true once the visor connected from the address and
+	// authenticated itself with STCP handshake
+	Verified bool      `json:"verified"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// lanAnnouncement is broadcast by visors, address of STCP listener is the source
+// address of the announcement with the announced port
+type lanAnnouncement struct {
+	PK   cipher.PubKey `json:"pk"`
+	Port int           `json:"port"`
+}
+
+// lanConn is a connection announcements are sent through and received from
+type lanConn struct {
+	conn  net.PacketConn
+	group net.Addr
+}
+
+// lanDiscovery announces STCP address of the visor on the local network and
+// feeds the addresses announced by other visors into PK table. Discovered
+// entries are used for dialing only, until verified.
+type lanDiscovery struct {
+	log      *logging.Logger
+	pk       cipher.PubKey
+	table    stcp.PKTable
+	conns    []lanConn
+	interval time.Duration
+	ttl      time.Duration
+
+	mx      sync.Mutex
+	entries map[cipher.PubKey]*LANEntry
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// listenLAN joins the multicast group on the configured interfaces
+func listenLAN(conf LANDiscoveryConfig) ([]lanConn, error) {
+	group := conf.Group
+	if group == "" {
+		group = DefaultLANDiscoveryGroup
+	}
+	groupAddr, err := net.ResolveUDPAddr("udp4", group)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LAN discovery group %q: %w", group, err)
+	}
+	if len(conf.Interfaces) == 0 {
+		return nil, errors.New("no LAN discovery interfaces configured")
+	}
+	var conns []lanConn
+	for _, name := range conf.Interfaces {
+		conn, err := listenLANInterface(name, groupAddr)
+		if err != nil {
+			for _, c := range conns {
+				c.conn.Close() //nolint:errcheck
+			}
+			return nil, err
+		}
+		conns = append(conns, lanConn{conn: conn, group: groupAddr})
+	}
+	return conns, nil
+}
+
+func listenLANInterface(name string, group *net.UDPAddr) (net.PacketConn, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("LAN discovery interface %q: %w", name, err)
+	}
+	conn, err := net.ListenMulticastUDP("udp4", ifi, group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join LAN discovery group on %q: %w", name, err)
+	}
+	// announcements are sent through the same interface, visors on the
+	// same host receive them as well
+	pc := ipv4.NewPacketConn(conn)
+	if err := pc.SetMulticastInterface(ifi); err != nil {
+		conn.Close() //nolint:errcheck
+		return nil, fmt.Errorf("failed to set LAN discovery interface %q: %w", name, err)
+	}
+	if err := pc.SetMulticastLoopback(true); err != nil {
+		conn.Close() //nolint:errcheck
+		return nil, fmt.Errorf("failed to set LAN discovery loopback on %q: %w", name, err)
+	}
+	return conn, nil
+}
+
+func newLANDiscovery(log *logging.Logger, pk cipher.PubKey, table stcp.PKTable, conns []lanConn) *lanDiscovery {
+	return &lanDiscovery{
+		log:      log,
+		pk:       pk,
+		table:    table,
+		conns:    conns,
+		interval: lanAnnounceInterval,
+		ttl:      lanEntryTTL,
+		entries:  make(map[cipher.PubKey]*LANEntry),
+		done:     make(chan struct{}),
+	}
+}
+
+// start announces STCP listener port and receives announcements of other visors
+func (d *lanDiscovery) start(port int) {
+	d.wg.Add(len(d.conns) + 1)
+	for _, c := range d.conns {
+		go d.receive(c.conn)
+	}
+	go d.announce(port)
+}
+
+func (d *lanDiscovery) announce(port int) {
+	defer d.wg.Done()
+	msg, err := json.Marshal(lanAnnouncement{PK: d.pk, Port: port})
+	if err != nil {
+		d.log.WithError(err).Error("Failed to encode LAN announcement")
+		return
+	}
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		for _, c := range d.conns {
+			if _, err := c.conn.WriteTo(msg, c.group); err != nil {
+				d.log.WithError(err).Debug("Failed to send LAN announcement")
+			}
+		}
+		select {
+		case <-ticker.C:
+			d.expire()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+func (d *lanDiscovery) receive(conn net.PacketConn) {
+	defer d.wg.Done()
+	buf := make([]byte, lanAnnouncementMaxSize)
+	for {
+		n, src, err := conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-d.done:
+			default:
+				d.log.WithError(err).Warn("Failed to receive LAN announcements")
+			}
+			return
+		}
+		var ann lanAnnouncement
+		if err := json.Unmarshal(buf[:n], &ann); err != nil {
+			d.log.WithError(err).Debugf("Invalid LAN announcement from %s", src)
+			continue
+		}
+		udpSrc, ok := src.(*net.UDPAddr)
+		if !ok || ann.PK == d.pk || ann.PK.Null() || ann.Port <= 0 || ann.Port > 65535 {
+			continue
+		}
+		d.discovered(ann.PK, net.JoinHostPort(udpSrc.IP.String(), strconv.Itoa(ann.Port)))
+	}
+}
+
+// discovered adds or refreshes the entry of the announced visor. Entries
+// configured in PK table are not replaced
+func (d *lanDiscovery) discovered(pk cipher.PubKey, addr string) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	entry, ok := d.entries[pk]
+	if !ok {
+		if _, configured := d.table.Addr(pk); configured {
+			return
+		}
+		d.log.Debugf("Discovered visor %s at %s on LAN", pk, addr)
+		entry = &LANEntry{}
+		d.entries[pk] = entry
+	}
+	if entry.Addr != addr {
+		// the visor has to verify the new address
+		entry.Addr = addr
+		entry.Verified = false
+		d.table.AddEntry(pk, addr)
+	}
+	entry.LastSeen = time.Now()
+}
+
+// expire removes entries of visors which stopped announcing
+func (d *lanDiscovery) expire() {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	for pk, entry := range d.entries {
+		if time.Since(entry.LastSeen) < d.ttl {
+			continue
+		}
+		d.log.Debugf("Visor %s at %s stopped announcing on LAN", pk, entry.Addr)
+		delete(d.entries, pk)
+		// the entry could have been replaced manually
+		if addr, ok := d.table.Addr(pk); ok && addr == entry.Addr {
+			d.table.RemoveEntry(pk)
+		}
+	}
+}
+
+// authenticated marks the entry of the visor as verified, if it was
+// discovered at the host it connected from
+func (d *lanDiscovery) authenticated(pk cipher.PubKey, remoteAddr net.Addr) {
+	remoteHost, _, err := net.SplitHostPort(remoteAddr.String())
+	if err != nil {
+		return
+	}
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	entry, ok := d.entries[pk]
+	if !ok || entry.Verified {
+		return
+	}
+	host, _, err := net.SplitHostPort(entry.Addr)
+	if err != nil || host != remoteHost {
+		return
+	}
+	d.log.Debugf("Verified LAN entry of visor %s at %s", pk, entry.Addr)
+	entry.Verified = true
+}
+
+// forget stops tracking the entry of the visor, leaving PK table as is
+func (d *lanDiscovery) forget(pk cipher.PubKey) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	delete(d.entries, pk)
+}
+
+// isUnverified is true for the discovered entries which are not verified yet
+func (d *lanDiscovery) isUnverified(pk cipher.PubKey) bool {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	entry, ok := d.entries[pk]
+	return ok && !entry.Verified
+}
+
+// lanEntries returns copies of all discovered entries
+func (d *lanDiscovery) lanEntries() map[cipher.PubKey]LANEntry {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	entries := make(map[cipher.PubKey]LANEntry, len(d.entries))
+	for pk, entry := range d.entries {
+		entries[pk] = *entry
+	}
+	return entries
+}
+
+func (d *lanDiscovery) close() {
+	d.closeOnce.Do(func() {
+		close(d.done)
+		for _, c := range d.conns {
+			if err := c.conn.Close(); err != nil {
+				d.log.WithError(err).Warn("Failed to close LAN discovery connection")
+			}
+		}
+	})
+	d.wg.Wait()
+}
@@ -0,0 +1,113 @@
+// Package network pkg/transport/network/lan_discovery_test.go
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire-utilities/pkg/logging"
+	"github.com/skycoin/skywire/pkg/transport/network/stcp"
+)
+
+// startTestLANDiscovery starts discovery of the client announcing to and
+// receiving from the given UDP connection
+func startTestLANDiscovery(t *testing.T, c STCPClient, conn net.PacketConn, target net.Addr) *lanDiscovery {
+	stcpC := c.(*stcpClient)
+	lan := newLANDiscovery(logging.MustGetLogger("lan_discovery"), c.PK(), stcpC.table, []lanConn{{conn: conn, group: target}})
+	lan.interval = 50 * time.Millisecond
+	lan.ttl = 200 * time.Millisecond
+
+	addr, err := c.LocalAddr()
+	require.NoError(t, err)
+	stcpC.startLAN(lan, addr.(*net.TCPAddr).Port)
+	return lan
+}
+
+func TestSTCPClient_LANDiscovery(t *testing.T) {
+	const port = 10
+
+	local := newTestSTCPClient(t, nil)
+	remote := newTestSTCPClient(t, nil)
+
+	localConn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	remoteConn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	startTestLANDiscovery(t, local, localConn, remoteConn.LocalAddr())
+	remoteLAN := startTestLANDiscovery(t, remote, remoteConn, localConn.LocalAddr())
+
+	localAddr, err := local.LocalAddr()
+	require.NoError(t, err)
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(local.LANEntries()) == 1 && len(remote.LANEntries()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	entry := local.LANEntries()[remote.PK()]
+	require.Equal(t, remoteAddr.String(), entry.Addr)
+	require.False(t, entry.Verified)
+	// unverified entries are used for dialing only
+	require.Empty(t, local.PKEntries())
+	require.Empty(t, remote.PKEntries())
+
+	lis, err := remote.Listen(port)
+	require.NoError(t, err)
+	acceptCh := make(chan Transport, 1)
+	go func() {
+		tp, err := lis.AcceptTransport()
+		if err == nil {
+			acceptCh <- tp
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tp, err := local.Dial(ctx, remote.PK(), port)
+	require.NoError(t, err)
+	select {
+	case accepted := <-acceptCh:
+		require.NoError(t, accepted.Close())
+	case <-ctx.Done():
+		t.Fatal("transport was not accepted")
+	}
+	require.NoError(t, tp.Close())
+
+	// the dialing visor authenticated itself from the announced host
+	require.True(t, remote.LANEntries()[local.PK()].Verified)
+	require.Equal(t, map[cipher.PubKey]string{local.PK(): localAddr.String()}, remote.PKEntries())
+	require.False(t, local.LANEntries()[remote.PK()].Verified)
+
+	// entries are removed once announcements stop
+	remoteLAN.close()
+	require.Eventually(t, func() bool {
+		return len(local.LANEntries()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = local.Dial(ctx, remote.PK(), port)
+	require.ErrorIs(t, err, ErrStcpEntryNotFound)
+}
+
+func TestLANDiscovery_ConfiguredEntry(t *testing.T) {
+	pk, _ := cipher.GenerateKeyPair()
+	table := stcp.NewTable(map[cipher.PubKey]string{pk: "10.0.0.1:7777"})
+	lan := newLANDiscovery(logging.MustGetLogger("lan_discovery"), cipher.PubKey{}, table, nil)
+
+	lan.discovered(pk, "192.168.1.2:7777")
+	require.Empty(t, lan.lanEntries())
+	addr, ok := table.Addr(pk)
+	require.True(t, ok)
+	require.Equal(t, "10.0.0.1:7777", addr)
+
+	// a different host can't verify the entry
+	other, _ := cipher.GenerateKeyPair()
+	lan.discovered(other, "192.168.1.3:7777")
+	lan.authenticated(other, &net.TCPAddr{IP: net.IPv4(192, 168, 1, 4), Port: 40000})
+	require.True(t, lan.isUnverified(other))
+	lan.authenticated(other, &net.TCPAddr{IP: net.IPv4(192, 168, 1, 3), Port: 40000})
+	require.False(t, lan.isUnverified(other))
+}
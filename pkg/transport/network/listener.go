@@ -2,9 +2,11 @@
 package network
 
 import (
-	"io"
+	"context"
 	"net"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/skycoin/dmsg/pkg/dmsg"
 
@@ -20,6 +22,12 @@ type Listener interface {
 	Port() uint16
 	Network() Type
 	AcceptTransport() (Transport, error)
+	// AcceptContext accepts a transport until ctx is done or the deadline
+	// passes. It returns ErrListenerClosed once the listener is closed
+	AcceptContext(ctx context.Context) (Transport, error)
+	// SetDeadline sets the deadline of pending and future accepts, after which
+	// they fail with os.ErrDeadlineExceeded. Zero value means no deadline
+	SetDeadline(t time.Time) error
 }
 
 type listener struct {
@@ -30,6 +38,7 @@ type listener struct {
 	accept   chan *transport
 	done     chan struct{}
 	network  Type
+	deadline acceptDeadline
 }
 
 // NewListener returns a new Listener.
@@ -54,12 +63,28 @@ func (l *listener) Accept() (net.Conn, error) {
 
 // AcceptTransport accepts a skywire transport and returns network.Transport
 func (l *listener) AcceptTransport() (Transport, error) {
-	c, ok := <-l.accept
-	if !ok {
-		return nil, io.ErrClosedPipe
+	return l.AcceptContext(context.Background())
+}
+
+// AcceptContext implements Listener
+func (l *listener) AcceptContext(ctx context.Context) (Transport, error) {
+	stop, cause, release := l.deadline.watch(ctx)
+	defer release()
+	select {
+	case c, ok := <-l.accept:
+		if !ok {
+			return nil, ErrListenerClosed
+		}
+		return c, nil
+	case <-stop:
+		return nil, cause()
 	}
+}
 
-	return c, nil
+// SetDeadline implements Listener
+func (l *listener) SetDeadline(t time.Time) error {
+	l.deadline.set(t)
+	return nil
 }
 
 // Close implements net.Listener
@@ -78,6 +103,15 @@ func (l *listener) Close() error {
 	return nil
 }
 
+func (l *listener) isClosed() bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // Addr implements net.Listener
 func (l *listener) Addr() net.Addr {
 	return l.lAddr
@@ -102,7 +136,7 @@ func (l *listener) Network() Type {
 func (l *listener) introduce(transport *transport) error {
 	select {
 	case <-l.done:
-		return io.ErrClosedPipe
+		return ErrListenerClosed
 	default:
 		l.mx.Lock()
 		defer l.mx.Unlock()
@@ -110,7 +144,77 @@ func (l *listener) introduce(transport *transport) error {
 		case l.accept <- transport:
 			return nil
 		case <-l.done:
-			return io.ErrClosedPipe
+			return ErrListenerClosed
+		}
+	}
+}
+
+// acceptDeadline is the deadline of listener accepts, which may be changed
+// while they are pending
+type acceptDeadline struct {
+	mx      sync.Mutex
+	t       time.Time
+	changed chan struct{} // closed when t changes
+}
+
+func (d *acceptDeadline) set(t time.Time) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.t = t
+	if d.changed != nil {
+		close(d.changed)
+	}
+	d.changed = make(chan struct{})
+}
+
+func (d *acceptDeadline) get() (time.Time, <-chan struct{}) {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if d.changed == nil {
+		d.changed = make(chan struct{})
+	}
+	return d.t, d.changed
+}
+
+// watch returns a channel closed once ctx is done or the deadline passes, and
+// the error the accept should fail with then. release has to be called once the accept is done
+func (d *acceptDeadline) watch(ctx context.Context) (stop <-chan struct{}, cause func() error, release func()) {
+	stopCh, released := make(chan struct{}), make(chan struct{})
+	var err error
+	go func() {
+		for {
+			retry, waitErr := d.wait(ctx, released)
+			if retry {
+				continue
+			}
+			if waitErr != nil {
+				err = waitErr
+				close(stopCh)
+			}
+			return
 		}
+	}()
+	var once sync.Once
+	return stopCh, func() error { return err }, func() { once.Do(func() { close(released) }) }
+}
+
+// wait waits until ctx is done, the deadline passes or changes, retry is true in the latter case
+func (d *acceptDeadline) wait(ctx context.Context, released <-chan struct{}) (retry bool, err error) {
+	t, changed := d.get()
+	var timeout <-chan time.Time
+	if !t.IsZero() {
+		timer := time.NewTimer(time.Until(t))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-timeout:
+		return false, os.ErrDeadlineExceeded
+	case <-changed:
+		return true, nil
+	case <-released:
+		return false, nil
 	}
 }
@@ -0,0 +1,136 @@
+// Package network pkg/transport/network/listener_test.go
+package network
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/pkg/dmsg"
+	"github.com/skycoin/dmsg/pkg/dmsgtest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+// listenerPair creates a listener and a function dialing it
+type listenerPair func(t *testing.T) (Listener, func() error)
+
+func newTestGenericListener(t *testing.T) (Listener, func() error) {
+	dialer, remote := newTestMuxSTCPPair(t, false, false)
+	lis, err := remote.Listen(testTransportPort)
+	require.NoError(t, err)
+	dial := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		tp, err := dialer.Dial(ctx, remote.PK(), testTransportPort)
+		if err == nil {
+			t.Cleanup(func() { tp.Close() }) //nolint:errcheck
+		}
+		return err
+	}
+	return lis, dial
+}
+
+func newTestDmsgListener(t *testing.T) (Listener, func() error) {
+	conf := dmsg.Config{MinSessions: 1}
+	env := dmsgtest.NewEnv(t, 10*time.Second)
+	require.NoError(t, env.Startup(0, 1, 0, &conf))
+	t.Cleanup(env.Shutdown)
+
+	newClient := func() Client {
+		dmsgC, err := env.NewClient(&conf)
+		require.NoError(t, err)
+		c, err := (&ClientFactory{DmsgC: dmsgC}).MakeClient(DMSG, 0)
+		require.NoError(t, err)
+		return c
+	}
+	dialer, remote := newClient(), newClient()
+	lis, err := remote.Listen(testTransportPort)
+	require.NoError(t, err)
+	dial := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		tp, err := dialer.Dial(ctx, remote.PK(), testTransportPort)
+		if err == nil {
+			t.Cleanup(func() { tp.Close() }) //nolint:errcheck
+		}
+		return err
+	}
+	return lis, dial
+}
+
+func TestListener_AcceptContext(t *testing.T) {
+	pairs := map[Type]listenerPair{
+		STCP: newTestGenericListener,
+		DMSG: newTestDmsgListener,
+	}
+	for netType, newPair := range pairs {
+		t.Run(string(netType), func(t *testing.T) {
+			lis, dial := newPair(t)
+
+			// canceled accept does not affect the listener
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			_, err := lis.AcceptContext(ctx)
+			cancel()
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+
+			require.NoError(t, dial())
+			ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+			tp, err := lis.AcceptContext(ctx)
+			cancel()
+			require.NoError(t, err)
+			require.NoError(t, tp.Close())
+
+			// deadline in the past fails accepts immediately
+			require.NoError(t, lis.SetDeadline(time.Now().Add(-time.Second)))
+			_, err = lis.AcceptTransport()
+			require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+			// deadline set while accept is pending applies to it
+			require.NoError(t, lis.SetDeadline(time.Time{}))
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := lis.AcceptTransport()
+				errCh <- err
+			}()
+			time.Sleep(50 * time.Millisecond)
+			require.NoError(t, lis.SetDeadline(time.Now().Add(50*time.Millisecond)))
+			select {
+			case err := <-errCh:
+				require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+			case <-time.After(5 * time.Second):
+				t.Fatal("accept did not time out")
+			}
+
+			// close unblocks pending accepts with a typed error
+			require.NoError(t, lis.SetDeadline(time.Time{}))
+			go func() {
+				_, err := lis.AcceptContext(context.Background())
+				errCh <- err
+			}()
+			time.Sleep(50 * time.Millisecond)
+			require.NoError(t, lis.Close())
+			select {
+			case err := <-errCh:
+				require.ErrorIs(t, err, ErrListenerClosed)
+				require.ErrorIs(t, err, io.ErrClosedPipe)
+			case <-time.After(5 * time.Second):
+				t.Fatal("accept was not unblocked by close")
+			}
+			_, err = lis.AcceptTransport()
+			require.ErrorIs(t, err, ErrListenerClosed)
+		})
+	}
+}
+
+func TestListener_SetDeadline(t *testing.T) {
+	// deadline may be set before the first accept
+	lis := NewListener(dmsg.Addr{PK: cipher.PubKey{}, Port: testTransportPort}, func() {}, STCP)
+	require.NoError(t, lis.SetDeadline(time.Now().Add(10*time.Millisecond)))
+	_, err := lis.AcceptTransport()
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	require.NoError(t, lis.Close())
+}
@@ -0,0 +1,277 @@
+// Package network pkg/transport/network/metrics.go
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// DialErrorClass is a class of errors that dials fail with
+type DialErrorClass string
+
+const (
+	// DialErrTimeout is a class of dials that ran out of time
+	DialErrTimeout DialErrorClass = "timeout"
+	// DialErrCanceled is a class of dials cancelled by the caller
+	DialErrCanceled DialErrorClass = "canceled"
+	// DialErrRefused is a class of dials refused by the remote host
+	DialErrRefused DialErrorClass = "refused"
+	// DialErrNotFound is a class of dials to visors whose address could not be resolved
+	DialErrNotFound DialErrorClass = "not_found"
+	// DialErrOther is a class of all the other dial failures
+	DialErrOther DialErrorClass = "other"
+)
+
+// dialErrorClasses lists all the classes, index in it is used to count failures
+var dialErrorClasses = [...]DialErrorClass{DialErrTimeout, DialErrCanceled, DialErrRefused, DialErrNotFound, DialErrOther}
+
+// classifyDialErr returns index of the class of err in dialErrorClasses
+func classifyDialErr(err error) int {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return 0
+	case errors.Is(err, context.Canceled):
+		return 1
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return 2
+	case errors.Is(err, ErrPKNotResolved), errors.Is(err, ErrStcpEntryNotFound):
+		return 3
+	default:
+		return 4
+	}
+}
+
+// NetworkStats is a snapshot of metrics of a single network
+type NetworkStats struct {
+	DialAttempts  uint64                    `json:"dial_attempts"`
+	DialSuccesses uint64                    `json:"dial_successes"`
+	DialFailures  map[DialErrorClass]uint64 `json:"dial_failures"`
+	Listeners     int64                     `json:"listeners"`
+	Accepted      uint64                    `json:"accepted"`
+	BytesIn       uint64                    `json:"bytes_in"`
+	BytesOut      uint64                    `json:"bytes_out"`
+}
+
+// Metrics counts dials, listeners, accepted transports and transferred bytes
+// per network. It is set in ClientFactory to collect metrics of created clients
+type Metrics struct {
+	mx   sync.Mutex
+	nets map[Type]*netMetrics
+}
+
+// NewMetrics creates Metrics
+func NewMetrics() *Metrics {
+	return &Metrics{nets: make(map[Type]*netMetrics)}
+}
+
+// network returns counters of the network, nil Metrics return nil counters
+func (m *Metrics) network(netType Type) *netMetrics {
+	if m == nil {
+		return nil
+	}
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	nm, ok := m.nets[netType]
+	if !ok {
+		nm = &netMetrics{}
+		m.nets[netType] = nm
+	}
+	return nm
+}
+
+// Snapshot returns current metrics of all the networks
+func (m *Metrics) Snapshot() map[Type]NetworkStats {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	snapshot := make(map[Type]NetworkStats, len(m.nets))
+	for netType, nm := range m.nets {
+		snapshot[netType] = nm.stats()
+	}
+	return snapshot
+}
+
+// VictoriaMetricsSet returns a set of metrics of all registered networks in
+// prometheus format. It may be registered with metrics.RegisterSet
+func (m *Metrics) VictoriaMetricsSet() *metrics.Set {
+	set := metrics.NewSet()
+	for _, netType := range RegisteredNetworks() {
+		nm := m.network(netType)
+		gauge := func(name string, value func() float64) {
+			set.NewGauge(fmt.Sprintf("skywire_network_%s{network=%q}", name, netType), value)
+		}
+		gauge("dial_attempts_total", func() float64 { return float64(nm.dialAttempts.Load()) })
+		gauge("dial_successes_total", func() float64 { return float64(nm.dialSuccesses.Load()) })
+		for i, class := range dialErrorClasses {
+			counter := &nm.dialFailures[i]
+			set.NewGauge(fmt.Sprintf("skywire_network_dial_failures_total{network=%q,class=%q}", netType, class),
+				func() float64 { return float64(counter.Load()) })
+		}
+		gauge("listeners", func() float64 { return float64(nm.listeners.Load()) })
+		gauge("accepted_total", func() float64 { return float64(nm.accepted.Load()) })
+		gauge("bytes_in_total", func() float64 { return float64(nm.bytesIn.Load()) })
+		gauge("bytes_out_total", func() float64 { return float64(nm.bytesOut.Load()) })
+	}
+	return set
+}
+
+// netMetrics holds counters of a single network. All methods are no-op
+// for nil netMetrics, so that clients may work without metrics
+type netMetrics struct {
+	dialAttempts  atomic.Uint64
+	dialSuccesses atomic.Uint64
+	dialFailures  [len(dialErrorClasses)]atomic.Uint64
+	listeners     atomic.Int64
+	accepted      atomic.Uint64
+	bytesIn       atomic.Uint64
+	bytesOut      atomic.Uint64
+}
+
+func (nm *netMetrics) stats() NetworkStats {
+	s := NetworkStats{
+		DialAttempts:  nm.dialAttempts.Load(),
+		DialSuccesses: nm.dialSuccesses.Load(),
+		DialFailures:  make(map[DialErrorClass]uint64, len(dialErrorClasses)),
+		Listeners:     nm.listeners.Load(),
+		Accepted:      nm.accepted.Load(),
+		BytesIn:       nm.bytesIn.Load(),
+		BytesOut:      nm.bytesOut.Load(),
+	}
+	for i, class := range dialErrorClasses {
+		s.DialFailures[class] = nm.dialFailures[i].Load()
+	}
+	return s
+}
+
+// record counts dial and accept events
+func (nm *netMetrics) record(ev ConnEvent) {
+	if nm == nil {
+		return
+	}
+	switch ev.Type {
+	case ConnEventDialStart:
+		nm.dialAttempts.Add(1)
+	case ConnEventDialSuccess:
+		nm.dialSuccesses.Add(1)
+	case ConnEventDialFail:
+		nm.dialFailures[classifyDialErr(ev.Err)].Add(1)
+	case ConnEventAccept:
+		nm.accepted.Add(1)
+	}
+}
+
+// listenerOpened counts a new listener and returns a function to call once it is closed
+func (nm *netMetrics) listenerOpened() func() {
+	if nm == nil {
+		return func() {}
+	}
+	nm.listeners.Add(1)
+	return func() { nm.listeners.Add(-1) }
+}
+
+// connCounters counts traffic of a single connection
+type connCounters struct {
+	created      time.Time
+	bytesIn      atomic.Uint64
+	bytesOut     atomic.Uint64
+	lastActivity atomic.Int64 // unix nanoseconds
+}
+
+func newConnCounters() *connCounters {
+	c := &connCounters{created: time.Now()}
+	c.lastActivity.Store(c.created.UnixNano())
+	return c
+}
+
+// stats returns current traffic of the connection, nil counters return zero stats
+func (c *connCounters) stats() ConnStats {
+	if c == nil {
+		return ConnStats{}
+	}
+	return ConnStats{
+		BytesIn:      c.bytesIn.Load(),
+		BytesOut:     c.bytesOut.Load(),
+		CreatedAt:    c.created,
+		Age:          time.Since(c.created),
+		LastActivity: time.Unix(0, c.lastActivity.Load()),
+	}
+}
+
+// countConn wraps conn to count bytes transferred over it by the counters of the
+// connection, and by the network metrics if nm is not nil
+func countConn(conn net.Conn, nm *netMetrics, counters *connCounters) net.Conn {
+	return &countingConn{Conn: conn, nm: nm, counters: counters}
+}
+
+// countingConn counts bytes read from and written to the wrapped conn.
+// Deadlines and io.ReaderFrom, io.WriterTo fast paths of the wrapped conn are preserved
+type countingConn struct {
+	net.Conn
+	nm       *netMetrics
+	counters *connCounters
+}
+
+func (c *countingConn) read(n int64) {
+	if n <= 0 {
+		return
+	}
+	c.counters.bytesIn.Add(uint64(n))
+	c.counters.lastActivity.Store(time.Now().UnixNano())
+	if c.nm != nil {
+		c.nm.bytesIn.Add(uint64(n))
+	}
+}
+
+func (c *countingConn) wrote(n int64) {
+	if n <= 0 {
+		return
+	}
+	c.counters.bytesOut.Add(uint64(n))
+	c.counters.lastActivity.Store(time.Now().UnixNano())
+	if c.nm != nil {
+		c.nm.bytesOut.Add(uint64(n))
+	}
+}
+
+// Read implements net.Conn
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read(int64(n))
+	return n, err
+}
+
+// Write implements net.Conn
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.wrote(int64(n))
+	return n, err
+}
+
+// ReadFrom implements io.ReaderFrom
+func (c *countingConn) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := c.Conn.(io.ReaderFrom); ok {
+		n, err := rf.ReadFrom(r)
+		c.wrote(n)
+		return n, err
+	}
+	return io.Copy(struct{ io.Writer }{c}, r)
+}
+
+// WriteTo implements io.WriterTo
+func (c *countingConn) WriteTo(w io.Writer) (int64, error) {
+	if wt, ok := c.Conn.(io.WriterTo); ok {
+		n, err := wt.WriteTo(w)
+		c.read(n)
+		return n, err
+	}
+	return io.Copy(w, struct{ io.Reader }{c})
+}
@@ -0,0 +1,191 @@
+// Package network pkg/transport/network/metrics_test.go
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire/pkg/app/appevent"
+)
+
+func TestClassifyDialErr(t *testing.T) {
+	pk, _ := cipher.GenerateKeyPair()
+	tests := []struct {
+		err  error
+		want DialErrorClass
+	}{
+		{err: &DialTimeoutError{Network: STCP, RemotePK: pk, Err: context.DeadlineExceeded}, want: DialErrTimeout},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}, want: DialErrTimeout},
+		{err: context.Canceled, want: DialErrCanceled},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: DialErrRefused},
+		{err: fmt.Errorf("%w: %s", ErrStcpEntryNotFound, pk), want: DialErrNotFound},
+		{err: errors.New("resolve PK: not found"), want: DialErrOther},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			require.Equal(t, tc.want, dialErrorClasses[classifyDialErr(tc.err)])
+		})
+	}
+}
+
+func TestMetrics_STCP(t *testing.T) {
+	const port = 10
+	payload := []byte("hello")
+
+	metrics := NewMetrics()
+	newClient := func() STCPClient {
+		pk, sk := cipher.GenerateKeyPair()
+		f := &ClientFactory{
+			PK:         pk,
+			SK:         sk,
+			ListenAddr: "127.0.0.1:0",
+			EB:         appevent.NewBroadcaster(nil, time.Second),
+			Metrics:    metrics,
+		}
+		c, err := f.MakeClient(STCP, 0)
+		require.NoError(t, err)
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Close()) })
+		return c.(STCPClient)
+	}
+	dialer, remote := newClient(), newClient()
+
+	lis, err := remote.Listen(port)
+	require.NoError(t, err)
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = dialer.Dial(ctx, remote.PK(), port)
+	require.ErrorIs(t, err, ErrStcpEntryNotFound)
+
+	dialer.AddPKEntry(remote.PK(), remoteAddr.String())
+	acceptCh := make(chan Transport, 1)
+	go func() {
+		tp, err := lis.AcceptTransport()
+		if err == nil {
+			acceptCh <- tp
+		}
+	}()
+
+	tp, err := dialer.Dial(ctx, remote.PK(), port)
+	require.NoError(t, err)
+	var accepted Transport
+	select {
+	case accepted = <-acceptCh:
+	case <-ctx.Done():
+		t.Fatal("transport was not accepted")
+	}
+
+	_, err = tp.Write(payload)
+	require.NoError(t, err)
+	_, err = io.ReadFull(accepted, make([]byte, len(payload)))
+	require.NoError(t, err)
+	require.EqualValues(t, len(payload), tp.Stats().BytesOut)
+	require.EqualValues(t, len(payload), accepted.Stats().BytesIn)
+
+	stats := metrics.Snapshot()[STCP]
+	require.EqualValues(t, 2, stats.DialAttempts)
+	require.EqualValues(t, 1, stats.DialSuccesses)
+	require.EqualValues(t, 1, stats.DialFailures[DialErrNotFound])
+	require.EqualValues(t, 1, stats.Listeners)
+	require.EqualValues(t, 1, stats.Accepted)
+	require.EqualValues(t, len(payload), stats.BytesOut)
+	require.EqualValues(t, len(payload), stats.BytesIn)
+
+	var buf bytes.Buffer
+	metrics.VictoriaMetricsSet().WritePrometheus(&buf)
+	require.Contains(t, buf.String(), `skywire_network_dial_failures_total{network="stcp",class="not_found"} 1`)
+	require.Contains(t, buf.String(), fmt.Sprintf(`skywire_network_bytes_out_total{network="stcp"} %d`, len(payload)))
+
+	require.NoError(t, tp.Close())
+	require.NoError(t, accepted.Close())
+	require.NoError(t, lis.Close())
+	require.NoError(t, lis.Close())
+	require.Zero(t, metrics.Snapshot()[STCP].Listeners)
+}
+
+// discardConn is a conn discarding all the writes.
+type discardConn struct {
+	net.Conn
+}
+
+func (discardConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func BenchmarkCountingConn(b *testing.B) {
+	buf := make([]byte, 32*1024)
+	conns := map[string]net.Conn{
+		"raw":      discardConn{},
+		"counting": countConn(discardConn{}, NewMetrics().network(STCP), newConnCounters()),
+	}
+	for name, conn := range conns {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(buf)))
+			for i := 0; i < b.N; i++ {
+				if _, err := conn.Write(buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// readerFromConn is a conn with io.ReaderFrom fast path, discarding all the writes.
+type readerFromConn struct {
+	discardConn
+	readFrom *int
+}
+
+func (c readerFromConn) ReadFrom(r io.Reader) (int64, error) {
+	*c.readFrom++
+	return io.Copy(io.Discard, r)
+}
+
+func TestCountingConn(t *testing.T) {
+	var readFrom int
+	nm := NewMetrics().network(STCP)
+	counters := newConnCounters()
+	conn := countConn(readerFromConn{readFrom: &readFrom}, nm, counters)
+
+	before := counters.stats().LastActivity
+	time.Sleep(time.Millisecond)
+	_, err := conn.Write(make([]byte, 10))
+	require.NoError(t, err)
+	n, err := io.Copy(conn, io.LimitReader(bytes.NewReader(make([]byte, 100)), 100))
+	require.NoError(t, err)
+	require.EqualValues(t, 100, n)
+
+	// io.Copy uses the fast path of the wrapped conn
+	require.Equal(t, 1, readFrom)
+	stats := counters.stats()
+	require.EqualValues(t, 110, stats.BytesOut)
+	require.Zero(t, stats.BytesIn)
+	require.True(t, stats.LastActivity.After(before))
+	require.Positive(t, stats.Age)
+	require.EqualValues(t, 110, nm.stats().BytesOut)
+
+	// deadlines are set on the wrapped conn
+	client, server := net.Pipe()
+	defer server.Close() //nolint:errcheck
+	conn = countConn(client, nil, newConnCounters())
+	defer conn.Close() //nolint:errcheck
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(-time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,332 @@
+// Package network pkg/transport/network/mux.go
+package network
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"github.com/skycoin/dmsg/pkg/dmsg"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire/pkg/transport/network/handshake"
+)
+
+// Multiplexing lets a client carry all transports to a remote visor over a single
+// connection. The connection is handshaked, encrypted and compressed once and
+// then turned into a yamux session, every transport is a stream of the session
+// with its own handshake, which only exchanges skywire addresses.
+// Either side may open streams, so the session is used for dials in both directions.
+// Sessions count the transports they carry and, if the client has an idle
+// timeout, are closed once the last transport has been closed for that long
+
+// errSessionPK is returned when a stream claims a different visor than its session
+var errSessionPK = errors.New("source PK does not match the session")
+
+// muxConfig returns configuration of sessions multiplexing transports
+func muxConfig() *yamux.Config {
+	cfg := yamux.DefaultConfig()
+	cfg.LogOutput = io.Discard
+	return cfg
+}
+
+// muxStream is a session stream used as a raw transport connection
+type muxStream struct {
+	*yamux.Stream
+	session *transport
+}
+
+// Close implements net.Conn. Closing yamux stream only closes its write side,
+// pending reads are unblocked like closing a TCP conn does
+func (s *muxStream) Close() error {
+	err := s.Stream.Close()
+	s.Stream.SetReadDeadline(time.Now()) //nolint: errcheck, gosec
+	return err
+}
+
+// LocalAddr implements net.Conn
+func (s *muxStream) LocalAddr() net.Addr {
+	return s.session.LocalRawAddr()
+}
+
+// RemoteAddr implements net.Conn
+func (s *muxStream) RemoteAddr() net.Addr {
+	return s.session.RemoteRawAddr()
+}
+
+// sessionRef counts transports carried by a session
+type sessionRef struct {
+	refs int
+	// idle closes the session once it stays without transports
+	idle *time.Timer
+	// closing is set when the idle session is about to be closed
+	closing bool
+}
+
+// startSession turns handshaked tp into a session multiplexing transports
+// to the remote visor and starts accepting streams of the session
+func (c *genericClient) startSession(tp *transport, initiator bool) (*yamux.Session, error) {
+	// tp is not used as a transport on its own
+	if tp.freePort != nil {
+		tp.freePort()
+		tp.freePort = nil
+	}
+	tp.onClose = nil
+
+	newSession := yamux.Server
+	if initiator {
+		newSession = yamux.Client
+	}
+	session, err := newSession(tp, muxConfig())
+	if err != nil {
+		tp.Close() //nolint: errcheck, gosec
+		return nil, err
+	}
+
+	c.sessionsMu.Lock()
+	if c.isClosed() {
+		c.sessionsMu.Unlock()
+		session.Close() //nolint: errcheck, gosec
+		return nil, io.ErrClosedPipe
+	}
+	c.sessions[session] = tp
+	ref := &sessionRef{}
+	c.sessionRefs[session] = ref
+	c.idleSession(session, ref)
+	c.sessionsMu.Unlock()
+	c.log.Debugf("Multiplexing transports to %v over %v", tp.rAddr.PK, tp.RemoteRawAddr())
+
+	go c.serveSession(session, tp)
+	return session, nil
+}
+
+// serveSession accepts streams opened by the remote visor until the session is closed
+func (c *genericClient) serveSession(session *yamux.Session, tp *transport) {
+	defer func() {
+		c.sessionsMu.Lock()
+		delete(c.sessions, session)
+		if ref, ok := c.sessionRefs[session]; ok && ref.idle != nil {
+			ref.idle.Stop()
+		}
+		delete(c.sessionRefs, session)
+		c.sessionsMu.Unlock()
+		session.Close() //nolint: errcheck, gosec
+	}()
+	for {
+		stream, err := session.AcceptStream()
+		if err != nil {
+			c.log.WithError(err).Debugf("Stopped serving session with %v", tp.rAddr.PK)
+			return
+		}
+		// handshakes are done concurrently, so that slow streams do not block others
+		go c.acceptStream(session, &muxStream{Stream: stream, session: tp})
+	}
+}
+
+// acquireSession counts a new transport of the session. It returns a function
+// to call once the transport is closed, ok is false if the session is closing
+func (c *genericClient) acquireSession(session *yamux.Session) (release func(), ok bool) {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	ref, ok := c.sessionRefs[session]
+	if !ok || ref.closing {
+		return nil, false
+	}
+	ref.refs++
+	if ref.idle != nil {
+		ref.idle.Stop()
+		ref.idle = nil
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.sessionsMu.Lock()
+			defer c.sessionsMu.Unlock()
+			ref.refs--
+			if ref.refs == 0 {
+				c.idleSession(session, ref)
+			}
+		})
+	}, true
+}
+
+// idleSession schedules closing of the session without transports.
+// It has to be called with sessionsMu held
+func (c *genericClient) idleSession(session *yamux.Session, ref *sessionRef) {
+	if c.muxIdleTimeout <= 0 {
+		return
+	}
+	ref.idle = time.AfterFunc(c.muxIdleTimeout, func() {
+		c.sessionsMu.Lock()
+		idle := ref.refs == 0 && c.sessionRefs[session] == ref
+		if idle {
+			ref.closing = true
+		}
+		tp := c.sessions[session]
+		c.sessionsMu.Unlock()
+		if !idle {
+			return
+		}
+		c.log.Debugf("Closing idle session with %v", tp.rAddr.PK)
+		session.Close() //nolint: errcheck, gosec
+	})
+}
+
+// acceptStream performs handshake over stream opened by the remote visor
+// and delivers it to the appropriate listener
+func (c *genericClient) acceptStream(session *yamux.Session, stream *muxStream) {
+	rPK := stream.session.rAddr.PK
+	release, ok := c.acquireSession(session)
+	if !ok {
+		stream.Close() //nolint: errcheck, gosec
+		return
+	}
+	checkF2 := func(f2 handshake.Frame2) error {
+		if f2.SrcAddr.PK != rPK {
+			return errSessionPK
+		}
+		return c.checkListener(f2.DstAddr.Port)
+	}
+	tp, err := doHandshake(stream, handshake.ResponderHandshake(checkF2), c.netType, c.log)
+	if err != nil {
+		c.log.WithError(err).Warnf("Failed to accept stream from %v", rPK)
+		release()
+		return
+	}
+	tp.compression = stream.session.compression
+	tp.onClose = releaseFunc(c.events().closeFunc(rPK), release)
+	// bytes are counted for the network by the session
+	tp.count(nil)
+	lis, err := c.getListener(tp.lAddr.Port)
+	if err != nil {
+		tp.Close() //nolint: errcheck, gosec
+		return
+	}
+	c.events().accepted(rPK)
+	if err := lis.introduce(tp); err != nil {
+		c.log.WithError(err).Warnf("Failed to introduce stream from %v", rPK)
+		tp.Close() //nolint: errcheck, gosec
+	}
+}
+
+// session returns an open session with the remote visor, if there is one
+func (c *genericClient) session(rPK cipher.PubKey) (*yamux.Session, *transport) {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	for session, tp := range c.sessions {
+		if tp.rAddr.PK == rPK && !session.IsClosed() {
+			return session, tp
+		}
+	}
+	return nil, nil
+}
+
+// sessionDial makes concurrent dials to the remote visor wait for the one that
+// may create a session. It returns a channel closed once the dial in progress is
+// done, or a function to call once the dial is done if there is none in progress
+func (c *genericClient) sessionDial(rPK cipher.PubKey) (wait <-chan struct{}, done func()) {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	if wait, ok := c.sessionDials[rPK]; ok {
+		return wait, nil
+	}
+	dialDone := make(chan struct{})
+	c.sessionDials[rPK] = dialDone
+	return nil, func() {
+		c.sessionsMu.Lock()
+		delete(c.sessionDials, rPK)
+		c.sessionsMu.Unlock()
+		close(dialDone)
+	}
+}
+
+// dialSession dials the remote visor over an open session with it.
+// ok is false if multiplexing is disabled or there is no usable session,
+// in which case a new connection should be dialed
+func (c *genericClient) dialSession(ctx context.Context, rPK cipher.PubKey, rPort uint16) (tp *transport, ok bool, err error) {
+	if !c.mux {
+		return nil, false, nil
+	}
+	session, sessionTp := c.session(rPK)
+	if session == nil {
+		return nil, false, nil
+	}
+	tp, err = c.openStream(ctx, session, sessionTp, rPort)
+	if errors.Is(err, yamux.ErrSessionShutdown) || errors.Is(err, yamux.ErrStreamsExhausted) {
+		c.log.WithError(err).Debugf("Session with %v is unusable, dialing a new connection", rPK)
+		return nil, false, nil
+	}
+	return tp, true, err
+}
+
+// openStream opens a new stream of the session and performs transport handshake over it
+func (c *genericClient) openStream(ctx context.Context, session *yamux.Session, sessionTp *transport, rPort uint16) (*transport, error) {
+	// the session is acquired first, so that it is not closed as idle
+	// while the stream is being opened
+	release, ok := c.acquireSession(session)
+	if !ok {
+		return nil, yamux.ErrSessionShutdown
+	}
+	stream, err := session.OpenStream()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	conn := &muxStream{Stream: stream, session: sessionTp}
+	lPort, freePort, err := c.porter.ReserveEphemeral(ctx)
+	if err != nil {
+		conn.Close() //nolint: errcheck, gosec
+		release()
+		return nil, err
+	}
+	lAddr, rAddr := dmsg.Addr{PK: c.lPK, Port: lPort}, dmsg.Addr{PK: sessionTp.rAddr.PK, Port: rPort}
+	hs := handshake.InitiatorHandshake(c.lSK, lAddr, rAddr)
+
+	// closing stream as soon as ctx is done aborts the handshake
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close() //nolint: errcheck, gosec
+	})
+	tp, err := doHandshake(conn, hs, c.netType, c.log)
+	if !stop() {
+		freePort()
+		release()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		freePort()
+		release()
+		return nil, err
+	}
+	tp.freePort = freePort
+	tp.onClose = releaseFunc(c.events().closeFunc(rAddr.PK), release)
+	tp.compression = sessionTp.compression
+	tp.count(nil)
+	return tp, nil
+}
+
+// releaseFunc chains releasing the session of a stream transport to its onClose
+func releaseFunc(onClose, release func()) func() {
+	return func() {
+		onClose()
+		release()
+	}
+}
+
+// closeSessions closes all sessions, which closes their streams as well
+func (c *genericClient) closeSessions() {
+	c.sessionsMu.Lock()
+	sessions := make([]*yamux.Session, 0, len(c.sessions))
+	for session := range c.sessions {
+		sessions = append(sessions, session)
+	}
+	c.sessionsMu.Unlock()
+	for _, session := range sessions {
+		if err := session.Close(); err != nil {
+			c.log.WithError(err).Warnf("Failed to close session")
+		}
+	}
+}
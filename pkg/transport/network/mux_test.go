@@ -0,0 +1,274 @@
+// Package network pkg/transport/network/mux_test.go
+package network
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire/pkg/app/appevent"
+)
+
+// newTestMuxSTCPPair creates stcp clients with the given multiplexing settings,
+// the dialer knows the address of the remote
+func newTestMuxSTCPPair(t testing.TB, dialerMux, remoteMux bool) (*stcpClient, *stcpClient) {
+	newClient := func(mux bool) *stcpClient {
+		pk, sk := cipher.GenerateKeyPair()
+		f := &ClientFactory{
+			PK:         pk,
+			SK:         sk,
+			ListenAddr: "127.0.0.1:0",
+			EB:         appevent.NewBroadcaster(nil, time.Second),
+			Mux:        mux,
+		}
+		c, err := f.MakeClient(STCP, 0)
+		require.NoError(t, err)
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Close()) })
+		return c.(*stcpClient)
+	}
+	dialer, remote := newClient(dialerMux), newClient(remoteMux)
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+	dialer.AddPKEntry(remote.PK(), remoteAddr.String())
+	return dialer, remote
+}
+
+// serveEcho listens on the port of c and echoes data of all accepted transports
+func serveEcho(t testing.TB, c Client, port uint16) {
+	lis, err := c.Listen(port)
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() }) //nolint:errcheck
+	go func() {
+		for {
+			tp, err := lis.AcceptTransport()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(tp, tp) //nolint:errcheck
+				tp.Close()      //nolint:errcheck
+			}()
+		}
+	}()
+}
+
+// checkEcho writes payload to tp and checks that it is echoed back
+func checkEcho(tp io.ReadWriter, payload []byte) error {
+	writeErr := make(chan error, 1)
+	go func() {
+		_, err := tp.Write(payload)
+		writeErr <- err
+	}()
+	got := make([]byte, len(payload))
+	if _, err := io.ReadFull(tp, got); err != nil {
+		return err
+	}
+	if err := <-writeErr; err != nil {
+		return err
+	}
+	if !bytes.Equal(payload, got) {
+		return fmt.Errorf("echoed %d bytes do not match", len(got))
+	}
+	return nil
+}
+
+func sessionCount(c *genericClient) int {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	return len(c.sessions)
+}
+
+func dialTest(t testing.TB, dialer, remote Client) Transport {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tp, err := dialer.Dial(ctx, remote.PK(), testTransportPort)
+	require.NoError(t, err)
+	return tp
+}
+
+func TestMux_ConcurrentStreams(t *testing.T) {
+	const streams = 16
+	dialer, remote := newTestMuxSTCPPair(t, true, true)
+	serveEcho(t, remote, testTransportPort)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, streams)
+	for i := 0; i < streams; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			tp, err := dialer.Dial(ctx, remote.PK(), testTransportPort)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer tp.Close() //nolint:errcheck
+			errs <- checkEcho(tp, bytes.Repeat([]byte{byte(i)}, 64*1024))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 1, sessionCount(dialer.genericClient))
+	require.Equal(t, 1, sessionCount(remote.genericClient))
+
+	// the remote dials back over the session without knowing the dialer address
+	serveEcho(t, dialer, testTransportPort)
+	tp := dialTest(t, remote, dialer)
+	defer tp.Close() //nolint:errcheck
+	require.Equal(t, dialer.PK(), tp.RemotePK())
+	require.NoError(t, checkEcho(tp, []byte("dial back")))
+}
+
+func TestMux_Fallback(t *testing.T) {
+	tests := []struct {
+		name                 string
+		dialerMux, remoteMux bool
+	}{
+		{name: "remote does not support", dialerMux: true, remoteMux: false},
+		{name: "dialer does not support", dialerMux: false, remoteMux: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dialer, remote := newTestMuxSTCPPair(t, tc.dialerMux, tc.remoteMux)
+			serveEcho(t, remote, testTransportPort)
+			for i := 0; i < 2; i++ {
+				tp := dialTest(t, dialer, remote)
+				require.NoError(t, checkEcho(tp, []byte("plain")))
+				_, isStream := tp.(*transport).Conn.(*muxStream)
+				require.False(t, isStream)
+				require.NoError(t, tp.Close())
+			}
+			require.Zero(t, sessionCount(dialer.genericClient))
+			require.Zero(t, sessionCount(remote.genericClient))
+		})
+	}
+}
+
+func TestMux_Close(t *testing.T) {
+	dialer, remote := newTestMuxSTCPPair(t, true, true)
+	serveEcho(t, remote, testTransportPort)
+
+	tp1, tp2 := dialTest(t, dialer, remote), dialTest(t, dialer, remote)
+	require.Equal(t, 1, sessionCount(dialer.genericClient))
+	require.Equal(t, tp1.RemoteRawAddr(), tp2.RemoteRawAddr())
+
+	t.Run("stream", func(t *testing.T) {
+		require.NoError(t, tp1.Close())
+		_, err := tp1.Write([]byte("closed"))
+		require.Error(t, err)
+		// other streams and the session are not affected
+		require.NoError(t, checkEcho(tp2, []byte("still open")))
+		require.Equal(t, 1, sessionCount(dialer.genericClient))
+	})
+
+	t.Run("session", func(t *testing.T) {
+		session, _ := remote.session(dialer.PK())
+		require.NotNil(t, session)
+		require.NoError(t, session.Close())
+
+		require.NoError(t, tp2.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, err := tp2.Read(make([]byte, 1))
+		require.Error(t, err)
+		require.NoError(t, tp2.Close())
+		require.Eventually(t, func() bool {
+			return sessionCount(dialer.genericClient) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// a new session is created by the next dial
+		tp := dialTest(t, dialer, remote)
+		defer tp.Close() //nolint:errcheck
+		require.NoError(t, checkEcho(tp, []byte("new session")))
+		require.Equal(t, 1, sessionCount(dialer.genericClient))
+	})
+}
+
+func TestMux_IdleClose(t *testing.T) {
+	const idleTimeout = 100 * time.Millisecond
+	dialer, remote := newTestMuxSTCPPair(t, true, true)
+	// no sessions are open yet
+	dialer.muxIdleTimeout, remote.muxIdleTimeout = idleTimeout, idleTimeout
+	serveEcho(t, remote, testTransportPort)
+
+	tp1, tp2 := dialTest(t, dialer, remote), dialTest(t, dialer, remote)
+	require.Equal(t, 1, sessionCount(dialer.genericClient))
+	require.Equal(t, tp1.RemoteRawAddr(), tp2.RemoteRawAddr())
+
+	// the session is kept while it carries a transport
+	require.NoError(t, tp1.Close())
+	time.Sleep(3 * idleTimeout)
+	require.NoError(t, checkEcho(tp2, []byte("still open")))
+	require.Equal(t, 1, sessionCount(dialer.genericClient))
+
+	require.NoError(t, tp2.Close())
+	require.Eventually(t, func() bool {
+		return sessionCount(dialer.genericClient) == 0 && sessionCount(remote.genericClient) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// a new session is created by the next dial
+	tp := dialTest(t, dialer, remote)
+	defer tp.Close() //nolint:errcheck
+	require.NoError(t, checkEcho(tp, []byte("new session")))
+	require.Equal(t, 1, sessionCount(dialer.genericClient))
+	require.NotEqual(t, tp1.LocalRawAddr().String(), tp.LocalRawAddr().String())
+}
+
+// BenchmarkMux compares short-lived transports, where multiplexing saves
+// a connection and handshakes per dial, and bulk throughput of a single transport
+func BenchmarkMux(b *testing.B) {
+	for _, mux := range []bool{false, true} {
+		name := "plain"
+		if mux {
+			name = "mux"
+		}
+		b.Run("dial/"+name, func(b *testing.B) {
+			dialer, remote := newTestMuxSTCPPair(b, mux, mux)
+			serveEcho(b, remote, testTransportPort)
+			msg := []byte("ping")
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				tp := dialTest(b, dialer, remote)
+				if err := checkEcho(tp, msg); err != nil {
+					b.Fatal(err)
+				}
+				tp.Close() //nolint:errcheck
+			}
+		})
+		b.Run("throughput/"+name, func(b *testing.B) {
+			dialer, remote := newTestMuxSTCPPair(b, mux, mux)
+			tp, accepted := connectDirect(b, dialer, remote)
+			defer tp.Close()       //nolint:errcheck
+			defer accepted.Close() //nolint:errcheck
+
+			buf := make([]byte, 32*1024)
+			done := make(chan error, 1)
+			go func() {
+				_, err := io.CopyN(io.Discard, accepted, int64(b.N*len(buf)))
+				done <- err
+			}()
+			b.SetBytes(int64(len(buf)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := tp.Write(buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := <-done; err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ package network
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
@@ -21,10 +23,23 @@ const (
 	SUDPH Type = "sudph"
 	// STCP is a type of a transport that works via TCP and resolves addresses using PK table.
 	STCP Type = "stcp"
+	// SQUIC is a type of a transport that works via QUIC and resolves addresses using address-resolver service.
+	SQUIC Type = "squic"
 	// DMSG is a type of a transport that works through an intermediary service
 	DMSG Type = "dmsg"
 )
 
+// IsDirect returns true when the network connects visors directly
+// and false when traffic is relayed through an intermediary, or the network is unknown
+func (t Type) IsDirect() bool {
+	switch t {
+	case STCPR, SUDPH, STCP, SQUIC:
+		return true
+	default:
+		return false
+	}
+}
+
 //go:generate mockery -name Dialer -case underscore -inpkg
 
 // Dialer is an entity that can be dialed and asked for its type.
@@ -48,4 +63,23 @@ var (
 
 	// ErrPortOccupied is returned when port is occupied.
 	ErrPortOccupied = errors.New("port is already occupied")
+
+	// ErrDialTimeout is matched by dials that did not complete before the deadline.
+	ErrDialTimeout = errors.New("dial timed out")
+
+	// ErrPKNotResolved is matched by dials to visors whose address could not be
+	// found, in the address resolver, the PK table or the dmsg discovery.
+	ErrPKNotResolved = errors.New("could not resolve PK")
+
+	// ErrHandshakeFailed is matched by transports whose handshake or
+	// encryption setup with the remote visor failed.
+	ErrHandshakeFailed = errors.New("handshake failed")
+
+	// ErrNetworkNotReady is matched by dials over networks that are not
+	// connected to the services they depend on yet, retrying later may succeed.
+	ErrNetworkNotReady = errors.New("network is not ready")
+
+	// ErrListenerClosed is returned when accepting from a closed listener.
+	// It wraps io.ErrClosedPipe returned by listeners before.
+	ErrListenerClosed = fmt.Errorf("listener closed: %w", io.ErrClosedPipe)
 )
@@ -0,0 +1,134 @@
+// Package network pkg/transport/network/network_test.go
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/pkg/disc"
+	"github.com/skycoin/dmsg/pkg/dmsg"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire/pkg/app/appevent"
+	"github.com/skycoin/skywire/pkg/transport/network/addrresolver"
+)
+
+func TestType_IsDirect(t *testing.T) {
+	tests := []struct {
+		netType Type
+		want    bool
+	}{
+		{netType: STCPR, want: true},
+		{netType: SUDPH, want: true},
+		{netType: STCP, want: true},
+		{netType: DMSG, want: false},
+		{netType: Type("unknown"), want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.netType), func(t *testing.T) {
+			require.Equal(t, tc.want, tc.netType.IsDirect())
+		})
+	}
+}
+
+// resolvingAR returns an address resolver failing to resolve visors with err
+func resolvingAR(err error) addrresolver.APIClient {
+	ar := &addrresolver.MockAPIClient{}
+	ar.On("Resolve", mock.Anything, mock.Anything, mock.Anything).Return(addrresolver.VisorData{}, err)
+	return ar
+}
+
+func TestDialErrors(t *testing.T) {
+	remotePK, _ := cipher.GenerateKeyPair()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	newSUDPHClient := func(t *testing.T, ar addrresolver.APIClient) Client {
+		pk, sk := cipher.GenerateKeyPair()
+		f := &ClientFactory{PK: pk, SK: sk, ARClient: ar, EB: appevent.NewBroadcaster(nil, time.Second)}
+		c, err := f.MakeClient(SUDPH, 0)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, c.Close()) })
+		return c
+	}
+
+	t.Run("not in PK table", func(t *testing.T) {
+		_, err := newTestSTCPClient(t, nil).Dial(ctx, remotePK, testTransportPort)
+		require.ErrorIs(t, err, ErrPKNotResolved)
+		require.ErrorIs(t, err, ErrStcpEntryNotFound)
+	})
+
+	t.Run("not in address resolver", func(t *testing.T) {
+		_, err := newSUDPHClient(t, resolvingAR(addrresolver.ErrNoEntry)).Dial(ctx, remotePK, testTransportPort)
+		require.ErrorIs(t, err, ErrPKNotResolved)
+		require.ErrorIs(t, err, addrresolver.ErrNoEntry)
+	})
+
+	t.Run("address resolver not ready", func(t *testing.T) {
+		_, err := newSUDPHClient(t, resolvingAR(addrresolver.ErrNotReady)).Dial(ctx, remotePK, testTransportPort)
+		require.ErrorIs(t, err, ErrNetworkNotReady)
+		require.NotErrorIs(t, err, ErrPKNotResolved)
+	})
+
+	t.Run("handshake failed", func(t *testing.T) {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer lis.Close() //nolint:errcheck
+		go func() {
+			for {
+				conn, err := lis.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close() //nolint:errcheck
+			}
+		}()
+
+		dialer := newTestSTCPClient(t, nil)
+		dialer.AddPKEntry(remotePK, lis.Addr().String())
+		_, err = dialer.Dial(ctx, remotePK, testTransportPort)
+		require.ErrorIs(t, err, ErrHandshakeFailed)
+		require.NotErrorIs(t, err, ErrDialTimeout)
+	})
+
+	t.Run("port occupied", func(t *testing.T) {
+		c := newTestSTCPClient(t, nil)
+		lis, err := c.Listen(testTransportPort)
+		require.NoError(t, err)
+		defer lis.Close() //nolint:errcheck
+		_, err = c.Listen(testTransportPort)
+		require.ErrorIs(t, err, ErrPortOccupied)
+	})
+}
+
+func TestDmsgDialErr(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	// the client is not served, so it is never ready
+	c := &dmsgClientAdapter{dmsgC: dmsg.NewClient(pk, sk, disc.NewMock(0), nil)}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "entry not found", err: dmsg.ErrDiscEntryNotFound, want: ErrPKNotResolved},
+		{name: "no delegated servers", err: dmsg.ErrDiscEntryHasNoDelegated, want: ErrPKNotResolved},
+		{name: "invalid response", err: dmsg.ErrDialRespInvalidSig, want: ErrHandshakeFailed},
+		{name: "not ready", err: dmsg.ErrCannotConnectToDelegated, want: ErrNetworkNotReady},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := c.dialErr(tc.err)
+			require.ErrorIs(t, err, tc.want)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+	require.NoError(t, c.dialErr(nil))
+	require.NotErrorIs(t, c.dialErr(errors.New("other")), ErrPKNotResolved)
+}
@@ -0,0 +1,87 @@
+// Package network pkg/transport/network/ping.go
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+const (
+	// pingNonceSize is the size of the nonce echoed back by the remote visor
+	pingNonceSize = 16
+	// echoTimeout bounds the time a probe connection is served for
+	echoTimeout = 10 * time.Second
+)
+
+// ErrPingMismatch is returned when the remote visor echoes back a different nonce
+var ErrPingMismatch = errors.New("ping nonce mismatch")
+
+// Ping measures round-trip time to the remote visor listening for probes with
+// ServeEcho on the given port. The time spent dialing is not included. When the
+// client multiplexes transports, the probe reuses the connection to the visor
+// if one is open
+func Ping(ctx context.Context, c Client, rPK cipher.PubKey, port uint16) (time.Duration, error) {
+	tp, err := c.Dial(ctx, rPK, port)
+	if err != nil {
+		return 0, err
+	}
+	defer tp.Close() //nolint:errcheck
+
+	// closing transport as soon as ctx is done aborts the probe
+	stop := context.AfterFunc(ctx, func() {
+		tp.Close() //nolint:errcheck
+	})
+	defer stop()
+
+	nonce := make([]byte, pingNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	if _, err := tp.Write(nonce); err != nil {
+		return 0, pingErr(ctx, err)
+	}
+	echo := make([]byte, pingNonceSize)
+	if _, err := io.ReadFull(tp, echo); err != nil {
+		return 0, pingErr(ctx, err)
+	}
+	rtt := time.Since(start)
+	if !bytes.Equal(nonce, echo) {
+		return 0, ErrPingMismatch
+	}
+	return rtt, nil
+}
+
+func pingErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return fmt.Errorf("ping failed: %w", err)
+}
+
+// ServeEcho echoes back nonces sent by Ping over transports accepted from lis,
+// until lis is closed
+func ServeEcho(lis Listener) error {
+	for {
+		tp, err := lis.AcceptTransport()
+		if err != nil {
+			return err
+		}
+		go echoTransport(tp)
+	}
+}
+
+func echoTransport(tp Transport) {
+	defer tp.Close() //nolint:errcheck
+	if err := tp.SetDeadline(time.Now().Add(echoTimeout)); err != nil {
+		return
+	}
+	io.CopyN(tp, tp, pingNonceSize) //nolint:errcheck
+}
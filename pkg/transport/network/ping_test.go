@@ -0,0 +1,41 @@
+// Package network pkg/transport/network/ping_test.go
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPing(t *testing.T) {
+	const port = 44
+
+	local := newTestSTCPClient(t, nil)
+	remote := newTestSTCPClient(t, nil)
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+	local.AddPKEntry(remote.PK(), remoteAddr.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// nobody answers probes yet
+	_, err = Ping(ctx, local, remote.PK(), port)
+	require.Error(t, err)
+
+	lis, err := remote.Listen(port)
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- ServeEcho(lis) }()
+
+	for i := 0; i < 3; i++ {
+		rtt, err := Ping(ctx, local, remote.PK(), port)
+		require.NoError(t, err)
+		require.Positive(t, rtt)
+	}
+
+	require.NoError(t, lis.Close())
+	require.ErrorIs(t, <-served, ErrListenerClosed)
+}
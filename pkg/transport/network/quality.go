@@ -0,0 +1,144 @@
+// Package network pkg/transport/network/quality.go
+package network
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+// DefaultQualityHalfLife is the default time after which weight of a dial
+// sample in the quality score is halved
+const DefaultQualityHalfLife = 10 * time.Minute
+
+type qualityKey struct {
+	pk      cipher.PubKey
+	netType Type
+}
+
+// qualityStats holds exponentially decaying sums of dial samples
+type qualityStats struct {
+	total      float64
+	successes  float64
+	latencySum float64 // seconds, successful dials only
+	updated    time.Time
+}
+
+// decay ages all sums to the time `now`
+func (s *qualityStats) decay(now time.Time, halfLife time.Duration) {
+	elapsed := now.Sub(s.updated)
+	if elapsed <= 0 {
+		return
+	}
+	factor := math.Pow(0.5, float64(elapsed)/float64(halfLife))
+	s.total *= factor
+	s.successes *= factor
+	s.latencySum *= factor
+	s.updated = now
+}
+
+// score is the success rate lowered by the average latency of successful dials
+func (s *qualityStats) score() float64 {
+	if s.total == 0 {
+		return 0
+	}
+	rate := s.successes / s.total
+	if s.successes == 0 {
+		return rate
+	}
+	return rate / (1 + s.latencySum/s.successes)
+}
+
+// QualityScorer scores networks to remote visors by recent dial success rate
+// and latency. Older samples weigh less, their weight halves every half life
+type QualityScorer struct {
+	mx       sync.Mutex
+	halfLife time.Duration
+	now      func() time.Time
+	stats    map[qualityKey]*qualityStats
+}
+
+// NewQualityScorer creates a QualityScorer. Non-positive halfLife
+// means DefaultQualityHalfLife
+func NewQualityScorer(halfLife time.Duration) *QualityScorer {
+	if halfLife <= 0 {
+		halfLife = DefaultQualityHalfLife
+	}
+	return &QualityScorer{
+		halfLife: halfLife,
+		now:      time.Now,
+		stats:    make(map[qualityKey]*qualityStats),
+	}
+}
+
+// Record adds a dial sample for the remote visor over the given network.
+// Latency is only taken into account for successful dials
+func (q *QualityScorer) Record(pk cipher.PubKey, netType Type, latency time.Duration, success bool) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	now := q.now()
+	key := qualityKey{pk: pk, netType: netType}
+	s, ok := q.stats[key]
+	if !ok {
+		s = &qualityStats{updated: now}
+		q.stats[key] = s
+	}
+	s.decay(now, q.halfLife)
+
+	s.total++
+	if success {
+		s.successes++
+		s.latencySum += latency.Seconds()
+	}
+}
+
+// OnConn records dial events, it may be used as ClientFactory.OnConn
+func (q *QualityScorer) OnConn(ev ConnEvent) {
+	switch ev.Type {
+	case ConnEventDialSuccess:
+		q.Record(ev.RemotePK, ev.Network, ev.Latency, true)
+	case ConnEventDialFail:
+		q.Record(ev.RemotePK, ev.Network, ev.Latency, false)
+	}
+}
+
+// Score returns quality score of the network to the remote visor in range [0, 1]
+// and false if there are no samples for it
+func (q *QualityScorer) Score(pk cipher.PubKey, netType Type) (float64, bool) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	s, ok := q.stats[qualityKey{pk: pk, netType: netType}]
+	if !ok {
+		return 0, false
+	}
+	s.decay(q.now(), q.halfLife)
+	return s.score(), true
+}
+
+// BestNetwork returns the network with the highest quality score to the
+// remote visor and false if no network to it was dialed yet
+func (q *QualityScorer) BestNetwork(pk cipher.PubKey) (Type, bool) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	var (
+		best      Type
+		bestScore = -1.0
+	)
+	now := q.now()
+	for key, s := range q.stats {
+		if key.pk != pk {
+			continue
+		}
+		s.decay(now, q.halfLife)
+		// ties are broken by network name to keep the choice deterministic
+		if score := s.score(); score > bestScore || (score == bestScore && key.netType < best) {
+			best, bestScore = key.netType, score
+		}
+	}
+	return best, bestScore >= 0
+}
@@ -0,0 +1,97 @@
+// Package network pkg/transport/network/quality_test.go
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+func newTestQualityScorer(halfLife time.Duration) (*QualityScorer, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	q := NewQualityScorer(halfLife)
+	q.now = func() time.Time { return now }
+	return q, &now
+}
+
+func TestQualityScorer_BestNetwork(t *testing.T) {
+	pk, _ := cipher.GenerateKeyPair()
+	otherPK, _ := cipher.GenerateKeyPair()
+
+	t.Run("no samples", func(t *testing.T) {
+		q, _ := newTestQualityScorer(time.Minute)
+		q.Record(otherPK, STCPR, 10*time.Millisecond, true)
+
+		_, ok := q.BestNetwork(pk)
+		require.False(t, ok)
+	})
+
+	t.Run("success rate wins", func(t *testing.T) {
+		q, _ := newTestQualityScorer(time.Minute)
+		for i := 0; i < 10; i++ {
+			q.Record(pk, STCPR, 10*time.Millisecond, i%2 == 0)
+			q.Record(pk, DMSG, 200*time.Millisecond, true)
+		}
+
+		best, ok := q.BestNetwork(pk)
+		require.True(t, ok)
+		require.Equal(t, DMSG, best)
+	})
+
+	t.Run("latency wins on equal success rate", func(t *testing.T) {
+		q, _ := newTestQualityScorer(time.Minute)
+		for i := 0; i < 10; i++ {
+			q.Record(pk, STCPR, 20*time.Millisecond, true)
+			q.Record(pk, SUDPH, 80*time.Millisecond, true)
+			q.Record(pk, DMSG, 300*time.Millisecond, true)
+		}
+
+		best, ok := q.BestNetwork(pk)
+		require.True(t, ok)
+		require.Equal(t, STCPR, best)
+	})
+
+	t.Run("old samples decay", func(t *testing.T) {
+		q, now := newTestQualityScorer(time.Minute)
+		for i := 0; i < 20; i++ {
+			q.Record(pk, STCPR, 10*time.Millisecond, false)
+		}
+		q.Record(pk, DMSG, 200*time.Millisecond, true)
+
+		best, ok := q.BestNetwork(pk)
+		require.True(t, ok)
+		require.Equal(t, DMSG, best)
+
+		// stcpr recovers, failures from an hour ago barely count anymore
+		*now = now.Add(time.Hour)
+		for i := 0; i < 3; i++ {
+			q.Record(pk, STCPR, 10*time.Millisecond, true)
+		}
+
+		best, ok = q.BestNetwork(pk)
+		require.True(t, ok)
+		require.Equal(t, STCPR, best)
+
+		score, ok := q.Score(pk, STCPR)
+		require.True(t, ok)
+		require.InDelta(t, 1/(1+0.01), score, 0.01)
+	})
+
+	t.Run("dial events", func(t *testing.T) {
+		q, _ := newTestQualityScorer(time.Minute)
+		q.OnConn(ConnEvent{Type: ConnEventDialStart, Network: STCPR, RemotePK: pk})
+		q.OnConn(ConnEvent{Type: ConnEventDialFail, Network: STCPR, RemotePK: pk, Latency: time.Millisecond})
+		q.OnConn(ConnEvent{Type: ConnEventDialSuccess, Network: SUDPH, RemotePK: pk, Latency: time.Millisecond})
+
+		best, ok := q.BestNetwork(pk)
+		require.True(t, ok)
+		require.Equal(t, SUDPH, best)
+
+		score, ok := q.Score(pk, STCPR)
+		require.True(t, ok)
+		require.Zero(t, score)
+	})
+}
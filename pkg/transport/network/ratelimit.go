@@ -0,0 +1,180 @@
+// Package network pkg/transport/network/ratelimit.go
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimit caps bandwidth of transports of a network. Zero rate is unlimited
+type RateLimit struct {
+	// Up is the number of bytes per second written to transports
+	Up int64
+	// Down is the number of bytes per second read from transports
+	Down int64
+	// PerConn applies the rates to each transport separately, instead of
+	// sharing them by all the transports of the network
+	PerConn bool
+}
+
+func (l RateLimit) unlimited() bool {
+	return l.Up <= 0 && l.Down <= 0
+}
+
+// RateLimiter caps bandwidth of transports per network. It is set in
+// ClientFactory to limit created clients, limits may be changed at runtime
+type RateLimiter struct {
+	mx   sync.Mutex
+	nets map[Type]*netLimiter
+}
+
+// NewRateLimiter creates RateLimiter with the initial limits of networks
+func NewRateLimiter(limits map[Type]RateLimit) *RateLimiter {
+	r := &RateLimiter{nets: make(map[Type]*netLimiter)}
+	for netType, limit := range limits {
+		r.SetLimit(netType, limit)
+	}
+	return r
+}
+
+// SetLimit changes the limit of the network, applying it to open transports as well
+func (r *RateLimiter) SetLimit(netType Type, limit RateLimit) {
+	r.network(netType).setLimit(limit)
+}
+
+// Limit returns the current limit of the network
+func (r *RateLimiter) Limit(netType Type) RateLimit {
+	nl := r.network(netType)
+	nl.mx.Lock()
+	defer nl.mx.Unlock()
+	return nl.limit
+}
+
+// network returns limiter of the network, nil RateLimiter returns nil limiter
+func (r *RateLimiter) network(netType Type) *netLimiter {
+	if r == nil {
+		return nil
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	nl, ok := r.nets[netType]
+	if !ok {
+		nl = &netLimiter{}
+		r.nets[netType] = nl
+	}
+	return nl
+}
+
+// netLimiter holds the limit of a single network and the buckets shared by its
+// transports. Buckets of transports limited separately are guarded by mx as well
+type netLimiter struct {
+	mx       sync.Mutex
+	limit    RateLimit
+	up, down tokenBucket
+}
+
+func (nl *netLimiter) setLimit(limit RateLimit) {
+	nl.mx.Lock()
+	defer nl.mx.Unlock()
+	nl.limit = limit
+}
+
+// reserve takes n bytes from the bucket of the direction and returns the time
+// to wait before they may be transferred
+func (nl *netLimiter) reserve(up bool, connBucket *tokenBucket, n int) time.Duration {
+	nl.mx.Lock()
+	defer nl.mx.Unlock()
+	rate, bucket := nl.limit.Down, &nl.down
+	if up {
+		rate, bucket = nl.limit.Up, &nl.up
+	}
+	if nl.limit.PerConn {
+		bucket = connBucket
+	}
+	return bucket.take(n, rate, time.Now())
+}
+
+// tokenBucket holds up to a second worth of tokens, a token per byte. Taking
+// more tokens than available puts the bucket in debt, which is paid off by
+// waiting, so that large transfers are limited as well
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take removes n tokens refilled at rate per second and returns the time to wait
+// until the bucket is out of debt. Zero rate never waits
+func (b *tokenBucket) take(n int, rate int64, now time.Time) time.Duration {
+	if rate <= 0 {
+		b.last = time.Time{}
+		return 0
+	}
+	if b.last.IsZero() {
+		b.tokens = float64(rate)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * float64(rate)
+		if b.tokens > float64(rate) {
+			b.tokens = float64(rate)
+		}
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(rate) * float64(time.Second))
+}
+
+// limitConn wraps conn to cap its bandwidth by the limiter of the network,
+// conn is returned as is if nl is nil
+func limitConn(conn net.Conn, nl *netLimiter) net.Conn {
+	if nl == nil {
+		return conn
+	}
+	return &limitedConn{Conn: conn, nl: nl, done: make(chan struct{})}
+}
+
+// limitedConn waits before writes and after reads for the limiter to
+// allow the transferred bytes
+type limitedConn struct {
+	net.Conn
+	nl       *netLimiter
+	up, down tokenBucket
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *limitedConn) wait(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-c.done:
+	}
+}
+
+// Read implements net.Conn
+func (c *limitedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.wait(c.nl.reserve(false, &c.down, n))
+	}
+	return n, err
+}
+
+// Write implements net.Conn
+func (c *limitedConn) Write(b []byte) (int, error) {
+	c.wait(c.nl.reserve(true, &c.up, len(b)))
+	return c.Conn.Write(b)
+}
+
+// Close implements net.Conn
+func (c *limitedConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,108 @@
+// Package network pkg/transport/network/ratelimit_test.go
+package network
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var b tokenBucket
+	now := time.Now()
+
+	// bucket starts full
+	require.Zero(t, b.take(100, 100, now))
+	require.Equal(t, 500*time.Millisecond, b.take(50, 100, now))
+	// debt is paid off after waiting
+	now = now.Add(500 * time.Millisecond)
+	require.Zero(t, b.take(0, 100, now))
+	// bucket holds a second worth of tokens at most
+	now = now.Add(time.Hour)
+	require.Equal(t, time.Second, b.take(200, 100, now))
+	// zero rate is unlimited
+	require.Zero(t, b.take(1<<30, 0, now))
+}
+
+// transferTest writes size bytes to each of the conns, wrapped with the limiter
+// of the network, and returns the time it took
+func transferTest(t *testing.T, nl *netLimiter, up bool, conns int, size int) time.Duration {
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < conns; i++ {
+		c1, c2 := newTestTCPPair(t)
+		writer, reader := c1, limitConn(c2, nl)
+		if up {
+			writer, reader = limitConn(c1, nl), c2
+		}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer writer.Close() //nolint:errcheck
+			chunk := make([]byte, 32*1024)
+			for sent := 0; sent < size; sent += len(chunk) {
+				if _, err := writer.Write(chunk); err != nil {
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			defer reader.Close()        //nolint:errcheck
+			io.Copy(io.Discard, reader) //nolint:errcheck
+		}()
+	}
+	wg.Wait()
+	return time.Since(start)
+}
+
+func TestLimitConn(t *testing.T) {
+	const rate = 1 << 20
+	// the first second worth of bytes is the burst, so the rest takes half a second
+	const size = rate + rate/2
+	const expected = 500 * time.Millisecond
+
+	requireRate := func(t *testing.T, elapsed time.Duration) {
+		require.InDelta(t, float64(expected), float64(elapsed), float64(expected)/2,
+			"transfer took %v, expected %v", elapsed, expected)
+	}
+
+	t.Run("up", func(t *testing.T) {
+		nl := &netLimiter{limit: RateLimit{Up: rate}}
+		requireRate(t, transferTest(t, nl, true, 1, size))
+	})
+
+	t.Run("down", func(t *testing.T) {
+		nl := &netLimiter{limit: RateLimit{Down: rate}}
+		requireRate(t, transferTest(t, nl, false, 1, size))
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		// the rate is shared by the conns, which transfer size bytes together
+		nl := &netLimiter{limit: RateLimit{Up: rate}}
+		requireRate(t, transferTest(t, nl, true, 2, size/2))
+	})
+
+	t.Run("per conn", func(t *testing.T) {
+		nl := &netLimiter{limit: RateLimit{Up: rate, PerConn: true}}
+		requireRate(t, transferTest(t, nl, true, 3, size))
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		r := NewRateLimiter(map[Type]RateLimit{STCP: {Up: 1}})
+		r.SetLimit(STCP, RateLimit{})
+		require.True(t, r.Limit(STCP).unlimited())
+		require.Less(t, transferTest(t, r.network(STCP), true, 1, 4*size), expected)
+	})
+}
+
+func TestLimitConn_Nil(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close() //nolint:errcheck
+	defer c2.Close() //nolint:errcheck
+	require.Equal(t, c1, limitConn(c1, (*RateLimiter)(nil).network(STCP)))
+}
@@ -0,0 +1,118 @@
+// Package network pkg/transport/network/registry.go
+package network
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// NetworkFactory builds clients of a single network type. Built-in networks
+// are registered by this package, other packages may add their own networks
+// with RegisterNetwork
+type NetworkFactory interface {
+	// Type returns the network type clients of which are built by the factory
+	Type() Type
+	// MakeClient builds a client using dependencies and settings of the ClientFactory.
+	// port is the local OS port to listen on, 0 means any
+	MakeClient(f *ClientFactory, port int) (Client, error)
+}
+
+var (
+	registryMx sync.RWMutex
+	registry   = make(map[Type]NetworkFactory)
+)
+
+func init() {
+	for _, factory := range []NetworkFactory{
+		builtinNetwork{netType: STCP, makeClient: func(f *ClientFactory, _ int) Client {
+			return newStcp(f.genericClient(STCP), f.PKTable, f.LANDiscovery)
+		}},
+		builtinNetwork{netType: STCPR, makeClient: func(f *ClientFactory, port int) Client {
+			return newStcpr(f.resolvedClient(STCPR), port)
+		}},
+		builtinNetwork{netType: SQUIC, makeClient: func(f *ClientFactory, port int) Client {
+			return newSquic(f.resolvedClient(SQUIC), port)
+		}},
+		builtinNetwork{netType: SUDPH, makeClient: func(f *ClientFactory, port int) Client {
+			return newSudph(f.resolvedClient(SUDPH), port)
+		}},
+		builtinNetwork{netType: DMSG, makeClient: func(f *ClientFactory, _ int) Client {
+			metrics := f.Metrics.network(DMSG)
+			events := connEventer{netType: DMSG, onConn: f.OnConn, metrics: metrics, status: newClientStatus()}
+			return newDmsgClient(f.DmsgC, events, f.dialTimeout(), f.RateLimiter.network(DMSG))
+		}},
+	} {
+		if err := RegisterNetwork(factory.Type(), factory); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterNetwork makes clients of netType available to ClientFactory
+// and so to the transport manager. It fails if netType is already registered
+func RegisterNetwork(netType Type, factory NetworkFactory) error {
+	if netType == "" || factory == nil {
+		return fmt.Errorf("%w: empty network type or factory", ErrUnknownTransportType)
+	}
+	if factory.Type() != netType {
+		return fmt.Errorf("factory of %s network registered as %s", factory.Type(), netType)
+	}
+	registryMx.Lock()
+	defer registryMx.Unlock()
+	if _, ok := registry[netType]; ok {
+		return fmt.Errorf("network %s is already registered", netType)
+	}
+	registry[netType] = factory
+	return nil
+}
+
+// IsKnownNetwork returns true when netType is registered
+func IsKnownNetwork(netType Type) bool {
+	_, ok := lookupNetwork(netType)
+	return ok
+}
+
+// RegisteredNetworks returns all registered network types sorted by name
+func RegisteredNetworks() []Type {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+	types := make([]Type, 0, len(registry))
+	for netType := range registry {
+		types = append(types, netType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// ParseType returns network type named s if it is registered
+func ParseType(s string) (Type, error) {
+	netType := Type(s)
+	if !IsKnownNetwork(netType) {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTransportType, s)
+	}
+	return netType, nil
+}
+
+func lookupNetwork(netType Type) (NetworkFactory, bool) {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+	factory, ok := registry[netType]
+	return factory, ok
+}
+
+// builtinNetwork is a factory of networks implemented in this package
+type builtinNetwork struct {
+	netType    Type
+	makeClient func(f *ClientFactory, port int) Client
+}
+
+// Type implements NetworkFactory
+func (n builtinNetwork) Type() Type {
+	return n.netType
+}
+
+// MakeClient implements NetworkFactory
+func (n builtinNetwork) MakeClient(f *ClientFactory, port int) (Client, error) {
+	return n.makeClient(f, port), nil
+}
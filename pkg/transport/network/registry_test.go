@@ -0,0 +1,51 @@
+// Package network pkg/transport/network/registry_test.go
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// customNetwork builds stcp clients under a different network type
+type customNetwork struct {
+	netType Type
+}
+
+func (n customNetwork) Type() Type {
+	return n.netType
+}
+
+func (n customNetwork) MakeClient(f *ClientFactory, _ int) (Client, error) {
+	generic := f.genericClient(n.netType)
+	c := newStcp(generic, f.PKTable, f.LANDiscovery)
+	generic.netType = n.netType
+	return c, nil
+}
+
+func TestRegisterNetwork(t *testing.T) {
+	const custom Type = "custom_registry_test"
+
+	for _, netType := range []Type{STCP, STCPR, SUDPH, DMSG} {
+		require.True(t, IsKnownNetwork(netType))
+	}
+	require.False(t, IsKnownNetwork(custom))
+	_, err := ParseType(string(custom))
+	require.ErrorIs(t, err, ErrUnknownTransportType)
+	_, err = (&ClientFactory{}).MakeClient(custom, 0)
+	require.ErrorIs(t, err, ErrUnknownTransportType)
+
+	require.Error(t, RegisterNetwork(custom, customNetwork{netType: STCP}))
+	require.Error(t, RegisterNetwork(STCP, customNetwork{netType: STCP}))
+	require.NoError(t, RegisterNetwork(custom, customNetwork{netType: custom}))
+	require.Error(t, RegisterNetwork(custom, customNetwork{netType: custom}))
+
+	netType, err := ParseType(string(custom))
+	require.NoError(t, err)
+	require.Equal(t, custom, netType)
+	require.Contains(t, RegisteredNetworks(), custom)
+
+	c, err := (&ClientFactory{ListenAddr: "127.0.0.1:0"}).MakeClient(custom, 0)
+	require.NoError(t, err)
+	require.Equal(t, custom, c.Type())
+}
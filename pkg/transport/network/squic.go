@@ -0,0 +1,284 @@
+// Package network pkg/transport/network/squic.go
+package network
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire-utilities/pkg/netutil"
+	"github.com/skycoin/skywire/pkg/transport/network/handshake"
+)
+
+// squicALPN is the application protocol negotiated in QUIC TLS handshakes
+const squicALPN = "skywire-squic"
+
+type squicClient struct {
+	*resolvedClient
+	port int
+}
+
+func newSquic(resolved *resolvedClient, port int) Client {
+	client := &squicClient{resolvedClient: resolved, port: port}
+	client.netType = SQUIC
+	return client
+}
+
+// squicQUICConfig is shared by dialing and listening sides. Keep alive
+// prevents idle transports from being closed by QUIC
+var squicQUICConfig = &quic.Config{
+	HandshakeIdleTimeout: handshake.Timeout,
+	KeepAlivePeriod:      15 * time.Second,
+}
+
+// Dial implements interface
+func (c *squicClient) Dial(ctx context.Context, rPK cipher.PubKey, rPort uint16) (tp Transport, err error) {
+	if c.isClosed() {
+		return nil, io.ErrClosedPipe
+	}
+	ctx, cancel := withDialTimeout(ctx, c.defaultDialTimeout)
+	defer cancel()
+
+	start := c.events().dialStarted(rPK)
+	defer func() {
+		err = dialTimeoutErr(ctx, c.netType, rPK, err)
+		c.events().dialDone(rPK, start, err)
+	}()
+	c.log.Debugf("Dialing PK %v", rPK)
+	return c.dialTransport(ctx, rPK, rPort, func() (net.Conn, error) {
+		return c.dialVisor(ctx, rPK, c.dial)
+	})
+}
+
+// dial opens a QUIC connection with a single stream to addr. Peers are authenticated
+// by skywire handshake over the stream, so TLS certificates are not verified
+func (c *squicClient) dial(ctx context.Context, addr string) (net.Conn, error) {
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: true, //nolint:gosec
+		NextProtos:         []string{squicALPN},
+		MinVersion:         tls.VersionTLS13,
+	}
+	qConn, err := quic.DialAddr(ctx, addr, tlsConf, squicQUICConfig)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := qConn.OpenStreamSync(ctx)
+	if err != nil {
+		qConn.CloseWithError(0, "") //nolint: errcheck, gosec
+		return nil, err
+	}
+	return &squicConn{Stream: stream, conn: qConn}, nil
+}
+
+// Start implements Client interface
+func (c *squicClient) Start() error {
+	if c.connListener != nil {
+		return ErrAlreadyListening
+	}
+	go c.serve()
+	return nil
+}
+
+func (c *squicClient) serve() {
+	lis, err := c.listen()
+	if err != nil {
+		c.log.Errorf("Failed to listen: %v", err)
+		c.status.setError(err)
+		return
+	}
+
+	_, port, err := net.SplitHostPort(lis.Addr().String())
+	if err != nil {
+		c.log.Errorf("Failed to extract port from addr %v: %v", lis.Addr(), err)
+		return
+	}
+	hasPublic, err := netutil.HasPublicIP()
+	if err != nil {
+		c.log.Errorf("Failed to check for public IP: %v", err)
+	}
+	if hasPublic {
+		c.log.Debug("Binding")
+		if err := c.ar.BindSQUIC(context.Background(), port); err != nil {
+			c.log.Errorf("Failed to bind SQUIC: %v", err)
+			c.status.setError(err)
+		} else {
+			c.log.Debugf("Successfully bound squic to port %s", port)
+			go c.resolveExternalAddr()
+		}
+	} else {
+		c.log.Debug("Not binding SQUIC: no public IP address found")
+	}
+	c.acceptTransports(lis)
+}
+
+func (c *squicClient) listen() (net.Listener, error) {
+	tlsConf, err := squicServerTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	var confPort string
+	if c.port != 0 {
+		confPort = fmt.Sprintf(":%d", c.port)
+	}
+	for {
+		lis, err := quic.ListenAddr(confPort, tlsConf, squicQUICConfig)
+		if err == nil {
+			return newSquicListener(lis), nil
+		}
+		if c.port == 0 {
+			return nil, err
+		}
+		c.log.WithError(err).Warnf("Failed to listen on port: %d", c.port)
+		c.port++
+		confPort = fmt.Sprintf(":%d", c.port)
+		c.log.Warnf("Trying port %d", c.port)
+	}
+}
+
+// squicServerTLSConfig creates TLS config with an ephemeral self-signed certificate.
+// The certificate is not used for authentication, which is done by skywire handshake
+func squicServerTLSConfig() (*tls.Config, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().AddDate(10, 0, 0)}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		NextProtos:   []string{squicALPN},
+		MinVersion:   tls.VersionTLS13,
+	}, nil
+}
+
+// squicListener accepts QUIC connections as net.Conn over their first stream
+type squicListener struct {
+	*quic.Listener
+	conns chan net.Conn
+	done  chan struct{}
+	err   error // set before done is closed
+}
+
+func newSquicListener(lis *quic.Listener) *squicListener {
+	l := &squicListener{Listener: lis, conns: make(chan net.Conn), done: make(chan struct{})}
+	go l.acceptConns()
+	return l
+}
+
+func (l *squicListener) acceptConns() {
+	for {
+		qConn, err := l.Listener.Accept(context.Background())
+		if err != nil {
+			if errors.Is(err, quic.ErrServerClosed) {
+				err = net.ErrClosed
+			}
+			l.err = err
+			close(l.done)
+			return
+		}
+		go l.acceptStream(qConn)
+	}
+}
+
+// acceptStream waits for the stream of the connection, which is announced
+// by the first frame of skywire handshake, so that slow peers do not block others
+func (l *squicListener) acceptStream(qConn quic.Connection) {
+	ctx, cancel := context.WithTimeout(context.Background(), handshake.Timeout)
+	defer cancel()
+	stream, err := qConn.AcceptStream(ctx)
+	if err != nil {
+		qConn.CloseWithError(0, "") //nolint: errcheck, gosec
+		return
+	}
+	conn := &squicConn{Stream: stream, conn: qConn}
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close() //nolint: errcheck, gosec
+	}
+}
+
+// Accept implements net.Listener
+func (l *squicListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+// squicLinger is how long a closed squicConn keeps its connection open to
+// deliver data written before closing, unless the peer closes it first
+const squicLinger = 5 * time.Second
+
+// squicConn is a QUIC stream that owns its connection
+type squicConn struct {
+	quic.Stream
+	conn       quic.Connection
+	peerClosed atomic.Bool
+	closeOnce  sync.Once
+	closeErr   error
+}
+
+// Read implements net.Conn
+func (c *squicConn) Read(b []byte) (int, error) {
+	n, err := c.Stream.Read(b)
+	if errors.Is(err, io.EOF) {
+		c.peerClosed.Store(true)
+	}
+	return n, err
+}
+
+// Close implements net.Conn. Closing the QUIC connection discards unsent data,
+// so unless the peer is done with the stream, the connection is closed after
+// the peer closes it or squicLinger passes
+func (c *squicConn) Close() error {
+	c.closeOnce.Do(func() {
+		// write side is already done if the peer stopped reading the stream
+		if c.Stream.Context().Err() == nil {
+			c.closeErr = c.Stream.Close()
+		}
+		// unblock pending reads like closing a TCP conn does
+		c.Stream.CancelRead(0)
+		if c.peerClosed.Load() {
+			c.conn.CloseWithError(0, "") //nolint: errcheck, gosec
+			return
+		}
+		go func() {
+			select {
+			case <-c.conn.Context().Done():
+			case <-time.After(squicLinger):
+			}
+			c.conn.CloseWithError(0, "") //nolint: errcheck, gosec
+		}()
+	})
+	return c.closeErr
+}
+
+// LocalAddr implements net.Conn
+func (c *squicConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr implements net.Conn
+func (c *squicConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
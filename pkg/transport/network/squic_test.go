@@ -0,0 +1,246 @@
+// Package network pkg/transport/network/squic_test.go
+package network
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire/pkg/app/appevent"
+	"github.com/skycoin/skywire/pkg/transport/network/addrresolver"
+)
+
+const testTransportPort = 10
+
+// directTransportPair creates a dialing and a listening client of a direct network,
+// addr overrides the address the dialer reaches the remote at, if set
+type directTransportPair func(t testing.TB, addr func(remote net.Addr) string) (dialer, remote Client)
+
+func newTestSQUICPair(t testing.TB, addr func(remote net.Addr) string) (Client, Client) {
+	newClient := func(ar addrresolver.APIClient) Client {
+		pk, sk := cipher.GenerateKeyPair()
+		f := &ClientFactory{
+			PK:       pk,
+			SK:       sk,
+			ARClient: ar,
+			EB:       appevent.NewBroadcaster(nil, time.Second),
+		}
+		c, err := f.MakeClient(SQUIC, 0)
+		require.NoError(t, err)
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Close()) })
+		return c
+	}
+
+	remoteAR := &addrresolver.MockAPIClient{}
+	remoteAR.On("BindSQUIC", mock.Anything, mock.Anything).Return(nil).Maybe()
+	remoteAR.On("Resolve", mock.Anything, string(SQUIC), mock.Anything).
+		Return(addrresolver.VisorData{}, addrresolver.ErrNoEntry).Maybe()
+	remote := newClient(remoteAR)
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+
+	dialerAR := &addrresolver.MockAPIClient{}
+	dialerAR.On("BindSQUIC", mock.Anything, mock.Anything).Return(nil).Maybe()
+	dialerAR.On("Resolve", mock.Anything, string(SQUIC), remote.PK()).
+		Return(addrresolver.VisorData{RemoteAddr: addr(remoteAddr)}, nil)
+	// clients resolve their own external address once bound
+	dialerAR.On("Resolve", mock.Anything, string(SQUIC), mock.Anything).
+		Return(addrresolver.VisorData{}, addrresolver.ErrNoEntry).Maybe()
+	return newClient(dialerAR), remote
+}
+
+func newTestSTCPPair(t testing.TB, addr func(remote net.Addr) string) (Client, Client) {
+	newClient := func() STCPClient {
+		pk, sk := cipher.GenerateKeyPair()
+		f := &ClientFactory{
+			PK:         pk,
+			SK:         sk,
+			ListenAddr: "127.0.0.1:0",
+			EB:         appevent.NewBroadcaster(nil, time.Second),
+		}
+		c, err := f.MakeClient(STCP, 0)
+		require.NoError(t, err)
+		require.NoError(t, c.Start())
+		t.Cleanup(func() { require.NoError(t, c.Close()) })
+		return c.(STCPClient)
+	}
+	dialer, remote := newClient(), newClient()
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+	dialer.AddPKEntry(remote.PK(), addr(remoteAddr))
+	return dialer, remote
+}
+
+// loopbackAddr returns loopback address with the port of addr
+func loopbackAddr(addr net.Addr) string {
+	_, port, _ := net.SplitHostPort(addr.String()) //nolint:errcheck
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// connectDirect dials remote from dialer and returns both ends of the transport
+func connectDirect(t testing.TB, dialer, remote Client) (Transport, Transport) {
+	lis, err := remote.Listen(testTransportPort)
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() }) //nolint:errcheck
+
+	acceptCh := make(chan Transport, 1)
+	go func() {
+		tp, err := lis.AcceptTransport()
+		if err == nil {
+			acceptCh <- tp
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tp, err := dialer.Dial(ctx, remote.PK(), testTransportPort)
+	require.NoError(t, err)
+	select {
+	case accepted := <-acceptCh:
+		return tp, accepted
+	case <-ctx.Done():
+		t.Fatal("transport was not accepted")
+		return nil, nil
+	}
+}
+
+// TestDirectTransports runs the same checks against all direct networks
+// which can be set up without external services
+func TestDirectTransports(t *testing.T) {
+	pairs := map[Type]directTransportPair{
+		STCP:  newTestSTCPPair,
+		SQUIC: newTestSQUICPair,
+	}
+	for netType, newPair := range pairs {
+		t.Run(string(netType), func(t *testing.T) {
+			dialer, remote := newPair(t, loopbackAddr)
+			tp, accepted := connectDirect(t, dialer, remote)
+
+			require.Equal(t, netType, tp.Network())
+			require.Equal(t, remote.PK(), tp.RemotePK())
+			require.Equal(t, dialer.PK(), accepted.RemotePK())
+			require.EqualValues(t, testTransportPort, tp.RemotePort())
+			require.EqualValues(t, testTransportPort, accepted.LocalPort())
+
+			payload := bytes.Repeat([]byte("direct transport "), 16*1024)
+			for _, pipe := range [][2]Transport{{tp, accepted}, {accepted, tp}} {
+				writeErr := make(chan error, 1)
+				go func(w Transport) {
+					_, err := w.Write(payload)
+					writeErr <- err
+				}(pipe[0])
+				got := make([]byte, len(payload))
+				_, err := io.ReadFull(pipe[1], got)
+				require.NoError(t, err)
+				require.NoError(t, <-writeErr)
+				require.Equal(t, payload, got)
+			}
+
+			require.NoError(t, tp.Close())
+			require.NoError(t, accepted.SetReadDeadline(time.Now().Add(5*time.Second)))
+			_, err := accepted.Read(make([]byte, 1))
+			require.Error(t, err)
+			require.NoError(t, accepted.Close())
+		})
+	}
+}
+
+// lossyUDPProxy relays UDP packets between a single client and the target,
+// dropping the given fraction of packets in both directions
+func lossyUDPProxy(t testing.TB, target string, loss float64) string {
+	front, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	targetAddr, err := net.ResolveUDPAddr("udp", target)
+	require.NoError(t, err)
+	back, err := net.DialUDP("udp", nil, targetAddr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		front.Close() //nolint:errcheck
+		back.Close()  //nolint:errcheck
+	})
+
+	var mx sync.Mutex
+	var client net.Addr
+	drop := func() bool { return rand.Float64() < loss } //nolint:gosec
+
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, addr, err := front.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			mx.Lock()
+			client = addr
+			mx.Unlock()
+			if !drop() {
+				back.Write(buf[:n]) //nolint:errcheck
+			}
+		}
+	}()
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := back.Read(buf)
+			if err != nil {
+				return
+			}
+			mx.Lock()
+			addr := client
+			mx.Unlock()
+			if addr != nil && !drop() {
+				front.WriteTo(buf[:n], addr) //nolint:errcheck
+			}
+		}
+	}()
+	return front.LocalAddr().String()
+}
+
+// BenchmarkDirectTransport_PacketLoss compares throughput of squic over a lossy link
+// with stcp, which shares the TCP path with stcpr. Loss is not simulated for
+// TCP, as it requires kernel level tools like netem
+func BenchmarkDirectTransport_PacketLoss(b *testing.B) {
+	buf := make([]byte, 32*1024)
+	run := func(b *testing.B, newPair directTransportPair, addr func(net.Addr) string) {
+		dialer, remote := newPair(b, addr)
+		tp, accepted := connectDirect(b, dialer, remote)
+		defer tp.Close()       //nolint:errcheck
+		defer accepted.Close() //nolint:errcheck
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := io.CopyN(io.Discard, accepted, int64(b.N*len(buf)))
+			done <- err
+		}()
+		b.SetBytes(int64(len(buf)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := tp.Write(buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := <-done; err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("stcp", func(b *testing.B) { run(b, newTestSTCPPair, loopbackAddr) })
+	for _, loss := range []float64{0, 0.01, 0.05} {
+		b.Run(fmt.Sprintf("squic/loss=%.0f%%", loss*100), func(b *testing.B) {
+			run(b, newTestSQUICPair, func(remote net.Addr) string {
+				return lossyUDPProxy(b, loopbackAddr(remote), loss)
+			})
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Package network pkg/transport/network/status.go
+package network
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+// NetworkStatus is a snapshot of the state of a network client
+type NetworkStatus struct {
+	Network Type `json:"network"`
+	// Ready is true when the client accepts transports
+	Ready bool `json:"ready"`
+	// LocalAddrs are raw network addresses the client listens on
+	LocalAddrs []string `json:"local_addrs,omitempty"`
+	// ExternalAddr is the address other visors reach the client at,
+	// as reported by address resolver
+	ExternalAddr string          `json:"external_addr,omitempty"`
+	DmsgSessions int             `json:"dmsg_sessions,omitempty"`
+	DmsgServers  []cipher.PubKey `json:"dmsg_servers,omitempty"`
+	// DmsgSessionsTarget is the number of dmsg sessions kept, it is set by the visor
+	DmsgSessionsTarget int `json:"dmsg_sessions_target,omitempty"`
+	// Listeners is the number of open skywire port listeners
+	Listeners   int    `json:"listeners"`
+	ActiveConns int64  `json:"active_conns"`
+	LastError   string `json:"last_error,omitempty"`
+	// LastErrorAt is zero if the client has not failed yet
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+}
+
+// StatusReporter is implemented by clients reporting their status.
+// All built-in clients implement it, networks registered by other
+// packages may do so as well
+type StatusReporter interface {
+	Status() NetworkStatus
+}
+
+// ReadyReporter is implemented by clients signaling when they are ready to
+// accept transports. Direct clients are ready once their listener is bound
+// and registered in address resolver where applicable, dmsg client once it
+// has a session with a dmsg server
+type ReadyReporter interface {
+	Ready() <-chan struct{}
+}
+
+// clientStatus tracks state of a client which is not available otherwise.
+// All methods are no-op for nil clientStatus
+type clientStatus struct {
+	activeConns atomic.Int64
+
+	mx           sync.Mutex
+	lastErr      string
+	lastErrAt    time.Time
+	externalAddr string
+}
+
+func newClientStatus() *clientStatus {
+	return &clientStatus{}
+}
+
+// record updates status on connection lifecycle event
+func (s *clientStatus) record(ev ConnEvent) {
+	if s == nil {
+		return
+	}
+	switch ev.Type {
+	case ConnEventDialSuccess, ConnEventAccept:
+		s.activeConns.Add(1)
+	case ConnEventClose:
+		s.activeConns.Add(-1)
+	case ConnEventDialFail:
+		s.setError(ev.Err)
+	}
+}
+
+// setError records err as the last error of the client
+func (s *clientStatus) setError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.lastErr = err.Error()
+	s.lastErrAt = time.Now()
+}
+
+func (s *clientStatus) setExternalAddr(addr string) {
+	if s == nil {
+		return
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.externalAddr = addr
+}
+
+// fill sets fields of status tracked by s
+func (s *clientStatus) fill(status *NetworkStatus) {
+	if s == nil {
+		return
+	}
+	status.ActiveConns = s.activeConns.Load()
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	status.ExternalAddr = s.externalAddr
+	status.LastError = s.lastErr
+	status.LastErrorAt = s.lastErrAt
+}
+
+// Status implements StatusReporter
+func (c *genericClient) Status() NetworkStatus {
+	status := NetworkStatus{Network: c.netType}
+	c.mu.RLock()
+	select {
+	case <-c.listenStarted:
+		status.Ready = !c.isClosed()
+		status.LocalAddrs = []string{c.connListener.Addr().String()}
+	default:
+	}
+	for _, lis := range c.listeners {
+		if !lis.isClosed() {
+			status.Listeners++
+		}
+	}
+	c.mu.RUnlock()
+	c.status.fill(&status)
+	return status
+}
+
+// Ready implements ReadyReporter
+func (c *genericClient) Ready() <-chan struct{} {
+	return c.listenStarted
+}
+
+// resolveExternalAddr asks address resolver for the address the client
+// was bound at, which is the address other visors dial
+func (c *resolvedClient) resolveExternalAddr() {
+	ctx, cancel := withDialTimeout(context.Background(), c.defaultDialTimeout)
+	defer cancel()
+	visorData, err := c.ar.Resolve(ctx, string(c.netType), c.lPK)
+	if err != nil {
+		c.log.WithError(err).Debug("Failed to resolve external address")
+		return
+	}
+	c.status.setExternalAddr(visorData.RemoteAddr)
+}
+
+// Status implements StatusReporter
+func (c *dmsgClientAdapter) Status() NetworkStatus {
+	status := NetworkStatus{Network: DMSG, Listeners: int(c.listeners.Load())}
+	for _, session := range c.dmsgC.AllSessions() {
+		status.DmsgServers = append(status.DmsgServers, session.RemotePK())
+		status.LocalAddrs = append(status.LocalAddrs, session.SessionCommon.GetConn().LocalAddr().String())
+	}
+	status.DmsgSessions = len(status.DmsgServers)
+	status.Ready = status.DmsgSessions > 0
+	c.events.status.fill(&status)
+	return status
+}
+
+// Ready implements ReadyReporter
+func (c *dmsgClientAdapter) Ready() <-chan struct{} {
+	return c.dmsgC.Ready()
+}
@@ -0,0 +1,55 @@
+// Package network pkg/transport/network/status_test.go
+package network
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+)
+
+func TestClient_Status(t *testing.T) {
+	dialer, remote := newTestMuxSTCPPair(t, false, false)
+
+	status := remote.Status()
+	require.Equal(t, STCP, status.Network)
+	require.True(t, status.Ready)
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+	require.Equal(t, []string{remoteAddr.String()}, status.LocalAddrs)
+	require.Zero(t, status.Listeners)
+	require.Zero(t, status.ActiveConns)
+	require.Empty(t, status.LastError)
+
+	lis, err := remote.Listen(testTransportPort)
+	require.NoError(t, err)
+	require.Equal(t, 1, remote.Status().Listeners)
+
+	tp := dialTest(t, dialer, remote)
+	rTp, err := lis.AcceptTransport()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, dialer.Status().ActiveConns)
+	require.EqualValues(t, 1, remote.Status().ActiveConns)
+
+	require.NoError(t, tp.Close())
+	require.NoError(t, rTp.Close())
+	require.Eventually(t, func() bool {
+		return dialer.Status().ActiveConns == 0 && remote.Status().ActiveConns == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, lis.Close())
+	require.Zero(t, remote.Status().Listeners)
+
+	// failed dials are reported as the last error
+	unknownPK, _ := cipher.GenerateKeyPair()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = dialer.Dial(ctx, unknownPK, testTransportPort)
+	require.Error(t, err)
+	status = dialer.Status()
+	require.Equal(t, err.Error(), status.LastError)
+	require.False(t, status.LastErrorAt.IsZero())
+}
@@ -4,8 +4,11 @@ package network
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"sync"
+	"syscall"
 
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
 	"github.com/skycoin/skywire/pkg/transport/network/stcp"
@@ -15,16 +18,43 @@ import (
 type STCPConfig struct {
 	PKTable          map[cipher.PubKey]string `json:"pk_table"`
 	ListeningAddress string                   `json:"listening_address"`
+	// LANDiscovery enables discovery of STCP visors on the local network, off if nil
+	LANDiscovery *LANDiscoveryConfig `json:"lan_discovery,omitempty"`
+}
+
+// STCPClient is a Client of Skywire-TCP network. It resolves remote visors
+// using a PK table that can be updated at runtime
+type STCPClient interface {
+	Client
+	// AddPKEntry associates remote visor public key with the given address
+	// to be used by subsequent dials
+	AddPKEntry(pk cipher.PubKey, addr string)
+	// RemovePKEntry removes remote visor public key from the PK table
+	RemovePKEntry(pk cipher.PubKey)
+	// PKEntries returns all the entries of the PK table, except the entries
+	// discovered on the local network which are not verified yet
+	PKEntries() map[cipher.PubKey]string
+	// LANEntries returns the entries discovered on the local network
+	LANEntries() map[cipher.PubKey]LANEntry
 }
 
 type stcpClient struct {
 	*genericClient
-	table stcp.PKTable
+	table   stcp.PKTable
+	lanConf *LANDiscoveryConfig
+
+	lanMx sync.Mutex
+	lan   *lanDiscovery
 }
 
-func newStcp(generic *genericClient, table stcp.PKTable) Client {
-	client := &stcpClient{genericClient: generic, table: table}
+func newStcp(generic *genericClient, table stcp.PKTable, lanConf *LANDiscoveryConfig) Client {
+	if table == nil {
+		table = stcp.NewTable(nil)
+	}
+	client := &stcpClient{genericClient: generic, table: table, lanConf: lanConf}
 	client.netType = STCP
+	// announced addresses are verified by the visors connecting from them
+	client.onAuthenticated = client.authenticated
 	return client
 }
 
@@ -34,26 +64,108 @@ var ErrStcpEntryNotFound = errors.New("entry not found in PK table")
 
 // Dial implements Client interface
 func (c *stcpClient) Dial(ctx context.Context, rPK cipher.PubKey, rPort uint16) (Transport, error) {
+	return c.dial(ctx, rPK, rPort, nil)
+}
+
+// DialFrom implements SourcePortDialer interface
+func (c *stcpClient) DialFrom(ctx context.Context, localPort uint16, rPK cipher.PubKey, rPort uint16) (Transport, error) {
+	return c.dial(ctx, rPK, rPort, &net.TCPAddr{Port: int(localPort)})
+}
+
+// DialWithOptions implements LocalAddrDialer interface
+func (c *stcpClient) DialWithOptions(ctx context.Context, opts DialOptions, rPK cipher.PubKey, rPort uint16) (Transport, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return c.dial(ctx, rPK, rPort, opts.tcpAddr())
+}
+
+// dial dials remote visor, from lAddr over a new connection if it is set
+func (c *stcpClient) dial(ctx context.Context, rPK cipher.PubKey, rPort uint16, lAddr *net.TCPAddr) (tp Transport, err error) {
 	if c.isClosed() {
 		return nil, io.ErrClosedPipe
 	}
+	ctx, cancel := withDialTimeout(ctx, c.defaultDialTimeout)
+	defer cancel()
+
+	start := c.events().dialStarted(rPK)
+	defer func() {
+		err = dialTimeoutErr(ctx, c.netType, rPK, err)
+		c.events().dialDone(rPK, start, err)
+	}()
 
 	c.log.Debugf("Dialing PK %v", rPK)
 
-	var conn net.Conn
-	addr, ok := c.table.Addr(rPK)
-	if !ok {
-		return nil, ErrStcpEntryNotFound
+	dial := func() (net.Conn, error) {
+		addr, ok := c.table.Addr(rPK)
+		if !ok {
+			return nil, fmt.Errorf("%w: %w: %s", ErrPKNotResolved, ErrStcpEntryNotFound, rPK)
+		}
+		c.eb.SendTCPDial(context.Background(), string(STCP), addr)
+		return dialTCP(ctx, STCP, addr, lAddr)
+	}
+	if lAddr != nil {
+		return c.dialNewTransport(ctx, rPK, rPort, dial)
+	}
+	return c.dialTransport(ctx, rPK, rPort, dial)
+}
+
+// dialTCP dials addr with the connection bound to lAddr, if it is set.
+// Binding to an address in use fails with LocalAddrInUseError
+func dialTCP(ctx context.Context, netType Type, addr string, lAddr *net.TCPAddr) (net.Conn, error) {
+	var dialer net.Dialer
+	if lAddr != nil {
+		dialer.LocalAddr = lAddr
 	}
-	c.eb.SendTCPDial(context.Background(), string(STCP), addr)
-	dialer := net.Dialer{}
 	conn, err := dialer.DialContext(ctx, "tcp", addr)
-	if err != nil {
-		return nil, err
+	if err != nil && lAddr != nil && errors.Is(err, syscall.EADDRINUSE) {
+		return nil, &LocalAddrInUseError{Network: netType, LocalAddr: lAddr.String(), Err: err}
 	}
+	return conn, err
+}
+
+// AddPKEntry implements STCPClient interface
+func (c *stcpClient) AddPKEntry(pk cipher.PubKey, addr string) {
+	// configured entries take precedence over discovered ones
+	if lan := c.lanDiscovery(); lan != nil {
+		lan.forget(pk)
+	}
+	c.table.AddEntry(pk, addr)
+}
 
-	c.log.Debugf("Dialed %v:%v@%v", rPK, rPort, conn.RemoteAddr())
-	return c.initTransport(ctx, conn, rPK, rPort)
+// RemovePKEntry implements STCPClient interface
+func (c *stcpClient) RemovePKEntry(pk cipher.PubKey) {
+	if lan := c.lanDiscovery(); lan != nil {
+		lan.forget(pk)
+	}
+	c.table.RemoveEntry(pk)
+}
+
+// PKEntries implements STCPClient interface
+func (c *stcpClient) PKEntries() map[cipher.PubKey]string {
+	entries := c.table.Entries()
+	if lan := c.lanDiscovery(); lan != nil {
+		for pk := range entries {
+			if lan.isUnverified(pk) {
+				delete(entries, pk)
+			}
+		}
+	}
+	return entries
+}
+
+// LANEntries implements STCPClient interface
+func (c *stcpClient) LANEntries() map[cipher.PubKey]LANEntry {
+	if lan := c.lanDiscovery(); lan != nil {
+		return lan.lanEntries()
+	}
+	return map[cipher.PubKey]LANEntry{}
+}
+
+func (c *stcpClient) lanDiscovery() *lanDiscovery {
+	c.lanMx.Lock()
+	defer c.lanMx.Unlock()
+	return c.lan
 }
 
 // Start implements Client interface
@@ -71,5 +183,47 @@ func (c *stcpClient) serve() {
 		c.log.Errorf("Failed to listen on %q: %v", c.listenAddr, err)
 		return
 	}
+	if c.lanConf != nil {
+		c.startLANDiscovery(lis.Addr().(*net.TCPAddr).Port)
+	}
 	c.acceptTransports(lis)
 }
+
+// startLANDiscovery announces the listening port on the local network. Failing
+// discovery is logged, STCP works with the configured PK table only then
+func (c *stcpClient) startLANDiscovery(port int) {
+	conns, err := listenLAN(*c.lanConf)
+	if err != nil {
+		c.log.WithError(err).Error("Failed to start LAN discovery")
+		return
+	}
+	c.startLAN(newLANDiscovery(c.log, c.lPK, c.table, conns), port)
+}
+
+func (c *stcpClient) startLAN(lan *lanDiscovery, port int) {
+	c.lanMx.Lock()
+	defer c.lanMx.Unlock()
+	if c.isClosed() {
+		lan.close()
+		return
+	}
+	c.lan = lan
+	lan.start(port)
+}
+
+func (c *stcpClient) authenticated(rPK cipher.PubKey, remoteAddr net.Addr) {
+	if lan := c.lanDiscovery(); lan != nil {
+		lan.authenticated(rPK, remoteAddr)
+	}
+}
+
+// Close implements Client interface
+func (c *stcpClient) Close() error {
+	err := c.genericClient.Close()
+	c.lanMx.Lock()
+	defer c.lanMx.Unlock()
+	if c.lan != nil {
+		c.lan.close()
+	}
+	return err
+}
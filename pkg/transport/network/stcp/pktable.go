@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
 )
@@ -19,24 +20,29 @@ type PKTable interface {
 	Addr(pk cipher.PubKey) (string, bool)
 	PubKey(addr string) (cipher.PubKey, bool)
 	Count() int
+	AddEntry(pk cipher.PubKey, addr string)
+	RemoveEntry(pk cipher.PubKey)
+	Entries() map[cipher.PubKey]string
 }
 
 type memoryTable struct {
 	entries map[cipher.PubKey]string
 	reverse map[string]cipher.PubKey
+	mx      sync.RWMutex
 }
 
 // NewTable instantiates a memory implementation of PKTable.
+// The given entries are copied, so the table can be changed without affecting them.
 func NewTable(entries map[cipher.PubKey]string) PKTable {
-	reverse := make(map[string]cipher.PubKey, len(entries))
-	for pk, addr := range entries {
-		reverse[addr] = pk
+	table := &memoryTable{
+		entries: make(map[cipher.PubKey]string, len(entries)),
+		reverse: make(map[string]cipher.PubKey, len(entries)),
 	}
-
-	return &memoryTable{
-		entries: entries,
-		reverse: reverse,
+	for pk, addr := range entries {
+		table.entries[pk] = addr
+		table.reverse[addr] = pk
 	}
+	return table
 }
 
 // NewTableFromFile is similar to NewTable, but grabs predefined values
@@ -83,17 +89,56 @@ func NewTableFromFile(path string) (PKTable, error) {
 
 // Addr obtains the address associated with the given public key.
 func (mt *memoryTable) Addr(pk cipher.PubKey) (string, bool) {
+	mt.mx.RLock()
+	defer mt.mx.RUnlock()
 	addr, ok := mt.entries[pk]
 	return addr, ok
 }
 
 // PubKey obtains the public key associated with the given public key.
 func (mt *memoryTable) PubKey(addr string) (cipher.PubKey, bool) {
+	mt.mx.RLock()
+	defer mt.mx.RUnlock()
 	pk, ok := mt.reverse[addr]
 	return pk, ok
 }
 
 // Count returns the number of entries within the PKTable implementation.
 func (mt *memoryTable) Count() int {
+	mt.mx.RLock()
+	defer mt.mx.RUnlock()
 	return len(mt.entries)
 }
+
+// AddEntry associates the given public key with the given address,
+// replacing any address previously associated with it.
+func (mt *memoryTable) AddEntry(pk cipher.PubKey, addr string) {
+	mt.mx.Lock()
+	defer mt.mx.Unlock()
+	if oldAddr, ok := mt.entries[pk]; ok {
+		delete(mt.reverse, oldAddr)
+	}
+	mt.entries[pk] = addr
+	mt.reverse[addr] = pk
+}
+
+// RemoveEntry removes the address associated with the given public key.
+func (mt *memoryTable) RemoveEntry(pk cipher.PubKey) {
+	mt.mx.Lock()
+	defer mt.mx.Unlock()
+	if addr, ok := mt.entries[pk]; ok {
+		delete(mt.reverse, addr)
+		delete(mt.entries, pk)
+	}
+}
+
+// Entries returns a copy of all the entries.
+func (mt *memoryTable) Entries() map[cipher.PubKey]string {
+	mt.mx.RLock()
+	defer mt.mx.RUnlock()
+	entries := make(map[cipher.PubKey]string, len(mt.entries))
+	for pk, addr := range mt.entries {
+		entries[pk] = addr
+	}
+	return entries
+}
@@ -0,0 +1,205 @@
+// Package network pkg/transport/network/stcp_test.go
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/skycoin/dmsg/pkg/dmsg"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skywire-utilities/pkg/cipher"
+	"github.com/skycoin/skywire/pkg/app/appevent"
+)
+
+func newTestSTCPClient(t *testing.T, onConn func(ConnEvent)) STCPClient {
+	pk, sk := cipher.GenerateKeyPair()
+	f := &ClientFactory{
+		PK:         pk,
+		SK:         sk,
+		ListenAddr: "127.0.0.1:0",
+		EB:         appevent.NewBroadcaster(nil, time.Second),
+		OnConn:     onConn,
+	}
+
+	c, err := f.MakeClient(STCP, 0)
+	require.NoError(t, err)
+	require.NoError(t, c.Start())
+	t.Cleanup(func() { require.NoError(t, c.Close()) })
+
+	stcpC, ok := c.(STCPClient)
+	require.True(t, ok)
+	return stcpC
+}
+
+func TestSTCPClient_PKEntries(t *testing.T) {
+	const port = 10
+
+	dialer := newTestSTCPClient(t, nil)
+	remote := newTestSTCPClient(t, nil)
+
+	lis, err := remote.Listen(port)
+	require.NoError(t, err)
+
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = dialer.Dial(ctx, remote.PK(), port)
+	require.ErrorIs(t, err, ErrStcpEntryNotFound)
+	require.ErrorContains(t, err, remote.PK().String())
+
+	dialer.AddPKEntry(remote.PK(), remoteAddr.String())
+	require.Equal(t, map[cipher.PubKey]string{remote.PK(): remoteAddr.String()}, dialer.PKEntries())
+
+	acceptCh := make(chan Transport, 1)
+	go func() {
+		tp, err := lis.AcceptTransport()
+		if err == nil {
+			acceptCh <- tp
+		}
+	}()
+
+	tp, err := dialer.Dial(ctx, remote.PK(), port)
+	require.NoError(t, err)
+	require.Equal(t, remote.PK(), tp.RemotePK())
+
+	select {
+	case accepted := <-acceptCh:
+		require.Equal(t, dialer.PK(), accepted.RemotePK())
+		require.NoError(t, accepted.Close())
+	case <-ctx.Done():
+		t.Fatal("transport was not accepted")
+	}
+	require.NoError(t, tp.Close())
+
+	dialer.RemovePKEntry(remote.PK())
+	require.Empty(t, dialer.PKEntries())
+	_, err = dialer.Dial(ctx, remote.PK(), port)
+	require.ErrorIs(t, err, ErrStcpEntryNotFound)
+}
+
+func TestSTCPClient_ListenerAddr(t *testing.T) {
+	const port = 10
+
+	dialer := newTestSTCPClient(t, nil)
+	remote := newTestSTCPClient(t, nil)
+
+	lis, err := remote.Listen(port)
+	require.NoError(t, err)
+	require.Equal(t, dmsg.Addr{PK: remote.PK(), Port: port}, lis.Addr())
+
+	_, err = remote.Listen(port)
+	require.ErrorIs(t, err, ErrPortOccupied)
+
+	remoteAddr, err := remote.LocalAddr()
+	require.NoError(t, err)
+	dialer.AddPKEntry(remote.PK(), remoteAddr.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	acceptCh := make(chan Transport, 1)
+	go func() {
+		tp, err := lis.AcceptTransport()
+		if err == nil {
+			acceptCh <- tp
+		}
+	}()
+
+	tp, err := dialer.Dial(ctx, remote.PK(), port)
+	require.NoError(t, err)
+	require.Equal(t, lis.Addr(), tp.RemoteAddr())
+
+	select {
+	case accepted := <-acceptCh:
+		require.Equal(t, lis.Addr(), accepted.LocalAddr())
+		require.NoError(t, accepted.Close())
+	case <-ctx.Done():
+		t.Fatal("transport was not accepted")
+	}
+	require.NoError(t, tp.Close())
+}
+
+func TestSTCPClient_RejectsPlaintextPeer(t *testing.T) {
+	dialer := newTestSTCPClient(t, nil)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close() //nolint:errcheck
+
+	// plaintext peer reads whatever is sent and answers with garbage,
+	// without ever completing skywire and noise handshakes
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+		buf := make([]byte, 1024)
+		if _, err := conn.Read(buf); err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte("plaintext response\n")) //nolint:errcheck
+	}()
+
+	remotePK, _ := cipher.GenerateKeyPair()
+	dialer.AddPKEntry(remotePK, lis.Addr().String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	_, err = dialer.Dial(ctx, remotePK, 10)
+	require.Error(t, err)
+}
+
+func TestSTCPClient_DialTimeout(t *testing.T) {
+	const timeout = 300 * time.Millisecond
+
+	// blackhole accepts TCP connections but never answers the handshake
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close() //nolint:errcheck
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close() //nolint:errcheck
+		}
+	}()
+
+	remotePK, _ := cipher.GenerateKeyPair()
+
+	dial := func(t *testing.T, ctx context.Context, dialer STCPClient) {
+		dialer.AddPKEntry(remotePK, lis.Addr().String())
+
+		start := time.Now()
+		_, err := dialer.Dial(ctx, remotePK, 10)
+		require.Less(t, time.Since(start), 2*timeout)
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorIs(t, err, ErrDialTimeout)
+		var timeoutErr *DialTimeoutError
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, STCP, timeoutErr.Network)
+		require.Equal(t, remotePK, timeoutErr.RemotePK)
+	}
+
+	t.Run("caller deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		dial(t, ctx, newTestSTCPClient(t, nil))
+	})
+
+	t.Run("default timeout", func(t *testing.T) {
+		dialer := newTestSTCPClient(t, nil)
+		dialer.(*stcpClient).defaultDialTimeout = timeout
+		dial(t, context.Background(), dialer)
+	})
+}
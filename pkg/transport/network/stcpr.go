@@ -24,22 +24,50 @@ func newStcpr(resolved *resolvedClient, port int) Client {
 
 // Dial implements interface
 func (c *stcprClient) Dial(ctx context.Context, rPK cipher.PubKey, rPort uint16) (Transport, error) {
+	return c.dialFrom(ctx, rPK, rPort, nil)
+}
+
+// DialFrom implements SourcePortDialer interface
+func (c *stcprClient) DialFrom(ctx context.Context, localPort uint16, rPK cipher.PubKey, rPort uint16) (Transport, error) {
+	return c.dialFrom(ctx, rPK, rPort, &net.TCPAddr{Port: int(localPort)})
+}
+
+// DialWithOptions implements LocalAddrDialer interface
+func (c *stcprClient) DialWithOptions(ctx context.Context, opts DialOptions, rPK cipher.PubKey, rPort uint16) (Transport, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return c.dialFrom(ctx, rPK, rPort, opts.tcpAddr())
+}
+
+// dialFrom dials remote visor, from lAddr over a new connection if it is set
+func (c *stcprClient) dialFrom(ctx context.Context, rPK cipher.PubKey, rPort uint16, lAddr *net.TCPAddr) (tp Transport, err error) {
 	if c.isClosed() {
 		return nil, io.ErrClosedPipe
 	}
+	ctx, cancel := withDialTimeout(ctx, c.defaultDialTimeout)
+	defer cancel()
+
+	start := c.events().dialStarted(rPK)
+	defer func() {
+		err = dialTimeoutErr(ctx, c.netType, rPK, err)
+		c.events().dialDone(rPK, start, err)
+	}()
 	c.log.Debugf("Dialing PK %v", rPK)
-	conn, err := c.dialVisor(ctx, rPK, c.dial)
-	if err != nil {
-		return nil, err
+	dial := func() (net.Conn, error) {
+		return c.dialVisor(ctx, rPK, func(ctx context.Context, addr string) (net.Conn, error) {
+			return c.dial(ctx, addr, lAddr)
+		})
 	}
-
-	return c.initTransport(ctx, conn, rPK, rPort)
+	if lAddr != nil {
+		return c.dialNewTransport(ctx, rPK, rPort, dial)
+	}
+	return c.dialTransport(ctx, rPK, rPort, dial)
 }
 
-func (c *stcprClient) dial(ctx context.Context, addr string) (net.Conn, error) {
+func (c *stcprClient) dial(ctx context.Context, addr string, lAddr *net.TCPAddr) (net.Conn, error) {
 	c.eb.SendTCPDial(context.Background(), string(STCPR), addr)
-	dialer := net.Dialer{}
-	return dialer.DialContext(ctx, "tcp", addr)
+	return dialTCP(ctx, STCPR, addr, lAddr)
 }
 
 // Start implements Client interface
@@ -74,6 +102,7 @@ func (c *stcprClient) serve() {
 	_, port, err := net.SplitHostPort(localAddr)
 	if err != nil {
 		c.log.Errorf("Failed to extract port from addr %v: %v", err)
+		c.status.setError(err)
 		return
 	}
 	hasPublic, err := netutil.HasPublicIP()
@@ -87,8 +116,10 @@ func (c *stcprClient) serve() {
 	c.log.Debug("Binding")
 	if err := c.ar.BindSTCPR(context.Background(), port); err != nil {
 		c.log.Errorf("Failed to bind STCPR: %v", err)
+		c.status.setError(err)
 		return
 	}
 	c.log.Debugf("Successfully bound stcpr to port %s", port)
+	go c.resolveExternalAddr()
 	c.acceptTransports(lis)
 }
@@ -3,9 +3,12 @@ package network
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/AudriusButkevicius/pfilter"
@@ -29,13 +32,18 @@ const (
 
 type sudphClient struct {
 	*resolvedClient
-	filter *pfilter.PacketFilter
+	// filter is set once the socket used for hole punching is bound
+	filter atomic.Pointer[pfilter.PacketFilter]
 	port   int
+	// boundPort is the local port of the socket used for hole punching, once bound
+	boundPort atomic.Uint32
 }
 
 func newSudph(resolved *resolvedClient, port int) Client {
 	client := &sudphClient{resolvedClient: resolved, port: port}
 	client.netType = SUDPH
+	// kcp sessions do not detect dead peers
+	client.heartbeat = true
 	return client
 }
 
@@ -52,6 +60,7 @@ func (c *sudphClient) serve() {
 	lis, err := c.listen()
 	if err != nil {
 		c.log.Errorf("Failed to listen on port: %v", err)
+		c.status.setError(err)
 		return
 	}
 	c.acceptTransports(lis)
@@ -76,11 +85,12 @@ func (c *sudphClient) listen() (net.Listener, error) {
 		}
 		break
 	}
-	c.filter = pfilter.NewPacketFilter(packetListener)
-	sudphVisorsConn := c.filter.NewConn(visorsConnPriority, nil)
-	c.filter.Start()
+	filter := pfilter.NewPacketFilter(packetListener)
+	sudphVisorsConn := filter.NewConn(visorsConnPriority, nil)
+	filter.Start()
+	c.filter.Store(filter)
 	c.log.Debug("Binding")
-	addrCh, err := c.ar.BindSUDPH(c.filter, c.makeBindHandshake())
+	addrCh, err := c.ar.BindSUDPH(filter, c.makeBindHandshake())
 	if err != nil {
 		return nil, err
 	}
@@ -90,7 +100,11 @@ func (c *sudphClient) listen() (net.Listener, error) {
 		return nil, err
 	}
 
+	if port, err := strconv.ParseUint(localPort, 10, 16); err == nil {
+		c.boundPort.Store(uint32(port))
+	}
 	c.log.Debugf("Successfully bound sudph to port %s", localPort)
+	go c.resolveExternalAddr()
 
 	go c.acceptAddresses(sudphVisorsConn, addrCh)
 	return kcp.ServeConn(nil, 0, 0, sudphVisorsConn)
@@ -129,16 +143,45 @@ func (c *sudphClient) acceptAddresses(conn net.PacketConn, addrCh <-chan addrres
 
 // Dial implements interface
 func (c *sudphClient) Dial(ctx context.Context, rPK cipher.PubKey, rPort uint16) (Transport, error) {
+	return c.dialFrom(ctx, rPK, rPort, nil)
+}
+
+// DialFrom implements SourcePortDialer interface. Connections from the port
+// the client is bound to go through the socket used for hole punching, other
+// ports get a socket of their own
+func (c *sudphClient) DialFrom(ctx context.Context, localPort uint16, rPK cipher.PubKey, rPort uint16) (Transport, error) {
+	return c.dialFrom(ctx, rPK, rPort, &localPort)
+}
+
+// dialFrom dials remote visor, from localPort over a new connection if it is set
+func (c *sudphClient) dialFrom(ctx context.Context, rPK cipher.PubKey, rPort uint16, localPort *uint16) (tp Transport, err error) {
 	if c.isClosed() {
 		return nil, io.ErrClosedPipe
 	}
+	ctx, cancel := withDialTimeout(ctx, c.defaultDialTimeout)
+	defer cancel()
+
+	start := c.events().dialStarted(rPK)
+	defer func() {
+		err = dialTimeoutErr(ctx, c.netType, rPK, err)
+		c.events().dialDone(rPK, start, err)
+	}()
 	// this will lookup visor address in address resolver and then dial that address
-	conn, err := c.dialVisor(ctx, rPK, c.dialWithTimeout)
-	if err != nil {
-		return nil, err
+	if localPort == nil {
+		return c.dialTransport(ctx, rPK, rPort, func() (net.Conn, error) {
+			return c.dialVisor(ctx, rPK, c.dialWithTimeout)
+		})
 	}
-
-	return c.initTransport(ctx, conn, rPK, rPort)
+	if bound := c.boundPort.Load(); *localPort == 0 || uint32(*localPort) == bound {
+		return c.dialNewTransport(ctx, rPK, rPort, func() (net.Conn, error) {
+			return c.dialVisor(ctx, rPK, c.dialWithTimeout)
+		})
+	}
+	return c.dialNewTransport(ctx, rPK, rPort, func() (net.Conn, error) {
+		return c.dialVisor(ctx, rPK, func(_ context.Context, addr string) (net.Conn, error) {
+			return c.dialFromPort(addr, *localPort)
+		})
+	})
 }
 
 func (c *sudphClient) dialWithTimeout(ctx context.Context, addr string) (net.Conn, error) {
@@ -156,6 +199,9 @@ func (c *sudphClient) dialWithTimeout(ctx context.Context, addr string) (net.Con
 				c.log.Debugf("Dialed %v", addr)
 				return conn, nil
 			}
+			if errors.Is(err, ErrNetworkNotReady) {
+				return nil, err
+			}
 			c.log.WithError(err).
 				Warnf("Failed to dial %v, trying again: %v", addr, err)
 		}
@@ -170,7 +216,11 @@ func (c *sudphClient) dial(remoteAddr string) (net.Conn, error) {
 		return nil, fmt.Errorf("net.ResolveUDPAddr (remote): %w", err)
 	}
 
-	dialConn := c.filter.NewConn(dialConnPriority, packetfilter.NewKCPConversationFilter(c.mLog))
+	filter := c.filter.Load()
+	if filter == nil {
+		return nil, fmt.Errorf("%w: sudph socket is not bound", ErrNetworkNotReady)
+	}
+	dialConn := filter.NewConn(dialConnPriority, packetfilter.NewKCPConversationFilter(c.mLog))
 
 	if _, err := dialConn.WriteTo([]byte(holePunchMessage), rAddr); err != nil {
 		return nil, fmt.Errorf("dialConn.WriteTo: %w", err)
@@ -183,3 +233,43 @@ func (c *sudphClient) dial(remoteAddr string) (net.Conn, error) {
 
 	return kcpConn, nil
 }
+
+// dialFromPort sends holepunch packet to the remote addr over a new UDP
+// socket bound to localPort, and returns the connection over the socket
+func (c *sudphClient) dialFromPort(remoteAddr string, localPort uint16) (net.Conn, error) {
+	rAddr, err := net.ResolveUDPAddr("udp", remoteAddr)
+	if err != nil {
+		return nil, fmt.Errorf("net.ResolveUDPAddr (remote): %w", err)
+	}
+	pc, err := net.ListenPacket("udp", fmt.Sprintf(":%d", localPort))
+	if err != nil {
+		return nil, fmt.Errorf("bind source port %d: %w", localPort, err)
+	}
+	if _, err := pc.WriteTo([]byte(holePunchMessage), rAddr); err != nil {
+		pc.Close() //nolint:errcheck
+		return nil, fmt.Errorf("pc.WriteTo: %w", err)
+	}
+	kcpConn, err := kcp.NewConn(remoteAddr, nil, 0, 0, pc)
+	if err != nil {
+		pc.Close() //nolint:errcheck
+		return nil, err
+	}
+	c.log.Debugf("Dialed %v from port %d", remoteAddr, localPort)
+	return &socketConn{Conn: kcpConn, socket: pc}, nil
+}
+
+// socketConn is a connection over a socket owned by it, closing
+// the connection closes the socket as well
+type socketConn struct {
+	net.Conn
+	socket net.PacketConn
+}
+
+// Close implements net.Conn
+func (c *socketConn) Close() error {
+	err := c.Conn.Close()
+	if sErr := c.socket.Close(); err == nil {
+		err = sErr
+	}
+	return err
+}
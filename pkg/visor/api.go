@@ -96,6 +96,8 @@ type API interface {
 
 	//transports
 	TransportTypes() ([]string, error)
+	NetworkStatus() ([]network.NetworkStatus, error)
+	PingNetworks(remote cipher.PubKey, netType string, timeout time.Duration) (map[network.Type]transport.PingResult, error)
 	Transports(types []string, pks []cipher.PubKey, logs bool) ([]*TransportSummary, error)
 	Transport(tid uuid.UUID) (*TransportSummary, error)
 	AddTransport(remote cipher.PubKey, tpType string, timeout time.Duration) (*TransportSummary, error)
@@ -104,6 +106,10 @@ type API interface {
 	SetPublicAutoconnect(pAc bool) error
 	GetPersistentTransports() ([]transport.PersistentTransports, error)
 	SetPersistentTransports([]transport.PersistentTransports) error
+	STCPEntries() (map[cipher.PubKey]string, error)
+	AddSTCPEntry(pk cipher.PubKey, addr string, persist bool) error
+	RemoveSTCPEntry(pk cipher.PubKey, persist bool) error
+	SetDmsgSessions(n int, persist bool) error
 	//transport discovery
 	DiscoverTransportsByPK(pk cipher.PubKey) ([]*transport.Entry, error)
 	DiscoverTransportByID(id uuid.UUID) (*transport.Entry, error)
@@ -1130,6 +1136,51 @@ func (v *Visor) TransportTypes() ([]string, error) {
 	return types, nil
 }
 
+// NetworkStatus implements API.
+func (v *Visor) NetworkStatus() ([]network.NetworkStatus, error) {
+	if v.tpM == nil {
+		return nil, ErrTrpMangerNotAvailable
+	}
+	statuses := v.tpM.NetworkStatus()
+	if v.dmsgSessions != nil {
+		for i := range statuses {
+			if statuses[i].Network == network.DMSG {
+				statuses[i].DmsgSessionsTarget = v.dmsgSessions.Target()
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// defaultPingNetworksTimeout bounds PingNetworks called without timeout
+const defaultPingNetworksTimeout = 20 * time.Second
+
+// PingNetworks implements API. It measures latency to the remote visor over
+// the given network, or over all the networks if netType is empty
+func (v *Visor) PingNetworks(remote cipher.PubKey, netType string, timeout time.Duration) (map[network.Type]transport.PingResult, error) {
+	if v.tpM == nil {
+		return nil, ErrTrpMangerNotAvailable
+	}
+	if timeout <= 0 {
+		timeout = defaultPingNetworksTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if netType == "" {
+		return v.tpM.PingAll(ctx, remote), nil
+	}
+	t, err := network.ParseType(netType)
+	if err != nil {
+		return nil, err
+	}
+	latency, err := v.tpM.Ping(ctx, t, remote)
+	if err != nil {
+		return nil, err
+	}
+	return map[network.Type]transport.PingResult{t: {Latency: latency}}, nil
+}
+
 // Transports implements API.
 func (v *Visor) Transports(types []string, pks []cipher.PubKey, logs bool) ([]*TransportSummary, error) {
 	var result []*TransportSummary
@@ -1188,9 +1239,14 @@ func (v *Visor) AddTransport(remote cipher.PubKey, tpType string, timeout time.D
 		defer cancel()
 	}
 
+	netType, err := network.ParseType(tpType)
+	if err != nil {
+		return nil, err
+	}
+
 	v.log.Debugf("Saving transport to %v via %v", remote, tpType)
 
-	tp, err := v.tpM.SaveTransport(ctx, remote, network.Type(tpType), transport.LabelUser)
+	tp, err := v.tpM.SaveTransport(ctx, remote, netType, transport.LabelUser)
 	if err != nil {
 		return nil, err
 	}
@@ -1480,6 +1536,11 @@ func (v *Visor) SetMinHops(in uint16) error {
 
 // SetPersistentTransports sets min_hops routing config of visor
 func (v *Visor) SetPersistentTransports(pTps []transport.PersistentTransports) error {
+	for _, pTp := range pTps {
+		if !network.IsKnownNetwork(pTp.NetType) {
+			return fmt.Errorf("%w: %s", network.ErrUnknownTransportType, pTp.NetType)
+		}
+	}
 	v.tpM.SetPTpsCache(pTps)
 	return v.conf.UpdatePersistentTransports(pTps)
 }
@@ -1489,6 +1550,67 @@ func (v *Visor) GetPersistentTransports() ([]transport.PersistentTransports, err
 	return v.conf.GetPersistentTransports()
 }
 
+// STCPEntries implements API.
+func (v *Visor) STCPEntries() (map[cipher.PubKey]string, error) {
+	stcpC, err := v.stcpClient()
+	if err != nil {
+		return nil, err
+	}
+	return stcpC.PKEntries(), nil
+}
+
+// AddSTCPEntry implements API.
+func (v *Visor) AddSTCPEntry(pk cipher.PubKey, addr string, persist bool) error {
+	stcpC, err := v.stcpClient()
+	if err != nil {
+		return err
+	}
+	stcpC.AddPKEntry(pk, addr)
+	if persist {
+		return v.conf.UpdateSTCPTable(stcpC.PKEntries())
+	}
+	return nil
+}
+
+// RemoveSTCPEntry implements API.
+func (v *Visor) RemoveSTCPEntry(pk cipher.PubKey, persist bool) error {
+	stcpC, err := v.stcpClient()
+	if err != nil {
+		return err
+	}
+	stcpC.RemovePKEntry(pk)
+	if persist {
+		return v.conf.UpdateSTCPTable(stcpC.PKEntries())
+	}
+	return nil
+}
+
+// SetDmsgSessions implements API. It changes the number of sessions
+// the visor keeps with dmsg servers
+func (v *Visor) SetDmsgSessions(n int, persist bool) error {
+	if v.dmsgSessions == nil {
+		return ErrDmsgNotAvailable
+	}
+	if err := v.dmsgSessions.SetTarget(n); err != nil {
+		return err
+	}
+	if persist {
+		return v.conf.UpdateDmsgSessions(n)
+	}
+	return nil
+}
+
+func (v *Visor) stcpClient() (network.STCPClient, error) {
+	if v.tpM == nil {
+		return nil, ErrTrpMangerNotAvailable
+	}
+	stcpC, ok := v.tpM.Stcp()
+	if !ok {
+		return nil, ErrSTCPNotAvailable
+	}
+	return stcpC, nil
+}
+
 // SetLogRotationInterval sets log_rotation_interval config of visor
 func (v *Visor) SetLogRotationInterval(d visorconfig.Duration) error {
 	return v.conf.UpdateLogRotationInterval(d)
@@ -525,5 +525,8 @@ func rpcClientSystray(conf *visorconfig.V1, logger *logging.Logger) API {
 		time.Sleep(2 * time.Second)
 	}
 	logger.Info("RPC Connection established")
-	return NewRPCClient(logger, conn, RPCPrefix, 0)
+	return NewRPCClientWithReconnect(logger, conn, RPCPrefix, 0, RPCReconnect{
+		Dial:        func() (io.ReadWriteCloser, error) { return net.Dial("tcp", conf.CLIAddr) },
+		InitBackoff: 2 * time.Second,
+	})
 }
@@ -89,6 +89,10 @@ var (
 	stcprC vinit.Module
 	// STCP module
 	stcpC vinit.Module
+	// SQUIC module
+	squicC vinit.Module
+	// Networks registered outside of the network package
+	extNets vinit.Module
 	// dmsg pty: a remote terminal to the visor working over dmsg protocol
 	pty vinit.Module
 	// Dmsg module
@@ -154,6 +158,8 @@ func registerModules(logger *logging.MasterLogger) {
 	sudphC = maker("sudph", initSudphClient, &sc, &tr)
 	stcprC = maker("stcpr", initStcprClient, &tr)
 	stcpC = maker("stcp", initStcpClient, &tr)
+	squicC = maker("squic", initSquicClient, &tr)
+	extNets = maker("external_networks", initExternalNetworks, &tr)
 	dmsgC = maker("dmsg", initDmsg, &ebc, &dmsgHTTP)
 	dmsgCtrl = maker("dmsg_ctrl", initDmsgCtrl, &dmsgC, &tr)
 	dmsgHTTPLogServer = maker("dmsghttp_logserver", initDmsgHTTPLogServer, &dmsgC, &tr)
@@ -173,7 +179,7 @@ func registerModules(logger *logging.MasterLogger) {
 	skyFwd = maker("sky_forward_conn", initSkywireForwardConn, &dmsgC, &dmsgCtrl, &tr, &launch)
 	pi = maker("ping", initPing, &dmsgC, &tm)
 	vis = vinit.MakeModule("visor", vinit.DoNothing, logger, &ebc, &ar, &disc, &pty,
-		&tr, &rt, &launch, &cli, &hvs, &ut, &pv, &pvs, &trs, &stcpC, &stcprC, &skyFwd, &pi, &systemSurvey)
+		&tr, &rt, &launch, &cli, &hvs, &ut, &pv, &pvs, &trs, &stcpC, &stcprC, &squicC, &extNets, &skyFwd, &pi, &systemSurvey)
 
 	hv = maker("hypervisor", initHypervisor, &vis)
 }
@@ -205,6 +211,7 @@ func initDmsgHTTP(ctx context.Context, v *Visor, log *logging.Logger) error { //
 	entries := direct.GetAllEntries(keys, servers)
 	dClient := direct.NewClient(entries, v.MasterLogger().PackageLogger("dmsg_http:direct_client"))
 
+	// StartDmsg returns once the client has a session with a dmsg server
 	dmsgDC, closeDmsgDC, err := direct.StartDmsg(ctx, v.MasterLogger().PackageLogger("dmsg_http:dmsgDC"),
 		v.conf.PK, v.conf.SK, dClient, dmsg.DefaultConfig())
 	if err != nil {
@@ -223,7 +230,6 @@ func initDmsgHTTP(ctx context.Context, v *Visor, log *logging.Logger) error { //
 	v.dmsgHTTP = &dmsgHTTP
 	v.dmsgDC = dmsgDC
 	v.initLock.Unlock()
-	time.Sleep(time.Duration(len(entries)) * time.Second)
 	return nil
 }
 
@@ -241,21 +247,30 @@ func initEventBroadcaster(ctx context.Context, v *Visor, log *logging.Logger) er
 func initAddressResolver(ctx context.Context, v *Visor, log *logging.Logger) error {
 	conf := v.conf.Transport
 
-	httpC, err := getHTTPClient(ctx, v, conf.AddressResolver)
-	if err != nil {
-		return err
-	}
+	var arClients []addrresolver.APIClient
+	for _, arAddr := range conf.AddressResolvers() {
+		httpC, err := getHTTPClient(ctx, v, arAddr)
+		if err != nil {
+			return err
+		}
 
-	// only needed for dmsghttp
-	pIP, err := getPublicIP(v, conf.AddressResolver)
-	if err != nil {
-		return err
+		// only needed for dmsghttp
+		pIP, err := getPublicIP(v, arAddr)
+		if err != nil {
+			return err
+		}
+
+		arClient, err := addrresolver.NewHTTP(arAddr, v.conf.PK, v.conf.SK, httpC, pIP, log, v.MasterLogger())
+		if err != nil {
+			err = fmt.Errorf("failed to create address resolver client: %w", err)
+			return err
+		}
+		arClients = append(arClients, arClient)
 	}
 
-	arClient, err := addrresolver.NewHTTP(conf.AddressResolver, v.conf.PK, v.conf.SK, httpC, pIP, log, v.MasterLogger())
+	arClient, err := addrresolver.NewFailover(log, arClients...)
 	if err != nil {
-		err = fmt.Errorf("failed to create address resolver client: %w", err)
-		return err
+		return fmt.Errorf("failed to create address resolver client: %w", err)
 	}
 
 	v.initLock.Lock()
@@ -324,15 +339,21 @@ func initDmsg(ctx context.Context, v *Visor, log *logging.Logger) (err error) {
 	if err != nil {
 		return err
 	}
-	dmsgC := dmsgc.New(v.conf.PK, v.conf.SK, v.ebc, v.conf.Dmsg, httpC, v.MasterLogger())
+	dmsgC, sessions := dmsgc.New(v.conf.PK, v.conf.SK, v.ebc, v.conf.Dmsg, httpC, v.MasterLogger())
+	sessionsCtx, cancelSessions := context.WithCancel(ctx)
 	wg := new(sync.WaitGroup)
-	wg.Add(1)
+	wg.Add(2)
 	go func() {
 		defer wg.Done()
 		dmsgC.Serve(ctx)
 	}()
+	go func() {
+		defer wg.Done()
+		sessions.Serve(sessionsCtx)
+	}()
 
 	v.pushCloseStack("dmsg", func() error {
+		cancelSessions()
 		if err := dmsgC.Close(); err != nil {
 			return err
 		}
@@ -342,6 +363,7 @@ func initDmsg(ctx context.Context, v *Visor, log *logging.Logger) (err error) {
 
 	v.initLock.Lock()
 	v.dmsgC = dmsgC
+	v.dmsgSessions = sessions
 	v.initLock.Unlock()
 	return nil
 }
@@ -500,6 +522,26 @@ func initStcpClient(ctx context.Context, v *Visor, log *logging.Logger) error {
 	return nil
 }
 
+func initSquicClient(ctx context.Context, v *Visor, log *logging.Logger) error { //nolint:all
+	if v.conf.Transport.SquicPort != 0 {
+		v.tpM.InitClient(ctx, network.SQUIC, v.conf.Transport.SquicPort)
+	}
+	return nil
+}
+
+func initExternalNetworks(ctx context.Context, v *Visor, log *logging.Logger) error { //nolint:all
+	for name, port := range v.conf.Transport.Networks {
+		netType, err := network.ParseType(name)
+		if err != nil {
+			return err
+		}
+		if err := v.tpM.AddNetwork(ctx, netType, port); err != nil {
+			return fmt.Errorf("failed to start %s network: %w", netType, err)
+		}
+	}
+	return nil
+}
+
 func initTransport(ctx context.Context, v *Visor, log *logging.Logger) error {
 
 	managerLogger := v.MasterLogger().PackageLogger("transport_manager")
@@ -539,9 +581,11 @@ func initTransport(ctx context.Context, v *Visor, log *logging.Logger) error {
 	// todo: pass down configuration?
 	var table stcp.PKTable
 	var listenAddr string
+	var lanDiscovery *network.LANDiscoveryConfig
 	if v.conf.STCP != nil {
 		table = stcp.NewTable(v.conf.STCP.PKTable)
 		listenAddr = v.conf.STCP.ListeningAddress
+		lanDiscovery = v.conf.STCP.LANDiscovery
 	}
 	factory := network.ClientFactory{
 		PK:         v.conf.PK,
@@ -551,6 +595,15 @@ func initTransport(ctx context.Context, v *Visor, log *logging.Logger) error {
 		ARClient:   v.arClient,
 		EB:         v.ebc,
 		MLogger:    v.MasterLogger(),
+
+		DialTimeout:    time.Duration(v.conf.Transport.DialTimeout),
+		Compression:    v.conf.Transport.Compression,
+		Mux:            v.conf.Transport.Mux,
+		MuxIdleTimeout: time.Duration(v.conf.Transport.MuxIdleTimeout),
+		Keepalive:      keepaliveConfigs(v.conf.Transport.Keepalive),
+		RateLimiter:    network.NewRateLimiter(rateLimits(v.conf.Transport.RateLimits)),
+
+		LANDiscovery: lanDiscovery,
 	}
 	tpM, err := transport.NewManager(managerLogger, v.arClient, v.ebc, &tpMConf, factory)
 	if err != nil {
@@ -568,9 +621,9 @@ func initTransport(ctx context.Context, v *Visor, log *logging.Logger) error {
 
 	v.pushCloseStack("transport.manager", func() error {
 		cancel()
-		tpM.Close()
+		err := tpM.Close()
 		wg.Wait()
-		return nil
+		return err
 	})
 
 	v.initLock.Lock()
@@ -579,6 +632,27 @@ func initTransport(ctx context.Context, v *Visor, log *logging.Logger) error {
 	return nil
 }
 
+// keepaliveConfigs converts keepalive configs of networks for the client factory
+func keepaliveConfigs(conf map[string]visorconfig.Keepalive) map[network.Type]network.KeepaliveConfig {
+	configs := make(map[network.Type]network.KeepaliveConfig, len(conf))
+	for netType, c := range conf {
+		configs[network.Type(netType)] = network.KeepaliveConfig{
+			Interval: time.Duration(c.Interval),
+			Probes:   c.Probes,
+		}
+	}
+	return configs
+}
+
+// rateLimits converts bandwidth limits of networks for the client factory
+func rateLimits(conf map[string]visorconfig.RateLimit) map[network.Type]network.RateLimit {
+	limits := make(map[network.Type]network.RateLimit, len(conf))
+	for netType, l := range conf {
+		limits[network.Type(netType)] = network.RateLimit{Up: l.Up, Down: l.Down, PerConn: l.PerConn}
+	}
+	return limits
+}
+
 func initTransportSetup(ctx context.Context, v *Visor, log *logging.Logger) error {
 	ctx, cancel := context.WithCancel(ctx)
 	// To remove the block set by NewTransportListener if dmsg is not initialized
@@ -1252,7 +1326,7 @@ func initUptimeTracker(ctx context.Context, v *Visor, log *logging.Logger) error
 
 // advertise this visor as public in service discovery
 // this service is not considered critical and always returns true
-func initPublicVisor(_ context.Context, v *Visor, log *logging.Logger) error { //nolint:all
+func initPublicVisor(ctx context.Context, v *Visor, log *logging.Logger) error { //nolint:all
 	if !v.conf.IsPublic {
 		// call Stop() method to clean service discovery for the situation that
 		// visor was public, then stop (not normal shutdown), then start as non-public
@@ -1275,6 +1349,14 @@ func initPublicVisor(_ context.Context, v *Visor, log *logging.Logger) error { /
 		logger.Warn("No stcpr client found, stopping")
 		return nil
 	}
+	// the client is started asynchronously, its listener may not be bound yet
+	const stcprReadyTimeout = time.Second * 30
+	readyCtx, cancel := context.WithTimeout(ctx, stcprReadyTimeout)
+	defer cancel()
+	if err := v.tpM.WaitReady(readyCtx, network.STCPR); err != nil {
+		logger.WithError(err).Warn("STCPR client is not ready, stopping")
+		return nil
+	}
 	addr, err := stcpr.LocalAddr()
 	if err != nil {
 		logger.Warn("Failed to get STCPR local addr")
@@ -144,23 +144,25 @@ func (r *RPC) LogsSince(in *AppLogsRequest, out *[]string) (err error) {
 
 // TransportSummary summarizes a Transport.
 type TransportSummary struct {
-	ID      uuid.UUID           `json:"id"`
-	Local   cipher.PubKey       `json:"local_pk"`
-	Remote  cipher.PubKey       `json:"remote_pk"`
-	Type    network.Type        `json:"type"`
-	Log     *transport.LogEntry `json:"log,omitempty"`
-	IsSetup bool                `json:"is_setup"`
-	Label   transport.Label     `json:"label"`
+	ID       uuid.UUID           `json:"id"`
+	Local    cipher.PubKey       `json:"local_pk"`
+	Remote   cipher.PubKey       `json:"remote_pk"`
+	Type     network.Type        `json:"type"`
+	IsDirect bool                `json:"is_direct"` // false when relayed through dmsg
+	Log      *transport.LogEntry `json:"log,omitempty"`
+	IsSetup  bool                `json:"is_setup"`
+	Label    transport.Label     `json:"label"`
 }
 
 func newTransportSummary(tm *transport.Manager, tp *transport.ManagedTransport, includeLogs, isSetup bool) *TransportSummary {
 	summary := &TransportSummary{
-		ID:      tp.Entry.ID,
-		Local:   tm.Local(),
-		Remote:  tp.Remote(),
-		Type:    tp.Type(),
-		IsSetup: isSetup,
-		Label:   tp.Entry.Label,
+		ID:       tp.Entry.ID,
+		Local:    tm.Local(),
+		Remote:   tp.Remote(),
+		Type:     tp.Type(),
+		IsDirect: tp.Type().IsDirect(),
+		IsSetup:  isSetup,
+		Label:    tp.Entry.Label,
 	}
 	if includeLogs {
 		summary.Log = tp.LogEntry
@@ -479,6 +481,33 @@ func (r *RPC) TransportTypes(_ *struct{}, out *[]string) (err error) {
 	return err
 }
 
+// NetworkStatus returns status of all the networks of the Visor.
+func (r *RPC) NetworkStatus(_ *struct{}, out *[]network.NetworkStatus) (err error) {
+	defer rpcutil.LogCall(r.log, "NetworkStatus", nil)(out, &err)
+
+	statuses, err := r.visor.NetworkStatus()
+	*out = statuses
+
+	return err
+}
+
+// PingNetworksIn is input for PingNetworks.
+type PingNetworksIn struct {
+	RemotePK cipher.PubKey
+	NetType  string
+	Timeout  time.Duration
+}
+
+// PingNetworks measures latency to a remote visor over the networks of the Visor.
+func (r *RPC) PingNetworks(in *PingNetworksIn, out *map[network.Type]transport.PingResult) (err error) {
+	defer rpcutil.LogCall(r.log, "PingNetworks", in)(out, &err)
+
+	results, err := r.visor.PingNetworks(in.RemotePK, in.NetType, in.Timeout)
+	*out = results
+
+	return err
+}
+
 // TransportsIn is input for Transports.
 type TransportsIn struct {
 	FilterTypes   []string
@@ -665,6 +694,46 @@ func (r *RPC) SetPersistentTransports(pTs *[]transport.PersistentTransports, _ *
 	return err
 }
 
+// STCPEntries gets the entries of visor's stcp pk table
+func (r *RPC) STCPEntries(_ *struct{}, out *map[cipher.PubKey]string) (err error) {
+	defer rpcutil.LogCall(r.log, "STCPEntries", nil)(out, &err)
+
+	entries, err := r.visor.STCPEntries()
+	*out = entries
+	return err
+}
+
+// STCPEntryIn is input for AddSTCPEntry and RemoveSTCPEntry
+type STCPEntryIn struct {
+	PK      cipher.PubKey
+	Addr    string
+	Persist bool
+}
+
+// AddSTCPEntry adds an entry to visor's stcp pk table
+func (r *RPC) AddSTCPEntry(in *STCPEntryIn, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "AddSTCPEntry", in)(nil, &err)
+	return r.visor.AddSTCPEntry(in.PK, in.Addr, in.Persist)
+}
+
+// RemoveSTCPEntry removes an entry from visor's stcp pk table
+func (r *RPC) RemoveSTCPEntry(in *STCPEntryIn, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "RemoveSTCPEntry", in)(nil, &err)
+	return r.visor.RemoveSTCPEntry(in.PK, in.Persist)
+}
+
+// DmsgSessionsIn is input for SetDmsgSessions
+type DmsgSessionsIn struct {
+	Sessions int
+	Persist  bool
+}
+
+// SetDmsgSessions changes the number of sessions visor keeps with dmsg servers
+func (r *RPC) SetDmsgSessions(in *DmsgSessionsIn, _ *struct{}) (err error) {
+	defer rpcutil.LogCall(r.log, "SetDmsgSessions", in)(nil, &err)
+	return r.visor.SetDmsgSessions(in.Sessions, in.Persist)
+}
+
 // SetPublicAutoconnect sets public_autoconnect in visor's routing config
 func (r *RPC) SetPublicAutoconnect(pAc *bool, _ *struct{}) (err error) {
 	defer rpcutil.LogCall(r.log, "SetPublicAutoconnect", *pAc)(nil, &err)
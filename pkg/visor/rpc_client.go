@@ -19,6 +19,7 @@ import (
 	"github.com/skycoin/skywire-utilities/pkg/buildinfo"
 	"github.com/skycoin/skywire-utilities/pkg/cipher"
 	"github.com/skycoin/skywire-utilities/pkg/logging"
+	"github.com/skycoin/skywire-utilities/pkg/netutil"
 	"github.com/skycoin/skywire/pkg/app/appcommon"
 	"github.com/skycoin/skywire/pkg/app/appnet"
 	"github.com/skycoin/skywire/pkg/app/appserver"
@@ -38,17 +39,58 @@ var (
 
 	// ErrTimeout represents a timed-out call.
 	ErrTimeout = errors.New("rpc client timeout")
+
+	// ErrReconnectBackoff is returned when reconnecting is skipped, as the last
+	// reconnect failed less than the max backoff ago.
+	ErrReconnectBackoff = errors.New("rpc client reconnect is backing off")
 )
 
+// RPCReconnect configures reconnecting of the RPC client when the connection to the
+// RPC server is lost. Zero backoff params are replaced with the defaults of netutil.Retrier
+type RPCReconnect struct {
+	// Dial opens a new connection to the RPC server.
+	Dial func() (io.ReadWriteCloser, error)
+	// InitBackoff is the delay before the second dial attempt,
+	// it is multiplied by Factor after every failed attempt up to MaxBackoff.
+	InitBackoff time.Duration
+	MaxBackoff  time.Duration
+	Factor      float64
+	// Tries bounds the dial attempts of a single reconnect.
+	Tries int64
+}
+
+// defaultRPCReconnectTries bounds reconnects, as the retrier retries forever by default
+const defaultRPCReconnectTries = 3
+
+func (r RPCReconnect) withDefaults() RPCReconnect {
+	if r.InitBackoff <= 0 {
+		r.InitBackoff = netutil.DefaultInitBackoff
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = netutil.DefaultMaxBackoff
+	}
+	if r.Factor <= 0 {
+		r.Factor = netutil.DefaultFactor
+	}
+	if r.Tries <= 0 {
+		r.Tries = defaultRPCReconnectTries
+	}
+	return r
+}
+
 // API provides methods to call an RPC Server.
 // It implements API
 type rpcClient struct {
 	log     logrus.FieldLogger
 	timeout time.Duration
-	conn    io.ReadWriteCloser
-	client  *rpc.Client
 	prefix  string
 	FixGob  bool
+
+	reconnect  RPCReconnect
+	mx         sync.Mutex // guards fields below
+	conn       io.ReadWriteCloser
+	client     *rpc.Client
+	nextRedial time.Time // reconnects are skipped until then after a failed one
 }
 
 // NewRPCClient creates a new API.
@@ -65,6 +107,17 @@ func NewRPCClient(log logrus.FieldLogger, conn io.ReadWriteCloser, prefix string
 	}
 }
 
+// NewRPCClientWithReconnect creates a new API, which reconnects to the RPC server
+// using reconnect when the connection is lost. A call failed due to the lost
+// connection is retried once after reconnecting
+func NewRPCClientWithReconnect(log logrus.FieldLogger, conn io.ReadWriteCloser, prefix string, timeout time.Duration, reconnect RPCReconnect) API {
+	rc := NewRPCClient(log, conn, prefix, timeout).(*rpcClient)
+	if reconnect.Dial != nil {
+		rc.reconnect = reconnect.withDefaults()
+	}
+	return rc
+}
+
 // Call calls the internal rpc.Client with the serviceMethod arg prefixed.
 func (rc *rpcClient) Call(method string, args, reply interface{}) error {
 	ctx := context.Background()
@@ -83,17 +136,77 @@ func (rc *rpcClient) Call(method string, args, reply interface{}) error {
 		defer cancel()
 	}
 
+	client, conn := rc.current()
+	err := rc.call(ctx, client, conn, method, args, reply)
+	if rc.reconnect.Dial == nil || !isRPCConnLost(err) {
+		return err
+	}
+	rc.log.WithError(err).Debugf("Lost connection to RPC server calling %s, reconnecting.", method)
+	client, conn, rErr := rc.redial(ctx, client)
+	if rErr != nil {
+		rc.log.WithError(rErr).Warn("Failed to reconnect to RPC server.")
+		return err
+	}
+	return rc.call(ctx, client, conn, method, args, reply)
+}
+
+func (rc *rpcClient) call(ctx context.Context, client *rpc.Client, conn io.Closer, method string, args, reply interface{}) error {
 	select {
-	case call := <-rc.client.Go(rc.prefix+"."+method, args, reply, nil).Done:
+	case call := <-client.Go(rc.prefix+"."+method, args, reply, nil).Done:
 		return call.Error
 	case <-ctx.Done():
-		if err := rc.conn.Close(); err != nil {
+		if err := conn.Close(); err != nil {
 			rc.log.WithError(err).Warn("Failed to close rpc client after timeout error.")
 		}
 		return ctx.Err()
 	}
 }
 
+func (rc *rpcClient) current() (*rpc.Client, io.Closer) {
+	rc.mx.Lock()
+	defer rc.mx.Unlock()
+	return rc.client, rc.conn
+}
+
+// redial replaces the failed client with a client over a new connection. Concurrent
+// callers failed with the same client share a single reconnect. After a failed
+// reconnect, further ones are skipped for the max backoff, so that calls fail fast
+// instead of each of them dialing the server which is down
+func (rc *rpcClient) redial(ctx context.Context, failed *rpc.Client) (*rpc.Client, io.Closer, error) {
+	rc.mx.Lock()
+	defer rc.mx.Unlock()
+	if rc.client != failed {
+		return rc.client, rc.conn, nil
+	}
+	if time.Now().Before(rc.nextRedial) {
+		return nil, nil, ErrReconnectBackoff
+	}
+
+	r := rc.reconnect
+	var conn io.ReadWriteCloser
+	retrier := netutil.NewRetrier(rc.log, r.InitBackoff, r.MaxBackoff, r.Tries, r.Factor)
+	err := retrier.Do(ctx, func() (err error) {
+		conn, err = r.Dial()
+		return err
+	})
+	if err != nil {
+		rc.nextRedial = time.Now().Add(r.MaxBackoff)
+		return nil, nil, err
+	}
+	if err := rc.client.Close(); err != nil && !errors.Is(err, rpc.ErrShutdown) {
+		rc.log.WithError(err).Debug("Failed to close lost rpc client.")
+	}
+	rc.conn = conn
+	rc.client = rpc.NewClient(conn)
+	rc.log.Info("Reconnected to RPC server.")
+	return rc.client, rc.conn, nil
+}
+
+// isRPCConnLost returns true if err means the connection to the RPC server is lost
+func isRPCConnLost(err error) bool {
+	return errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 // Summary calls Summary.
 func (rc *rpcClient) Summary() (*Summary, error) {
 	out := new(Summary)
@@ -364,6 +477,24 @@ func (rc *rpcClient) TransportTypes() ([]string, error) {
 	return types, err
 }
 
+// NetworkStatus calls NetworkStatus.
+func (rc *rpcClient) NetworkStatus() ([]network.NetworkStatus, error) {
+	var statuses []network.NetworkStatus
+	err := rc.Call("NetworkStatus", &struct{}{}, &statuses)
+	return statuses, err
+}
+
+// PingNetworks calls PingNetworks.
+func (rc *rpcClient) PingNetworks(remote cipher.PubKey, netType string, timeout time.Duration) (map[network.Type]transport.PingResult, error) {
+	var results map[network.Type]transport.PingResult
+	err := rc.Call("PingNetworks", &PingNetworksIn{
+		RemotePK: remote,
+		NetType:  netType,
+		Timeout:  timeout,
+	}, &results)
+	return results, err
+}
+
 // Transports calls Transports.
 func (rc *rpcClient) Transports(types []string, pks []cipher.PubKey, logs bool) ([]*TransportSummary, error) {
 	transports := make([]*TransportSummary, 0)
@@ -495,6 +626,28 @@ func (rc *rpcClient) GetPersistentTransports() ([]transport.PersistentTransports
 	return tps, err
 }
 
+// STCPEntries calls STCPEntries.
+func (rc *rpcClient) STCPEntries() (map[cipher.PubKey]string, error) {
+	var entries map[cipher.PubKey]string
+	err := rc.Call("STCPEntries", &struct{}{}, &entries)
+	return entries, err
+}
+
+// AddSTCPEntry calls AddSTCPEntry.
+func (rc *rpcClient) AddSTCPEntry(pk cipher.PubKey, addr string, persist bool) error {
+	return rc.Call("AddSTCPEntry", &STCPEntryIn{PK: pk, Addr: addr, Persist: persist}, &struct{}{})
+}
+
+// RemoveSTCPEntry calls RemoveSTCPEntry.
+func (rc *rpcClient) RemoveSTCPEntry(pk cipher.PubKey, persist bool) error {
+	return rc.Call("RemoveSTCPEntry", &STCPEntryIn{PK: pk, Persist: persist}, &struct{}{})
+}
+
+// SetDmsgSessions calls SetDmsgSessions.
+func (rc *rpcClient) SetDmsgSessions(n int, persist bool) error {
+	return rc.Call("SetDmsgSessions", &DmsgSessionsIn{Sessions: n, Persist: persist}, &struct{}{})
+}
+
 // SetLogRotationInterval sets the log_rotation_interval from visor config
 func (rc *rpcClient) SetLogRotationInterval(d visorconfig.Duration) error {
 	err := rc.Call("SetLogRotationInterval", &d, &struct{}{})
@@ -1093,6 +1246,26 @@ func (mc *mockRPCClient) TransportTypes() ([]string, error) {
 	return res, nil
 }
 
+// NetworkStatus implements API.
+func (mc *mockRPCClient) NetworkStatus() ([]network.NetworkStatus, error) {
+	res := make([]network.NetworkStatus, 0, len(mc.tpTypes))
+	for _, tpType := range mc.tpTypes {
+		res = append(res, network.NetworkStatus{Network: tpType, Ready: true})
+	}
+	return res, nil
+}
+
+// PingNetworks implements API.
+func (mc *mockRPCClient) PingNetworks(_ cipher.PubKey, netType string, _ time.Duration) (map[network.Type]transport.PingResult, error) {
+	res := make(map[network.Type]transport.PingResult)
+	for _, tpType := range mc.tpTypes {
+		if netType == "" || netType == string(tpType) {
+			res[tpType] = transport.PingResult{Latency: time.Millisecond}
+		}
+	}
+	return res, nil
+}
+
 // Transports implements API.
 func (mc *mockRPCClient) Transports(types []string, pks []cipher.PubKey, logs bool) ([]*TransportSummary, error) {
 	var summaries []*TransportSummary
@@ -1148,11 +1321,12 @@ func (mc *mockRPCClient) Transport(tid uuid.UUID) (*TransportSummary, error) {
 // AddTransport implements API.
 func (mc *mockRPCClient) AddTransport(remote cipher.PubKey, tpType string, _ time.Duration) (*TransportSummary, error) {
 	summary := &TransportSummary{
-		ID:     transport.MakeTransportID(mc.o.PubKey, remote, network.Type(tpType)),
-		Local:  mc.o.PubKey,
-		Remote: remote,
-		Type:   network.Type(tpType),
-		Log:    transport.NewLogEntry(),
+		ID:       transport.MakeTransportID(mc.o.PubKey, remote, network.Type(tpType)),
+		Local:    mc.o.PubKey,
+		Remote:   remote,
+		Type:     network.Type(tpType),
+		IsDirect: network.Type(tpType).IsDirect(),
+		Log:      transport.NewLogEntry(),
 	}
 	return summary, mc.do(true, func() error {
 		mc.o.Transports = append(mc.o.Transports, summary)
@@ -1274,6 +1448,26 @@ func (mc *mockRPCClient) GetPersistentTransports() ([]transport.PersistentTransp
 	return []transport.PersistentTransports{}, nil
 }
 
+// STCPEntries implements API
+func (mc *mockRPCClient) STCPEntries() (map[cipher.PubKey]string, error) {
+	return map[cipher.PubKey]string{}, nil
+}
+
+// AddSTCPEntry implements API
+func (mc *mockRPCClient) AddSTCPEntry(_ cipher.PubKey, _ string, _ bool) error {
+	return nil
+}
+
+// RemoveSTCPEntry implements API
+func (mc *mockRPCClient) RemoveSTCPEntry(_ cipher.PubKey, _ bool) error {
+	return nil
+}
+
+// SetDmsgSessions implements API
+func (mc *mockRPCClient) SetDmsgSessions(_ int, _ bool) error {
+	return nil
+}
+
 // SetLogRotationInterval implements API
 func (mc *mockRPCClient) SetLogRotationInterval(_ visorconfig.Duration) error {
 	return nil
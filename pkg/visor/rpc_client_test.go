@@ -0,0 +1,118 @@
+// Package visor pkg/visor/rpc_client_test.go
+package visor
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// restartableRPCServer serves visor RPC over TCP and can be stopped and
+// started again on the same address, dropping the connections of clients
+type restartableRPCServer struct {
+	t     *testing.T
+	addr  string
+	lis   net.Listener
+	mx    sync.Mutex
+	conns []net.Conn
+}
+
+func newRestartableRPCServer(t *testing.T) *restartableRPCServer {
+	s := &restartableRPCServer{t: t, addr: "127.0.0.1:0"}
+	s.start()
+	s.addr = s.lis.Addr().String()
+	t.Cleanup(s.stop)
+	return s
+}
+
+func (s *restartableRPCServer) start() {
+	rpcS := rpc.NewServer()
+	require.NoError(s.t, rpcS.RegisterName(RPCPrefix, &RPC{visor: &Visor{startedAt: time.Now()}, log: logrus.New()}))
+	lis, err := net.Listen("tcp", s.addr)
+	require.NoError(s.t, err)
+	s.lis = lis
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			s.mx.Lock()
+			s.conns = append(s.conns, conn)
+			s.mx.Unlock()
+			go rpcS.ServeConn(conn)
+		}
+	}()
+}
+
+func (s *restartableRPCServer) stop() {
+	s.lis.Close() //nolint:errcheck
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, conn := range s.conns {
+		conn.Close() //nolint:errcheck
+	}
+	s.conns = nil
+}
+
+func (s *restartableRPCServer) dial() (io.ReadWriteCloser, error) {
+	return net.Dial("tcp", s.addr)
+}
+
+func TestRPCClient_Reconnect(t *testing.T) {
+	srv := newRestartableRPCServer(t)
+	conn, err := srv.dial()
+	require.NoError(t, err)
+
+	reconnect := RPCReconnect{
+		Dial:        srv.dial,
+		InitBackoff: 10 * time.Millisecond,
+		MaxBackoff:  100 * time.Millisecond,
+		Tries:       3,
+	}
+	client := NewRPCClientWithReconnect(logrus.New(), conn, RPCPrefix, 5*time.Second, reconnect)
+	_, err = client.Uptime()
+	require.NoError(t, err)
+
+	t.Run("server restarted", func(t *testing.T) {
+		srv.stop()
+		srv.start()
+		_, err := client.Uptime()
+		require.NoError(t, err)
+		_, err = client.Uptime()
+		require.NoError(t, err)
+	})
+
+	t.Run("server down", func(t *testing.T) {
+		srv.stop()
+		_, err := client.Uptime()
+		require.Error(t, err)
+		// reconnects back off after failing instead of dialing on every call
+		rc := client.(*rpcClient)
+		_, _, err = rc.redial(context.Background(), rc.client)
+		require.ErrorIs(t, err, ErrReconnectBackoff)
+
+		srv.start()
+		require.Eventually(t, func() bool {
+			_, err := client.Uptime()
+			return err == nil
+		}, 5*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("without reconnect", func(t *testing.T) {
+		conn, err := srv.dial()
+		require.NoError(t, err)
+		client := NewRPCClient(logrus.New(), conn, RPCPrefix, 5*time.Second)
+		srv.stop()
+		srv.start()
+		_, err = client.Uptime()
+		require.Error(t, err)
+	})
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/skycoin/skywire/pkg/app/appnet"
 	"github.com/skycoin/skywire/pkg/app/appserver"
 	"github.com/skycoin/skywire/pkg/app/launcher"
+	"github.com/skycoin/skywire/pkg/dmsgc"
 	"github.com/skycoin/skywire/pkg/routefinder/rfclient"
 	"github.com/skycoin/skywire/pkg/router"
 	"github.com/skycoin/skywire/pkg/transport"
@@ -49,6 +50,10 @@ var (
 	ErrTrpMangerNotAvailable = errors.New("no transport manager available")
 	// ErrAppLauncherNotAvailable represents error for unavailable app launcher
 	ErrAppLauncherNotAvailable = errors.New("no app launcher available")
+	// ErrSTCPNotAvailable represents error for unavailable stcp client
+	ErrSTCPNotAvailable = errors.New("no stcp client available")
+	// ErrDmsgNotAvailable represents error for unavailable dmsg client
+	ErrDmsgNotAvailable = errors.New("no dmsg client available")
 )
 
 const (
@@ -78,6 +83,7 @@ type Visor struct {
 
 	ebc          *appevent.Broadcaster // event broadcaster
 	dmsgC        *dmsg.Client
+	dmsgSessions *dmsgc.Sessions
 	dmsgDC       *dmsg.Client       // dmsg direct client
 	dClient      dmsgdisc.APIClient // dmsg direct api client
 	dmsgHTTP     *http.Client       // dmsghttp client
@@ -54,3 +54,47 @@ func (d *Duration) UnmarshalJSON(b []byte) error {
 		return errors.New("invalid duration")
 	}
 }
+
+// urlList is a list of URLs which parses from a single URL string as well,
+// and is marshaled as a string when it holds at most one URL
+type urlList []string
+
+// MarshalJSON implements json marshaling
+func (u urlList) MarshalJSON() ([]byte, error) {
+	switch len(u) {
+	case 0:
+		return json.Marshal("")
+	case 1:
+		return json.Marshal(u[0])
+	}
+	return json.Marshal([]string(u))
+}
+
+// UnmarshalJSON implements unmarshal from json
+func (u *urlList) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case nil:
+		*u = nil
+		return nil
+	case string:
+		*u = urlList{value}
+		return nil
+	case []interface{}:
+		urls := make(urlList, 0, len(value))
+		for _, url := range value {
+			s, ok := url.(string)
+			if !ok {
+				return errors.New("invalid URL list")
+			}
+			urls = append(urls, s)
+		}
+		*u = urls
+		return nil
+	default:
+		return errors.New("invalid URL list")
+	}
+}
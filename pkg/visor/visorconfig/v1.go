@@ -2,6 +2,7 @@
 package visorconfig
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"strings"
@@ -61,6 +62,71 @@ type Transport struct {
 	LogStore          *LogStore       `json:"log_store"`
 	StcprPort         int             `json:"stcpr_port"`
 	SudphPort         int             `json:"sudph_port"`
+	SquicPort         int             `json:"squic_port,omitempty"`       // squic network is started only if the port is set
+	DialTimeout       Duration        `json:"dial_timeout,omitempty"`     // bounds dials without a deadline, examples: 10s, 1m
+	Compression       bool            `json:"compression,omitempty"`      // compresses transports to visors that support it
+	Mux               bool            `json:"mux,omitempty"`              // multiplexes direct transports to the same visor over a single connection
+	MuxIdleTimeout    Duration        `json:"mux_idle_timeout,omitempty"` // closes multiplexing connections without transports, examples: 30s, 5m
+	Networks          map[string]int  `json:"networks,omitempty"`         // networks registered by other packages to start, mapped to their ports
+	// Keepalive configures detection of dead peers of direct transports,
+	// keyed by network type. Unset networks use the defaults
+	Keepalive map[string]Keepalive `json:"keepalive,omitempty"`
+	// RateLimits cap bandwidth of transports, keyed by network type
+	RateLimits map[string]RateLimit `json:"rate_limits,omitempty"`
+	// AddressResolverFallbacks are used in order while AddressResolver fails.
+	// They are configured by giving a list of URLs as "address_resolver"
+	AddressResolverFallbacks []string `json:"-"`
+}
+
+// transportJSON has the fields of Transport without its json methods
+type transportJSON Transport
+
+// AddressResolvers returns URLs of all the address resolvers in the order they are used
+func (t *Transport) AddressResolvers() []string {
+	return append([]string{t.AddressResolver}, t.AddressResolverFallbacks...)
+}
+
+// MarshalJSON implements json marshaling, address resolvers are marshaled as
+// a list if there are fallbacks
+func (t Transport) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		transportJSON
+		AddressResolver urlList `json:"address_resolver"`
+	}{
+		transportJSON:   transportJSON(t),
+		AddressResolver: t.AddressResolvers(),
+	})
+}
+
+// UnmarshalJSON implements unmarshal from json, "address_resolver" is either
+// a single URL or a list of them
+func (t *Transport) UnmarshalJSON(b []byte) error {
+	aux := struct {
+		*transportJSON
+		AddressResolver urlList `json:"address_resolver"`
+	}{transportJSON: (*transportJSON)(t)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	t.AddressResolver, t.AddressResolverFallbacks = "", nil
+	if len(aux.AddressResolver) > 0 {
+		t.AddressResolver = aux.AddressResolver[0]
+		t.AddressResolverFallbacks = aux.AddressResolver[1:]
+	}
+	return nil
+}
+
+// Keepalive configures keepalive probes of a network.
+type Keepalive struct {
+	Interval Duration `json:"interval,omitempty"` // time between probes, negative disables them, examples: 15s, 1m
+	Probes   int      `json:"probes,omitempty"`   // missed probes after which the connection is closed
+}
+
+// RateLimit caps bandwidth of transports of a network.
+type RateLimit struct {
+	Up      int64 `json:"up,omitempty"`       // bytes per second written, zero is unlimited
+	Down    int64 `json:"down,omitempty"`     // bytes per second read, zero is unlimited
+	PerConn bool  `json:"per_conn,omitempty"` // limits each transport separately instead of all of them together
 }
 
 // LogStore configures a LogStore.
@@ -248,6 +314,27 @@ func (v1 *V1) GetPersistentTransports() ([]transport.PersistentTransports, error
 	return v1.PersistentTransports, nil
 }
 
+// UpdateSTCPTable updates pk_table of skywire-tcp in config
+func (v1 *V1) UpdateSTCPTable(table map[cipher.PubKey]string) error {
+	v1.mu.Lock()
+	if v1.STCP == nil {
+		v1.STCP = &network.STCPConfig{}
+	}
+	v1.STCP.PKTable = table
+	v1.mu.Unlock()
+
+	return v1.flush(v1)
+}
+
+// UpdateDmsgSessions updates sessions_count of dmsg in config
+func (v1 *V1) UpdateDmsgSessions(n int) error {
+	v1.mu.Lock()
+	v1.Dmsg.SessionsCount = n
+	v1.mu.Unlock()
+
+	return v1.flush(v1)
+}
+
 // UpdateLogRotationInterval updates log_rotation_interval in config
 func (v1 *V1) UpdateLogRotationInterval(d Duration) error {
 	v1.mu.Lock()
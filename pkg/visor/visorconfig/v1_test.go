@@ -2,9 +2,11 @@
 package visorconfig
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/skycoin/skywire/pkg/app/appserver"
 	"github.com/skycoin/skywire/pkg/skyenv"
@@ -432,3 +434,22 @@ func Test_updateBoolArg(t *testing.T) {
 		})
 	}
 }
+
+func TestTransport_AddressResolvers(t *testing.T) {
+	var single Transport
+	require.NoError(t, json.Unmarshal([]byte(`{"address_resolver": "http://ar.skywire.skycoin.com"}`), &single))
+	assert.Equal(t, "http://ar.skywire.skycoin.com", single.AddressResolver)
+	assert.Equal(t, []string{"http://ar.skywire.skycoin.com"}, single.AddressResolvers())
+	raw, err := json.Marshal(single)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"address_resolver":"http://ar.skywire.skycoin.com"`)
+
+	var list Transport
+	require.NoError(t, json.Unmarshal([]byte(`{"address_resolver": ["http://ar1", "http://ar2"], "stcpr_port": 1}`), &list))
+	assert.Equal(t, "http://ar1", list.AddressResolver)
+	assert.Equal(t, []string{"http://ar1", "http://ar2"}, list.AddressResolvers())
+	assert.Equal(t, 1, list.StcprPort)
+	raw, err = json.Marshal(list)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"address_resolver":["http://ar1","http://ar2"]`)
+}